@@ -77,7 +77,7 @@ func NewSimulatedBackend(alloc core.GenesisAlloc) *SimulatedBackend {
 		config:     genesis.Config,
 		events:     filters.NewEventSystem(new(event.TypeMux), &filterBackend{database, blockchain}, false),
 	}
-	backend.rollback()
+	backend.rollback(blockchain.CurrentBlock())
 	return backend
 }
 
@@ -90,7 +90,8 @@ func (b *SimulatedBackend) Commit() {
 	if _, err := b.blockchain.InsertChain([]*types.Block{b.pendingBlock}); err != nil {
 		panic(err) // This cannot happen unless the simulator is wrong, fail in that case
 	}
-	b.rollback()
+	// Build on the imported block even if it didn't become the head of a fork
+	b.rollback(b.pendingBlock)
 }
 
 // Rollback aborts all pending transactions, reverting to the last committed state.
@@ -98,11 +99,26 @@ func (b *SimulatedBackend) Rollback() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.rollback()
+	b.rollback(b.blockchain.GetBlockByHash(b.pendingBlock.ParentHash()))
 }
 
-func (b *SimulatedBackend) rollback() {
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(int, *core.BlockGen) {})
+// Fork discards all pending transactions and builds the following blocks on the
+// given ancestor of the head, creating a side chain which replaces the current
+// one once it grows longer.
+func (b *SimulatedBackend) Fork(ctx context.Context, parent common.Hash) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	block := b.blockchain.GetBlockByHash(parent)
+	if block == nil {
+		return errors.New("could not find parent block")
+	}
+	b.rollback(block)
+	return nil
+}
+
+func (b *SimulatedBackend) rollback(parent *types.Block) {
+	blocks, _ := core.GenerateChain(b.config, parent, ethash.NewFaker(), b.database, 1, func(int, *core.BlockGen) {})
 	statedb, _ := b.blockchain.State()
 
 	b.pendingBlock = blocks[0]
@@ -160,10 +176,28 @@ func (b *SimulatedBackend) StorageAt(ctx context.Context, contract common.Addres
 
 // TransactionReceipt returns the receipt of a transaction.
 func (b *SimulatedBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
-	receipt, _, _, _ := rawdb.ReadReceipt(b.database, txHash)
+	receipt, blockHash, blockNumber, index := rawdb.ReadReceipt(b.database, txHash)
+	if receipt != nil {
+		receipt.BlockHash, receipt.BlockNumber, receipt.TransactionIndex = blockHash, new(big.Int).SetUint64(blockNumber), uint(index)
+	}
 	return receipt, nil
 }
 
+// HeaderByNumber returns the header of the canonical block with the given number,
+// or the head block if the number is nil.
+func (b *SimulatedBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if number == nil {
+		return b.blockchain.CurrentHeader(), nil
+	}
+	if header := b.blockchain.GetHeaderByNumber(number.Uint64()); header != nil {
+		return header, nil
+	}
+	return nil, simplechain.NotFound
+}
+
 // PendingCodeAt returns the code associated with an account in the pending state.
 func (b *SimulatedBackend) PendingCodeAt(ctx context.Context, contract common.Address) ([]byte, error) {
 	b.mu.Lock()
@@ -291,8 +325,9 @@ func (b *SimulatedBackend) callContract(ctx context.Context, call simplechain.Ca
 	return core.NewStateTransition(vmenv, msg, gaspool).TransitionDb()
 }
 
-// SendTransaction updates the pending block to include the given transaction.
-// It panics if the transaction is invalid.
+// SendTransaction updates the pending block to include the given transaction,
+// replacing the pending one of the same sender and nonce if it pays a higher gas
+// price. It panics if the transaction is invalid.
 func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -301,16 +336,36 @@ func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transa
 	if err != nil {
 		panic(fmt.Errorf("invalid transaction: %v", err))
 	}
+	txs := append(types.Transactions{}, b.pendingBlock.Transactions()...)
+
 	nonce := b.pendingState.GetNonce(sender)
-	if tx.Nonce() != nonce {
+	if tx.Nonce() < nonce {
+		replaced := -1
+		for i, ptx := range txs {
+			if from, _ := types.Sender(types.HomesteadSigner{}, ptx); from == sender && ptx.Nonce() == tx.Nonce() {
+				replaced = i
+			}
+		}
+		if replaced < 0 {
+			panic(fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce))
+		}
+		if txs[replaced].Hash() == tx.Hash() {
+			return fmt.Errorf("known transaction: %x", tx.Hash())
+		}
+		if txs[replaced].GasPrice().Cmp(tx.GasPrice()) >= 0 {
+			return core.ErrReplaceUnderpriced
+		}
+		txs[replaced] = tx
+	} else if tx.Nonce() == nonce {
+		txs = append(txs, tx)
+	} else {
 		panic(fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce))
 	}
-
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
-		for _, tx := range b.pendingBlock.Transactions() {
+	parent := b.blockchain.GetBlockByHash(b.pendingBlock.ParentHash())
+	blocks, _ := core.GenerateChain(b.config, parent, ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
+		for _, tx := range txs {
 			block.AddTxWithChain(b.blockchain, tx)
 		}
-		block.AddTxWithChain(b.blockchain, tx)
 	})
 	statedb, _ := b.blockchain.State()
 
@@ -391,7 +446,8 @@ func (b *SimulatedBackend) SubscribeFilterLogs(ctx context.Context, query simple
 func (b *SimulatedBackend) AdjustTime(adjustment time.Duration) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
+	parent := b.blockchain.GetBlockByHash(b.pendingBlock.ParentHash())
+	blocks, _ := core.GenerateChain(b.config, parent, ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
 		for _, tx := range b.pendingBlock.Transactions() {
 			block.AddTx(tx)
 		}
@@ -470,6 +526,12 @@ func (fb *filterBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.
 		return nil
 	})
 }
+func (fb *filterBackend) SubscribeTxPoolEvent(ch chan<- core.TxPoolEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
 func (fb *filterBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return fb.bc.SubscribeChainEvent(ch)
 }
@@ -480,7 +542,8 @@ func (fb *filterBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscr
 	return fb.bc.SubscribeLogsEvent(ch)
 }
 
-func (fb *filterBackend) BloomStatus() (uint64, uint64) { return 4096, 0 }
+func (fb *filterBackend) BloomStatus() (uint64, uint64)    { return 4096, 0 }
+func (fb *filterBackend) LogIndexStatus() (uint64, uint64) { return 4096, 0 }
 func (fb *filterBackend) ServiceFilter(ctx context.Context, ms *bloombits.MatcherSession) {
 	panic("not supported")
 }
@@ -170,6 +170,67 @@ Remove blockchain and state databases`,
 The arguments are interpreted as block numbers or hashes.
 Use "sipe dump 0" to dump the genesis block.`,
 	}
+	chainRewindTargetFlag = cli.StringFlag{
+		Name:  "to",
+		Usage: "Number or hash of the block to rewind the chain to",
+	}
+	chainVerifyRepairFlag = cli.BoolFlag{
+		Name:  "repair",
+		Usage: "Repair detected inconsistencies, rewinding the chain if needed",
+	}
+	chainCommand = cli.Command{
+		Name:     "chain",
+		Usage:    "Inspect and repair the local blockchain",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Maintenance commands to recover a chain database after an unclean shutdown
+without having to remove it and resynchronise from scratch.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "rewind",
+				Usage:     "Rewind the local chain to a past block",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(rewindChain),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.LightModeFlag,
+					utils.GCModeFlag,
+					chainRewindTargetFlag,
+				},
+				Description: `
+	sipe chain rewind --to <number|hash>
+
+Rewinds the head of the local chain to the given canonical block, discarding
+everything above it. If the state of the target block is not available, the
+chain is rewound further to the closest ancestor that has state.`,
+			},
+			{
+				Name:      "verify",
+				Usage:     "Verify the consistency of the local chain",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(verifyChain),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.LightModeFlag,
+					chainVerifyRepairFlag,
+				},
+				Description: `
+	sipe chain verify [--repair]
+
+Walks the canonical chain from the head header down to the genesis block,
+checking headers, bodies, receipts, total difficulties, transaction lookups
+and canonical hash mappings.
+
+With --repair, derivable data is rebuilt in place and, if chain data or the
+head state is missing, the chain is rewound to the last consistent block that
+has state available.`,
+			},
+		},
+	}
 )
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
@@ -467,6 +528,90 @@ func dump(ctx *cli.Context) error {
 	return nil
 }
 
+// rewindChain rewinds the local chain to the block requested by the user, or
+// to its closest ancestor with state available.
+func rewindChain(ctx *cli.Context) error {
+	arg := ctx.String(chainRewindTargetFlag.Name)
+	if arg == "" {
+		utils.Fatalf("The rewind target must be specified with --%s", chainRewindTargetFlag.Name)
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	var target *types.Block
+	if hashish(arg) {
+		if target = chain.GetBlockByHash(common.HexToHash(arg)); target != nil {
+			if canonical := chain.GetHeaderByNumber(target.NumberU64()); canonical == nil || canonical.Hash() != target.Hash() {
+				utils.Fatalf("Block %s is not canonical", arg)
+			}
+		}
+	} else {
+		number, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			utils.Fatalf("Invalid rewind target %q: %v", arg, err)
+		}
+		target = chain.GetBlockByNumber(number)
+	}
+	if target == nil {
+		utils.Fatalf("Rewind target %s not found", arg)
+	}
+	head := chain.CurrentBlock()
+	if target.NumberU64() >= head.NumberU64() {
+		utils.Fatalf("Rewind target #%d not below current head #%d", target.NumberU64(), head.NumberU64())
+	}
+	// Make sure we don't end up with a head block without state
+	for {
+		if _, err := chain.StateAt(target.Root()); err == nil {
+			break
+		}
+		if target.NumberU64() == 0 {
+			utils.Fatalf("Genesis state missing")
+		}
+		log.Warn("Rewind target state missing, rewinding further", "number", target.NumberU64(), "hash", target.Hash())
+		if target = chain.GetBlock(target.ParentHash(), target.NumberU64()-1); target == nil {
+			utils.Fatalf("Rewind target ancestor missing")
+		}
+	}
+	start := time.Now()
+	if err := chain.SetHead(target.NumberU64()); err != nil {
+		utils.Fatalf("Rewind failed: %v", err)
+	}
+	chain.Stop()
+
+	fmt.Printf("Rewound chain from #%d to #%d [%x] in %v\n", head.NumberU64(), target.NumberU64(), target.Hash().Bytes()[:4], time.Since(start))
+	return nil
+}
+
+// verifyChain checks the local chain database for inconsistencies, optionally
+// repairing them.
+func verifyChain(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	start := time.Now()
+	res, err := core.VerifyChain(chainDb, ctx.Bool(chainVerifyRepairFlag.Name))
+	if err != nil {
+		utils.Fatalf("Chain verification failed: %v", err)
+	}
+	for _, issue := range res.Issues {
+		status := "unfixed"
+		if issue.Fixed {
+			status = "fixed"
+		}
+		fmt.Printf("#%-10d %x  %-24s %s\n", issue.Number, issue.Hash, issue.Kind, status)
+	}
+	fmt.Printf("Verified %d blocks in %v: %d issues, %d unfixed\n", res.Head+1, time.Since(start), len(res.Issues), res.Unfixed())
+	switch {
+	case res.Rewound:
+		fmt.Printf("Chain rewound to last consistent block #%d\n", res.Consistent)
+	case res.Consistent < res.Head && len(res.Issues) > 0:
+		fmt.Printf("Last consistent block is #%d, run with --%s to rewind\n", res.Consistent, chainVerifyRepairFlag.Name)
+	}
+	return nil
+}
+
 // hashish returns true for strings that look like hashes.
 func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
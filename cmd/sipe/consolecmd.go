@@ -113,22 +113,7 @@ func localConsole(ctx *cli.Context) error {
 // console to it.
 func remoteConsole(ctx *cli.Context) error {
 	// Attach to a remotely running geth instance and start the JavaScript console
-	endpoint := ctx.Args().First()
-	if endpoint == "" {
-		path := node.DefaultDataDir()
-		if ctx.GlobalIsSet(utils.DataDirFlag.Name) {
-			path = ctx.GlobalString(utils.DataDirFlag.Name)
-		}
-		if path != "" {
-			if ctx.GlobalBool(utils.TestnetFlag.Name) {
-				path = filepath.Join(path, "testnet")
-			} else if ctx.GlobalBool(utils.RinkebyFlag.Name) {
-				path = filepath.Join(path, "rinkeby")
-			}
-		}
-		endpoint = fmt.Sprintf("%s/sipe.ipc", path)
-	}
-	client, err := dialRPC(endpoint)
+	client, err := dialRPC(attachEndpoint(ctx))
 	if err != nil {
 		utils.Fatalf("Unable to attach to remote sipe: %v", err)
 	}
@@ -157,6 +142,26 @@ func remoteConsole(ctx *cli.Context) error {
 	return nil
 }
 
+// attachEndpoint returns the endpoint of the running node to attach to, given
+// as the first argument or defaulting to the IPC endpoint of the data directory.
+func attachEndpoint(ctx *cli.Context) string {
+	if endpoint := ctx.Args().First(); endpoint != "" {
+		return endpoint
+	}
+	path := node.DefaultDataDir()
+	if ctx.GlobalIsSet(utils.DataDirFlag.Name) {
+		path = ctx.GlobalString(utils.DataDirFlag.Name)
+	}
+	if path != "" {
+		if ctx.GlobalBool(utils.TestnetFlag.Name) {
+			path = filepath.Join(path, "testnet")
+		} else if ctx.GlobalBool(utils.RinkebyFlag.Name) {
+			path = filepath.Join(path, "rinkeby")
+		}
+	}
+	return fmt.Sprintf("%s/sipe.ipc", path)
+}
+
 // dialRPC returns a RPC client which connects to the given endpoint.
 // The check for empty endpoint implements the defaulting logic
 // for "geth attach" and "geth monitor" with no argument.
@@ -79,6 +79,7 @@ var (
 
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
+		utils.TxPoolRemoteJournalFlag,
 		utils.TxPoolRejournalFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
@@ -87,10 +88,16 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolMaxPerContractFlag,
+		utils.TxPoolPeerRateFlag,
+		utils.TxPoolPeerBurstFlag,
 		//utils.FastSyncFlag,
 		//utils.LightModeFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.ReceiptsHistoryFlag,
+		utils.TraceIndexFlag,
+		utils.LogIndexFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
@@ -142,11 +149,37 @@ var (
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
+		utils.RPCLogRangeLimitFlag,
+		utils.RPCLogResultLimitFlag,
+		utils.SafeDepthFlag,
+		utils.FinalizedDepthFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
 		utils.WSApiFlag,
 		utils.WSAllowedOriginsFlag,
+		utils.BinaryEnabledFlag,
+		utils.BinaryListenAddrFlag,
+		utils.BinaryPortFlag,
+		utils.BinaryApiFlag,
+		utils.RPCJWTSecretFlag,
+		utils.RPCJWTAccessFlag,
+		utils.RPCAPIKeysFlag,
+		utils.RPCAuthIPCFlag,
+		utils.RPCRateLimitFlag,
+		utils.RPCRateBurstFlag,
+		utils.RPCMethodCostsFlag,
+		utils.RPCHeavyMethodsFlag,
+		utils.RPCHeavyConcurrencyFlag,
+		utils.RPCTimeoutFlag,
+		utils.RPCBatchItemLimitFlag,
+		utils.RPCResponseSizeLimitFlag,
+		utils.RPCSlowRequestThresholdFlag,
+		utils.GraphQLEnabledFlag,
+		utils.GraphQLListenAddrFlag,
+		utils.GraphQLPortFlag,
+		utils.GraphQLCORSDomainFlag,
+		utils.GraphQLVirtualHostsFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
 	}
@@ -182,6 +215,7 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		chainCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -197,6 +231,7 @@ func init() {
 		versionCommand,
 		bugCommand,
 		licenseCommand,
+		openrpcCommand,
 		// See config.go
 		dumpConfigCommand,
 	}
@@ -17,6 +17,8 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"runtime"
@@ -46,6 +48,18 @@ The output of this command is supposed to be machine-readable.
 		ArgsUsage: " ",
 		Category:  "MISCELLANEOUS COMMANDS",
 	}
+	openrpcCommand = cli.Command{
+		Action:    utils.MigrateFlags(openrpc),
+		Name:      "openrpc",
+		Usage:     "Print the OpenRPC description of the API of a running node",
+		ArgsUsage: "[endpoint]",
+		Flags:     []cli.Flag{utils.DataDirFlag},
+		Category:  "MISCELLANEOUS COMMANDS",
+		Description: `
+The openrpc command attaches to a running sipe node, on the given endpoint or
+on the IPC endpoint of the data directory, and prints the OpenRPC document
+describing the methods it serves, as returned by rpc_discover.`,
+	}
 )
 
 func version(ctx *cli.Context) error {
@@ -79,3 +93,22 @@ You should have received a copy of the GNU General Public License
 along with sipe. If not, see <http://www.gnu.org/licenses/>.`)
 	return nil
 }
+
+func openrpc(ctx *cli.Context) error {
+	client, err := dialRPC(attachEndpoint(ctx))
+	if err != nil {
+		utils.Fatalf("Unable to attach to remote sipe: %v", err)
+	}
+	defer client.Close()
+
+	var doc json.RawMessage
+	if err := client.Call(&doc, "rpc_discover"); err != nil {
+		utils.Fatalf("Failed to retrieve the OpenRPC document: %v", err)
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, doc, "", "  "); err != nil {
+		utils.Fatalf("Invalid OpenRPC document: %v", err)
+	}
+	fmt.Println(out.String())
+	return nil
+}
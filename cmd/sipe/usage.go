@@ -76,6 +76,9 @@ var AppHelpFlagGroups = []flagGroup{
 			//utils.RinkebyFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.ReceiptsHistoryFlag,
+			utils.TraceIndexFlag,
+			utils.LogIndexFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightServFlag,
@@ -116,6 +119,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.TxPoolNoLocalsFlag,
 			utils.TxPoolJournalFlag,
+			utils.TxPoolRemoteJournalFlag,
 			utils.TxPoolRejournalFlag,
 			utils.TxPoolPriceLimitFlag,
 			utils.TxPoolPriceBumpFlag,
@@ -124,6 +128,9 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolMaxPerContractFlag,
+			utils.TxPoolPeerRateFlag,
+			utils.TxPoolPeerBurstFlag,
 		},
 	},
 	{
@@ -149,11 +156,37 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RPCListenAddrFlag,
 			utils.RPCPortFlag,
 			utils.RPCApiFlag,
+			utils.RPCLogRangeLimitFlag,
+			utils.RPCLogResultLimitFlag,
+			utils.SafeDepthFlag,
+			utils.FinalizedDepthFlag,
 			utils.WSEnabledFlag,
 			utils.WSListenAddrFlag,
 			utils.WSPortFlag,
 			utils.WSApiFlag,
 			utils.WSAllowedOriginsFlag,
+			utils.BinaryEnabledFlag,
+			utils.BinaryListenAddrFlag,
+			utils.BinaryPortFlag,
+			utils.BinaryApiFlag,
+			utils.RPCJWTSecretFlag,
+			utils.RPCJWTAccessFlag,
+			utils.RPCAPIKeysFlag,
+			utils.RPCAuthIPCFlag,
+			utils.RPCRateLimitFlag,
+			utils.RPCRateBurstFlag,
+			utils.RPCMethodCostsFlag,
+			utils.RPCHeavyMethodsFlag,
+			utils.RPCHeavyConcurrencyFlag,
+			utils.RPCTimeoutFlag,
+			utils.RPCBatchItemLimitFlag,
+			utils.RPCResponseSizeLimitFlag,
+			utils.RPCSlowRequestThresholdFlag,
+			utils.GraphQLEnabledFlag,
+			utils.GraphQLListenAddrFlag,
+			utils.GraphQLPortFlag,
+			utils.GraphQLCORSDomainFlag,
+			utils.GraphQLVirtualHostsFlag,
 			utils.IPCDisabledFlag,
 			utils.IPCPathFlag,
 			utils.RPCCORSDomainFlag,
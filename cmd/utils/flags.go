@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -43,9 +44,12 @@ import (
 	"github.com/simplechain-org/go-simplechain/dashboard"
 	"github.com/simplechain-org/go-simplechain/eth"
 	"github.com/simplechain-org/go-simplechain/eth/downloader"
+	"github.com/simplechain-org/go-simplechain/eth/filters"
 	"github.com/simplechain-org/go-simplechain/eth/gasprice"
 	"github.com/simplechain-org/go-simplechain/ethdb"
 	"github.com/simplechain-org/go-simplechain/ethstats"
+	"github.com/simplechain-org/go-simplechain/graphql"
+	"github.com/simplechain-org/go-simplechain/internal/ethapi"
 	"github.com/simplechain-org/go-simplechain/les"
 	"github.com/simplechain-org/go-simplechain/log"
 	"github.com/simplechain-org/go-simplechain/metrics"
@@ -57,6 +61,7 @@ import (
 	"github.com/simplechain-org/go-simplechain/p2p/nat"
 	"github.com/simplechain-org/go-simplechain/p2p/netutil"
 	"github.com/simplechain-org/go-simplechain/params"
+	"github.com/simplechain-org/go-simplechain/rpc"
 	whisper "github.com/simplechain-org/go-simplechain/whisper/whisperv6"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -177,6 +182,18 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	ReceiptsHistoryFlag = cli.Uint64Flag{
+		Name:  "history.receipts",
+		Usage: "Number of recent blocks to keep receipts of, older ones are regenerated on demand (0 = entire chain)",
+	}
+	TraceIndexFlag = cli.BoolFlag{
+		Name:  "trace.index",
+		Usage: "Enable background indexing of transaction call traces for the trace RPC namespace",
+	}
+	LogIndexFlag = cli.BoolFlag{
+		Name:  "log.index",
+		Usage: "Enable background indexing of log addresses and topics for fast log queries over wide ranges",
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -251,9 +268,14 @@ var (
 		Usage: "Disk journal for local transaction to survive node restarts",
 		Value: core.DefaultTxPoolConfig.Journal,
 	}
+	TxPoolRemoteJournalFlag = cli.StringFlag{
+		Name:  "txpool.remotejournal",
+		Usage: "Disk snapshot of remote transactions to survive node restarts (empty = disabled)",
+		Value: core.DefaultTxPoolConfig.RemoteJournal,
+	}
 	TxPoolRejournalFlag = cli.DurationFlag{
 		Name:  "txpool.rejournal",
-		Usage: "Time interval to regenerate the local transaction journal",
+		Usage: "Time interval to regenerate the transaction journals",
 		Value: core.DefaultTxPoolConfig.Rejournal,
 	}
 	TxPoolPriceLimitFlag = cli.Uint64Flag{
@@ -291,6 +313,21 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolMaxPerContractFlag = cli.Uint64Flag{
+		Name:  "txpool.maxpercontract",
+		Usage: "Maximum number of pooled transactions calling a single contract (0 = unlimited)",
+		Value: eth.DefaultConfig.TxPool.Policy.MaxPerContract,
+	}
+	TxPoolPeerRateFlag = cli.Float64Flag{
+		Name:  "txpool.peerrate",
+		Usage: "Remote transactions per second accepted from a single peer (0 = unlimited)",
+		Value: eth.DefaultConfig.TxPool.Policy.PeerRate,
+	}
+	TxPoolPeerBurstFlag = cli.Uint64Flag{
+		Name:  "txpool.peerburst",
+		Usage: "Maximum number of remote transactions a single peer may relay in one burst",
+		Value: eth.DefaultConfig.TxPool.Policy.PeerBurst,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -433,6 +470,69 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	RPCLogRangeLimitFlag = cli.Uint64Flag{
+		Name:  "rpc.logs.maxrange",
+		Usage: "Maximum number of blocks a single log query may span (0 = unlimited)",
+		Value: eth.DefaultConfig.LogQueryRangeLimit,
+	}
+	RPCLogResultLimitFlag = cli.IntFlag{
+		Name:  "rpc.logs.maxresults",
+		Usage: "Maximum number of logs a single log query may return (0 = unlimited)",
+		Value: eth.DefaultConfig.LogQueryResultLimit,
+	}
+	SafeDepthFlag = cli.Uint64Flag{
+		Name:  "confirmations.safe",
+		Usage: `Number of confirmations after which blocks are considered "safe"`,
+		Value: eth.DefaultConfig.SafeDepth,
+	}
+	FinalizedDepthFlag = cli.Uint64Flag{
+		Name:  "confirmations.finalized",
+		Usage: `Number of confirmations after which blocks are considered "finalized"`,
+		Value: eth.DefaultConfig.FinalizedDepth,
+	}
+	BinaryEnabledFlag = cli.BoolFlag{
+		Name:  "rpc.binary",
+		Usage: "Enable the binary RPC server (length prefixed RLP frames over TCP)",
+	}
+	BinaryListenAddrFlag = cli.StringFlag{
+		Name:  "rpc.binary.addr",
+		Usage: "Binary RPC server listening interface",
+		Value: node.DefaultBinaryHost,
+	}
+	BinaryPortFlag = cli.IntFlag{
+		Name:  "rpc.binary.port",
+		Usage: "Binary RPC server listening port",
+		Value: node.DefaultBinaryPort,
+	}
+	BinaryApiFlag = cli.StringFlag{
+		Name:  "rpc.binary.api",
+		Usage: "API's offered over the binary RPC interface",
+		Value: "",
+	}
+	GraphQLEnabledFlag = cli.BoolFlag{
+		Name:  "graphql",
+		Usage: "Enable the GraphQL server",
+	}
+	GraphQLListenAddrFlag = cli.StringFlag{
+		Name:  "graphql.addr",
+		Usage: "GraphQL server listening interface",
+		Value: node.DefaultGraphQLHost,
+	}
+	GraphQLPortFlag = cli.IntFlag{
+		Name:  "graphql.port",
+		Usage: "GraphQL server listening port",
+		Value: node.DefaultGraphQLPort,
+	}
+	GraphQLCORSDomainFlag = cli.StringFlag{
+		Name:  "graphql.corsdomain",
+		Usage: "Comma separated list of domains from which to accept cross origin requests (browser enforced)",
+		Value: "",
+	}
+	GraphQLVirtualHostsFlag = cli.StringFlag{
+		Name:  "graphql.vhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
+		Value: strings.Join(node.DefaultConfig.GraphQLVirtualHosts, ","),
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -452,7 +552,7 @@ var (
 	}
 	WSPortFlag = cli.IntFlag{
 		Name:  "wsport",
-		Usage: "WS-RPC server listening port",
+		Usage: "WS-RPC server listening port (same as --rpcport to share the HTTP-RPC server)",
 		Value: node.DefaultWSPort,
 	}
 	WSApiFlag = cli.StringFlag{
@@ -465,6 +565,60 @@ var (
 		Usage: "Origins from which to accept websockets requests",
 		Value: "",
 	}
+	RPCJWTSecretFlag = cli.StringFlag{
+		Name:  "rpc.jwtsecret",
+		Usage: "Path to a hex encoded HS256 secret authenticating HTTP and WS-RPC requests with JWT bearer tokens",
+	}
+	RPCJWTAccessFlag = cli.StringFlag{
+		Name:  "rpc.jwtaccess",
+		Usage: "Comma separated list of API namespaces and methods callable with a valid JWT ('*' for all)",
+	}
+	RPCAPIKeysFlag = cli.StringFlag{
+		Name:  "rpc.apikeys",
+		Usage: "Path to a JSON file mapping the API keys authenticating HTTP and WS-RPC requests to their allowed namespaces and methods",
+	}
+	RPCAuthIPCFlag = cli.BoolFlag{
+		Name:  "rpc.authipc",
+		Usage: "Require IPC connections to authenticate with the configured RPC credentials",
+	}
+	RPCRateLimitFlag = cli.Float64Flag{
+		Name:  "rpc.ratelimit",
+		Usage: "Request cost units per second allowed per HTTP and WS-RPC connection, client address or API key (0 = unlimited)",
+	}
+	RPCRateBurstFlag = cli.IntFlag{
+		Name:  "rpc.rateburst",
+		Usage: "Request cost units spendable at once by an RPC client (defaults to the rate limit)",
+	}
+	RPCMethodCostsFlag = cli.StringFlag{
+		Name:  "rpc.methodcosts",
+		Usage: "Comma separated list of API namespaces and methods with their request cost (e.g. eth_getLogs=20,debug=50)",
+	}
+	RPCHeavyMethodsFlag = cli.StringFlag{
+		Name:  "rpc.heavymethods",
+		Usage: "Comma separated list of API namespaces and methods subject to the heavy request concurrency limit",
+	}
+	RPCHeavyConcurrencyFlag = cli.IntFlag{
+		Name:  "rpc.heavyconcurrency",
+		Usage: "Maximum number of heavy RPC requests executed at once (0 = unlimited)",
+	}
+	RPCBatchItemLimitFlag = cli.IntFlag{
+		Name:  "rpc.batchlimit",
+		Usage: "Maximum number of requests in an RPC batch (0 = unlimited)",
+		Value: node.DefaultConfig.RPCBatchItemLimit,
+	}
+	RPCResponseSizeLimitFlag = cli.IntFlag{
+		Name:  "rpc.responselimit",
+		Usage: "Maximum size in bytes of an HTTP or WebSocket RPC response or batch of responses (0 = unlimited)",
+		Value: node.DefaultConfig.RPCResponseSizeLimit,
+	}
+	RPCSlowRequestThresholdFlag = cli.DurationFlag{
+		Name:  "rpc.slowthreshold",
+		Usage: "Execution time above which RPC requests are logged with their parameters (0 = disabled)",
+	}
+	RPCTimeoutFlag = cli.DurationFlag{
+		Name:  "rpc.timeout",
+		Usage: "Execution timeout of HTTP and WS-RPC requests (0 = no timeout)",
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -766,6 +920,26 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setGraphQL creates the GraphQL listener interface string from the set
+// command line flags, returning empty if the GraphQL endpoint is disabled.
+func setGraphQL(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalBool(GraphQLEnabledFlag.Name) && cfg.GraphQLHost == "" {
+		cfg.GraphQLHost = "127.0.0.1"
+		if ctx.GlobalIsSet(GraphQLListenAddrFlag.Name) {
+			cfg.GraphQLHost = ctx.GlobalString(GraphQLListenAddrFlag.Name)
+		}
+	}
+	if ctx.GlobalIsSet(GraphQLPortFlag.Name) {
+		cfg.GraphQLPort = ctx.GlobalInt(GraphQLPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(GraphQLCORSDomainFlag.Name) {
+		cfg.GraphQLCors = splitAndTrim(ctx.GlobalString(GraphQLCORSDomainFlag.Name))
+	}
+	if ctx.GlobalIsSet(GraphQLVirtualHostsFlag.Name) {
+		cfg.GraphQLVirtualHosts = splitAndTrim(ctx.GlobalString(GraphQLVirtualHostsFlag.Name))
+	}
+}
+
 // setWS creates the WebSocket RPC listener interface string from the set
 // command line flags, returning empty if the HTTP endpoint is disabled.
 func setWS(ctx *cli.Context, cfg *node.Config) {
@@ -787,6 +961,92 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setBinary creates the binary RPC listener interface string from the set
+// command line flags, returning empty if the binary endpoint is disabled.
+func setBinary(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalBool(BinaryEnabledFlag.Name) && cfg.BinaryHost == "" {
+		cfg.BinaryHost = "127.0.0.1"
+		if ctx.GlobalIsSet(BinaryListenAddrFlag.Name) {
+			cfg.BinaryHost = ctx.GlobalString(BinaryListenAddrFlag.Name)
+		}
+	}
+	if ctx.GlobalIsSet(BinaryPortFlag.Name) {
+		cfg.BinaryPort = ctx.GlobalInt(BinaryPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(BinaryApiFlag.Name) {
+		cfg.BinaryModules = splitAndTrim(ctx.GlobalString(BinaryApiFlag.Name))
+	}
+}
+
+// setRPCAuth configures the credentials required by the RPC endpoints from the
+// set command line flags.
+func setRPCAuth(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(RPCJWTSecretFlag.Name) {
+		cfg.RPCJWTSecret = ctx.GlobalString(RPCJWTSecretFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCJWTAccessFlag.Name) {
+		cfg.RPCJWTAccess = splitAndTrim(ctx.GlobalString(RPCJWTAccessFlag.Name))
+	}
+	if ctx.GlobalIsSet(RPCAPIKeysFlag.Name) {
+		blob, err := ioutil.ReadFile(ctx.GlobalString(RPCAPIKeysFlag.Name))
+		if err != nil {
+			Fatalf("Failed to read RPC API keys: %v", err)
+		}
+		if err := json.Unmarshal(blob, &cfg.RPCAPIKeys); err != nil {
+			Fatalf("Failed to parse RPC API keys: %v", err)
+		}
+	}
+	if ctx.GlobalIsSet(RPCAuthIPCFlag.Name) {
+		cfg.RPCAuthIPC = ctx.GlobalBool(RPCAuthIPCFlag.Name)
+	}
+}
+
+// setRPCLimits configures the request budget enforced by the RPC endpoints from
+// the set command line flags.
+func setRPCLimits(ctx *cli.Context, cfg *node.Config) {
+	flags := []cli.Flag{RPCRateLimitFlag, RPCRateBurstFlag, RPCMethodCostsFlag, RPCHeavyMethodsFlag, RPCHeavyConcurrencyFlag, RPCTimeoutFlag}
+
+	set := false
+	for _, flag := range flags {
+		set = set || ctx.GlobalIsSet(flag.GetName())
+	}
+	if !set {
+		return
+	}
+	if cfg.RPCLimits == nil {
+		cfg.RPCLimits = new(rpc.LimitConfig)
+	}
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCLimits.Rate = ctx.GlobalFloat64(RPCRateLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateBurstFlag.Name) {
+		cfg.RPCLimits.Burst = ctx.GlobalInt(RPCRateBurstFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCMethodCostsFlag.Name) {
+		cfg.RPCLimits.MethodCosts = make(map[string]int)
+		for _, entry := range splitAndTrim(ctx.GlobalString(RPCMethodCostsFlag.Name)) {
+			parts := strings.Split(entry, "=")
+			if len(parts) != 2 {
+				Fatalf("Invalid RPC method cost %q, want <method>=<cost>", entry)
+			}
+			cost, err := strconv.Atoi(parts[1])
+			if err != nil {
+				Fatalf("Invalid RPC method cost %q: %v", entry, err)
+			}
+			cfg.RPCLimits.MethodCosts[parts[0]] = cost
+		}
+	}
+	if ctx.GlobalIsSet(RPCHeavyMethodsFlag.Name) {
+		cfg.RPCLimits.HeavyMethods = splitAndTrim(ctx.GlobalString(RPCHeavyMethodsFlag.Name))
+	}
+	if ctx.GlobalIsSet(RPCHeavyConcurrencyFlag.Name) {
+		cfg.RPCLimits.HeavyConcurrency = ctx.GlobalInt(RPCHeavyConcurrencyFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCTimeoutFlag.Name) {
+		cfg.RPCLimits.Timeout = ctx.GlobalDuration(RPCTimeoutFlag.Name)
+	}
+}
+
 // setIPC creates an IPC path configuration from the set command line flags,
 // returning an empty string if IPC was explicitly disabled, or the set path.
 func setIPC(ctx *cli.Context, cfg *node.Config) {
@@ -944,7 +1204,21 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	SetP2PConfig(ctx, &cfg.P2P)
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
+	setGraphQL(ctx, cfg)
 	setWS(ctx, cfg)
+	setBinary(ctx, cfg)
+	setRPCAuth(ctx, cfg)
+	setRPCLimits(ctx, cfg)
+
+	if ctx.GlobalIsSet(RPCBatchItemLimitFlag.Name) {
+		cfg.RPCBatchItemLimit = ctx.GlobalInt(RPCBatchItemLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCResponseSizeLimitFlag.Name) {
+		cfg.RPCResponseSizeLimit = ctx.GlobalInt(RPCResponseSizeLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCSlowRequestThresholdFlag.Name) {
+		cfg.RPCSlowRequestThreshold = ctx.GlobalDuration(RPCSlowRequestThresholdFlag.Name)
+	}
 	setNodeUserIdent(ctx, cfg)
 
 	switch {
@@ -985,6 +1259,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolJournalFlag.Name) {
 		cfg.Journal = ctx.GlobalString(TxPoolJournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolRemoteJournalFlag.Name) {
+		cfg.RemoteJournal = ctx.GlobalString(TxPoolRemoteJournalFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
@@ -1009,6 +1286,15 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolMaxPerContractFlag.Name) {
+		cfg.Policy.MaxPerContract = ctx.GlobalUint64(TxPoolMaxPerContractFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolPeerRateFlag.Name) {
+		cfg.Policy.PeerRate = ctx.GlobalFloat64(TxPoolPeerRateFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolPeerBurstFlag.Name) {
+		cfg.Policy.PeerBurst = ctx.GlobalUint64(TxPoolPeerBurstFlag.Name)
+	}
 }
 
 func setEthash(ctx *cli.Context, cfg *eth.Config) {
@@ -1122,6 +1408,31 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 
+	if ctx.GlobalIsSet(ReceiptsHistoryFlag.Name) {
+		cfg.ReceiptsHistory = ctx.GlobalUint64(ReceiptsHistoryFlag.Name)
+	}
+	if ctx.GlobalIsSet(TraceIndexFlag.Name) {
+		cfg.TraceIndex = ctx.GlobalBool(TraceIndexFlag.Name)
+	}
+	if ctx.GlobalIsSet(LogIndexFlag.Name) {
+		cfg.LogIndex = ctx.GlobalBool(LogIndexFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCLogRangeLimitFlag.Name) {
+		cfg.LogQueryRangeLimit = ctx.GlobalUint64(RPCLogRangeLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCLogResultLimitFlag.Name) {
+		cfg.LogQueryResultLimit = ctx.GlobalInt(RPCLogResultLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(SafeDepthFlag.Name) {
+		cfg.SafeDepth = ctx.GlobalUint64(SafeDepthFlag.Name)
+	}
+	if ctx.GlobalIsSet(FinalizedDepthFlag.Name) {
+		cfg.FinalizedDepth = ctx.GlobalUint64(FinalizedDepthFlag.Name)
+	}
+	if cfg.SafeDepth > cfg.FinalizedDepth {
+		Fatalf("--%s must not exceed --%s", SafeDepthFlag.Name, FinalizedDepthFlag.Name)
+	}
+
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
@@ -1223,6 +1534,29 @@ func RegisterDashboardService(stack *node.Node, cfg *dashboard.Config, commit st
 	})
 }
 
+// RegisterGraphQLService adds a GraphQL server serving the chain data of the
+// full or light Simplechain service to the given node, enforcing the log query
+// limits of the given configuration.
+func RegisterGraphQLService(stack *node.Node, cfg *eth.Config, endpoint string, cors, vhosts []string, timeouts rpc.HTTPTimeouts) {
+	logLimits := filters.Config{LogRangeLimit: cfg.LogQueryRangeLimit, LogResultLimit: cfg.LogQueryResultLimit}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var backend ethapi.Backend
+		var fullNode *eth.Simplechain
+		if err := ctx.Service(&fullNode); err == nil {
+			backend = fullNode.APIBackend
+		} else {
+			var lightNode *les.LightSimplechain
+			if err := ctx.Service(&lightNode); err != nil {
+				return nil, err
+			}
+			backend = lightNode.ApiBackend
+		}
+		return graphql.New(backend, logLimits, endpoint, cors, vhosts, timeouts)
+	}); err != nil {
+		Fatalf("Failed to register the GraphQL service: %v", err)
+	}
+}
+
 // RegisterShhService configures Whisper and adds it to the given node.
 func RegisterShhService(stack *node.Node, cfg *whisper.Config) {
 	if err := stack.Register(func(n *node.ServiceContext) (node.Service, error) {
@@ -1338,6 +1672,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
 		TrieNodeLimit: eth.DefaultConfig.TrieCache,
 		TrieTimeLimit: eth.DefaultConfig.TrieTimeout,
+		ReceiptsLimit: ctx.GlobalUint64(ReceiptsHistoryFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
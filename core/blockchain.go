@@ -70,6 +70,7 @@ type CacheConfig struct {
 	Disabled      bool          // Whether to disable trie write caching (archive node)
 	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
 	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	ReceiptsLimit uint64        // Number of recent blocks to retain receipts for (0 = retain all)
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -117,6 +118,8 @@ type BlockChain struct {
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
 	futureBlocks *lru.Cache     // future blocks are blocks added for later processing
 
+	receiptsCache *lru.Cache // Cache for the most recently regenerated pruned receipts
+
 	quit    chan struct{} // blockchain quit channel
 	running int32         // running must be called atomically
 	// procInterrupt must be atomically called
@@ -146,21 +149,23 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	blockCache, _ := lru.New(blockCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
 	badBlocks, _ := lru.New(badBlockLimit)
+	receiptsCache, _ := lru.New(receiptsCacheLimit)
 
 	bc := &BlockChain{
-		chainConfig:  chainConfig,
-		cacheConfig:  cacheConfig,
-		db:           db,
-		triegc:       prque.New(),
-		stateCache:   state.NewDatabase(db),
-		quit:         make(chan struct{}),
-		bodyCache:    bodyCache,
-		bodyRLPCache: bodyRLPCache,
-		blockCache:   blockCache,
-		futureBlocks: futureBlocks,
-		engine:       engine,
-		vmConfig:     vmConfig,
-		badBlocks:    badBlocks,
+		chainConfig:   chainConfig,
+		cacheConfig:   cacheConfig,
+		db:            db,
+		triegc:        prque.New(),
+		stateCache:    state.NewDatabase(db),
+		quit:          make(chan struct{}),
+		bodyCache:     bodyCache,
+		bodyRLPCache:  bodyRLPCache,
+		blockCache:    blockCache,
+		futureBlocks:  futureBlocks,
+		receiptsCache: receiptsCache,
+		engine:        engine,
+		vmConfig:      vmConfig,
+		badBlocks:     badBlocks,
 	}
 	bc.SetValidator(NewBlockValidator(chainConfig, bc, engine))
 	bc.SetProcessor(NewStateProcessor(chainConfig, bc, engine))
@@ -308,6 +313,10 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	rawdb.WriteHeadBlockHash(bc.db, currentBlock.Hash())
 	rawdb.WriteHeadFastBlockHash(bc.db, currentFastBlock.Hash())
 
+	// Blocks reimported above the new head will store their receipts again
+	bc.rewindReceiptsTail(currentBlock.NumberU64() + 1)
+	bc.receiptsCache.Purge()
+
 	return bc.loadLastState()
 }
 
@@ -600,12 +609,32 @@ func (bc *BlockChain) GetBlockByNumber(number uint64) *types.Block {
 }
 
 // GetReceiptsByHash retrieves the receipts for all transactions in a given block.
+// If the receipts were pruned, they are regenerated by re-executing the block.
 func (bc *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
 	number := rawdb.ReadHeaderNumber(bc.db, hash)
 	if number == nil {
 		return nil
 	}
-	return rawdb.ReadReceipts(bc.db, hash, *number)
+	if receipts := rawdb.ReadReceipts(bc.db, hash, *number); receipts != nil {
+		return receipts
+	}
+	if bc.cacheConfig.ReceiptsLimit == 0 || *number >= rawdb.ReadReceiptsTail(bc.db) {
+		return nil
+	}
+	if cached, ok := bc.receiptsCache.Get(hash); ok {
+		return cached.(types.Receipts)
+	}
+	block := bc.GetBlock(hash, *number)
+	if block == nil {
+		return nil
+	}
+	receipts, err := bc.regenerateReceipts(block)
+	if err != nil {
+		log.Debug("Failed to regenerate pruned receipts", "number", *number, "hash", hash, "err", err)
+		return nil
+	}
+	bc.receiptsCache.Add(hash, receipts)
+	return receipts
 }
 
 // GetBlocksFromHash returns the block corresponding to hash and up to n-1 ancestors.
@@ -988,6 +1017,7 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	// Set new head.
 	if status == CanonStatTy {
 		bc.insert(block)
+		bc.pruneReceipts(block.NumberU64())
 	}
 	bc.futureBlocks.Remove(block.Hash())
 	return status, nil
@@ -1331,6 +1361,10 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	} else {
 		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "newnum", newBlock.Number(), "newhash", newBlock.Hash())
 	}
+	// The receipts of the new chain are stored, even below the pruned range
+	if commonBlock != nil {
+		bc.rewindReceiptsTail(commonBlock.NumberU64() + 1)
+	}
 	// Insert the new chain, taking care of the proper incremental order
 	var addedTxs types.Transactions
 	for i := len(newChain) - 1; i >= 0; i-- {
@@ -0,0 +1,170 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/core/rawdb"
+	"github.com/simplechain-org/go-simplechain/core/state"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/log"
+)
+
+const (
+	receiptsCacheLimit = 32   // Number of regenerated receipt sets to keep in memory
+	receiptsPruneBatch = 1024 // Maximum number of blocks to prune receipts of in one go
+	receiptsReexec     = 1024 // Maximum number of blocks to re-execute to find a usable state
+)
+
+// errReceiptsMismatch is returned if the receipts regenerated for a block don't
+// match the receipt root committed to in its header.
+var errReceiptsMismatch = errors.New("regenerated receipts mismatch")
+
+// pruneReceipts deletes the receipts of canonical blocks falling out of the
+// configured retention window ending at head. To avoid stalling block import
+// when pruning gets enabled on an existing database, at most receiptsPruneBatch
+// blocks are processed per invocation.
+func (bc *BlockChain) pruneReceipts(head uint64) {
+	limit := bc.cacheConfig.ReceiptsLimit
+	if limit == 0 || head < limit {
+		return
+	}
+	var (
+		tail = rawdb.ReadReceiptsTail(bc.db)
+		end  = head - limit + 1
+	)
+	if tail >= end {
+		return
+	}
+	if end-tail > receiptsPruneBatch {
+		end = tail + receiptsPruneBatch
+	}
+	batch := bc.db.NewBatch()
+	for number := tail; number < end; number++ {
+		if hash := rawdb.ReadCanonicalHash(bc.db, number); hash != (common.Hash{}) {
+			rawdb.DeleteReceipts(batch, hash, number)
+		}
+	}
+	rawdb.WriteReceiptsTail(batch, end)
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to prune receipts", "from", tail, "to", end, "err", err)
+		return
+	}
+	log.Debug("Pruned historical receipts", "from", tail, "to", end)
+}
+
+// rewindReceiptsTail moves the receipts tail back to the given block if it was
+// past it, as the receipts of the canonical blocks from there on are stored anew
+// after a rewind or a reorg below the tail. They are pruned again as the
+// retention window moves past them.
+func (bc *BlockChain) rewindReceiptsTail(number uint64) {
+	if tail := rawdb.ReadReceiptsTail(bc.db); tail > number {
+		rawdb.WriteReceiptsTail(bc.db, number)
+		log.Debug("Rewound receipts tail", "from", tail, "to", number)
+	}
+}
+
+// GetStoredReceiptsByHash retrieves the receipts for all transactions in a given
+// block from the database only, without regenerating pruned ones.
+func (bc *BlockChain) GetStoredReceiptsByHash(hash common.Hash) types.Receipts {
+	number := rawdb.ReadHeaderNumber(bc.db, hash)
+	if number == nil {
+		return nil
+	}
+	return rawdb.ReadReceipts(bc.db, hash, *number)
+}
+
+// regenerateReceipts recreates the pruned receipts of a block by re-executing it
+// on top of the nearest ancestor state still available.
+func (bc *BlockChain) regenerateReceipts(block *types.Block) (types.Receipts, error) {
+	if len(block.Transactions()) == 0 {
+		return types.Receipts{}, nil
+	}
+	parent := bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent block #%d not found", block.NumberU64()-1)
+	}
+	statedb, err := bc.StateAt(parent.Root())
+	if err != nil {
+		// Parent state is gone, reexecute from the closest available ancestor
+		if statedb, err = bc.reexecState(parent); err != nil {
+			return nil, err
+		}
+	}
+	receipts, _, _, err := bc.processor.Process(block, statedb, bc.vmConfig)
+	if err != nil {
+		return nil, err
+	}
+	if hash := types.DeriveSha(receipts); hash != block.ReceiptHash() {
+		return nil, errReceiptsMismatch
+	}
+	return receipts, nil
+}
+
+// reexecState regenerates the state of the given block by replaying at most
+// receiptsReexec blocks on top of the nearest ancestor with state available.
+// A throwaway state database is used to avoid polluting the live trie cache.
+func (bc *BlockChain) reexecState(block *types.Block) (*state.StateDB, error) {
+	var (
+		database = state.NewDatabase(bc.db)
+		replay   = []*types.Block{block}
+		statedb  *state.StateDB
+	)
+	for i := 0; i < receiptsReexec; i++ {
+		parent := bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
+		if parent == nil {
+			break
+		}
+		if db, err := state.New(parent.Root(), database); err == nil {
+			statedb = db
+			break
+		}
+		block = parent
+		replay = append(replay, block)
+	}
+	if statedb == nil {
+		return nil, errors.New("required historical state unavailable")
+	}
+	var (
+		start = time.Now()
+		proot common.Hash
+	)
+	for i := len(replay) - 1; i >= 0; i-- {
+		block := replay[i]
+		if _, _, _, err := bc.processor.Process(block, statedb, bc.vmConfig); err != nil {
+			return nil, err
+		}
+		root, err := statedb.Commit(bc.chainConfig.IsEIP158(block.Number()))
+		if err != nil {
+			return nil, err
+		}
+		if err := statedb.Reset(root); err != nil {
+			return nil, err
+		}
+		database.TrieDB().Reference(root, common.Hash{})
+		if proot != (common.Hash{}) {
+			database.TrieDB().Dereference(proot)
+		}
+		proot = root
+	}
+	log.Debug("Regenerated state for receipts", "number", replay[0].NumberU64(), "blocks", len(replay), "elapsed", common.PrettyDuration(time.Since(start)))
+	return statedb, nil
+}
@@ -1481,3 +1481,244 @@ func BenchmarkBlockChain_1x1000Executions(b *testing.B) {
 
 	benchmarkLargeNumberOfValueToNonexisting(b, numTxs, numBlocks, recipientFn, dataFn)
 }
+
+// Tests that receipts falling out of the retention window get pruned from the
+// database and are regenerated on demand, both from in-memory recent state and
+// by re-executing blocks on top of older persisted state.
+func TestReceiptsPruning(t *testing.T) {
+	var (
+		gendb   = ethdb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: funds}},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	blocks, receipts := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 2*triesInMemory, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	db := ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	cache := &CacheConfig{
+		TrieNodeLimit: 256 * 1024 * 1024,
+		TrieTimeLimit: 5 * time.Minute,
+		ReceiptsLimit: 16,
+	}
+	chain, _ := NewBlockChain(db, cache, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to process block %d: %v", n, err)
+	}
+	head := chain.CurrentBlock().NumberU64()
+	if tail := rawdb.ReadReceiptsTail(db); tail != head-cache.ReceiptsLimit+1 {
+		t.Fatalf("receipts tail mismatch: have %d, want %d", tail, head-cache.ReceiptsLimit+1)
+	}
+	for i, block := range blocks {
+		stored := rawdb.ReadReceipts(db, block.Hash(), block.NumberU64())
+		if pruned := block.NumberU64() <= head-cache.ReceiptsLimit; pruned != (stored == nil) {
+			t.Errorf("block #%d: pruned mismatch: have %v, want %v", block.NumberU64(), stored == nil, pruned)
+		}
+		if stored != nil && chain.GetStoredReceiptsByHash(block.Hash()) == nil {
+			t.Errorf("block #%d: stored receipts not retrievable", block.NumberU64())
+		}
+		if stored == nil && chain.GetStoredReceiptsByHash(block.Hash()) != nil {
+			t.Errorf("block #%d: pruned receipts retrieved from storage", block.NumberU64())
+		}
+		// Regenerate both recent (state in memory) and ancient (reexec) receipts
+		if i != 1 && i != len(blocks)-int(cache.ReceiptsLimit)-1 {
+			continue
+		}
+		have := chain.GetReceiptsByHash(block.Hash())
+		if have == nil {
+			t.Fatalf("block #%d: failed to retrieve receipts", block.NumberU64())
+		}
+		if hash, want := types.DeriveSha(have), types.DeriveSha(receipts[i]); hash != want {
+			t.Errorf("block #%d: receipts hash mismatch: have %x, want %x", block.NumberU64(), hash, want)
+		}
+	}
+	// Ensure regenerated receipts are cached instead of being recomputed
+	if _, ok := chain.receiptsCache.Get(blocks[1].Hash()); !ok {
+		t.Errorf("regenerated receipts not cached")
+	}
+}
+
+// Tests that the receipts tail is moved back when the chain is rewound or
+// reorganised below it, and that the receipts stored anew are pruned again.
+func TestReceiptsPruningRewind(t *testing.T) {
+	var (
+		gendb   = ethdb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: funds}},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	generate := func(parent *types.Block, n int, coinbase common.Address) []*types.Block {
+		blocks, _ := GenerateChain(gspec.Config, parent, ethash.NewFaker(), gendb, n, func(i int, block *BlockGen) {
+			block.SetCoinbase(coinbase)
+			tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+			if err != nil {
+				panic(err)
+			}
+			block.AddTx(tx)
+		})
+		return blocks
+	}
+	blocks := generate(genesis, 64, common.Address{0x01})
+
+	db := ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	cache := &CacheConfig{
+		TrieNodeLimit: 256 * 1024 * 1024,
+		TrieTimeLimit: 5 * time.Minute,
+		ReceiptsLimit: 16,
+	}
+	chain, _ := NewBlockChain(db, cache, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	// Checks that exactly the canonical receipts below the tail are pruned
+	check := func(stage string, tail uint64) {
+		if have := rawdb.ReadReceiptsTail(db); have != tail {
+			t.Fatalf("%s: receipts tail mismatch: have %d, want %d", stage, have, tail)
+		}
+		for number := uint64(1); number <= chain.CurrentBlock().NumberU64(); number++ {
+			block := chain.GetBlockByNumber(number)
+			if pruned, stored := number < tail, rawdb.ReadReceipts(db, block.Hash(), number) != nil; pruned == stored {
+				t.Errorf("%s: block #%d: stored mismatch: have %v, want %v", stage, number, stored, !pruned)
+			}
+		}
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to process block %d: %v", n, err)
+	}
+	check("import", 64-16+1)
+
+	// Rewind below the tail and reimport the dropped blocks
+	if err := chain.SetHead(30); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	check("rewind", 31)
+
+	if n, err := chain.InsertChain(blocks[30:]); err != nil {
+		t.Fatalf("failed to reimport block %d: %v", n, err)
+	}
+	check("reimport", 64-16+1)
+
+	// Reorganise to a longer fork branching off below the tail
+	fork := generate(blocks[19], 64-20+8, common.Address{0x02})
+	if n, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to import fork block %d: %v", n, err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != fork[len(fork)-1].Hash() {
+		t.Fatalf("fork not canonical: head #%d [%x]", head.NumberU64(), head.Hash())
+	}
+	check("reorg", 72-16+1)
+}
+
+// Tests that chain verification detects inconsistencies in the database, repairs
+// the derivable ones and rewinds the chain below the unrecoverable ones.
+func TestVerifyChainRepair(t *testing.T) {
+	var (
+		gendb   = ethdb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: funds}},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 10, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	db := ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	chain, _ := NewBlockChain(db, &CacheConfig{Disabled: true}, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to process block %d: %v", n, err)
+	}
+	chain.Stop()
+
+	// A healthy chain should verify without issues
+	res, err := VerifyChain(db, false)
+	if err != nil {
+		t.Fatalf("failed to verify chain: %v", err)
+	}
+	if len(res.Issues) != 0 || res.Consistent != 10 {
+		t.Fatalf("healthy chain mismatch: issues %v, consistent %d", res.Issues, res.Consistent)
+	}
+	// Corrupt the database with both derivable and missing data
+	rawdb.DeleteCanonicalHash(db, 3)
+	rawdb.DeleteTxLookupEntry(db, blocks[3].Transactions()[0].Hash())
+	rawdb.DeleteTd(db, blocks[4].Hash(), blocks[4].NumberU64())
+	rawdb.DeleteBody(db, blocks[7].Hash(), blocks[7].NumberU64())
+
+	res, err = VerifyChain(db, false)
+	if err != nil {
+		t.Fatalf("failed to verify chain: %v", err)
+	}
+	issues := map[ChainIssueKind]uint64{
+		IssueCanonicalHash:   3,
+		IssueTxLookup:        4,
+		IssueTotalDifficulty: 5,
+		IssueMissingBody:     8,
+	}
+	if len(res.Issues) != len(issues) {
+		t.Fatalf("issue count mismatch: have %v, want %d", res.Issues, len(issues))
+	}
+	for _, issue := range res.Issues {
+		if number, ok := issues[issue.Kind]; !ok || number != issue.Number || issue.Fixed {
+			t.Errorf("unexpected issue: %+v", issue)
+		}
+	}
+	if res.Consistent != 7 || res.Rewound {
+		t.Fatalf("consistency mismatch: have #%d (rewound %v), want #7", res.Consistent, res.Rewound)
+	}
+	// Repair the chain and ensure it's consistent afterwards
+	if res, err = VerifyChain(db, true); err != nil {
+		t.Fatalf("failed to repair chain: %v", err)
+	}
+	if res.Unfixed() != 0 || !res.Rewound {
+		t.Fatalf("repair mismatch: issues %v, rewound %v", res.Issues, res.Rewound)
+	}
+	if res, err = VerifyChain(db, false); err != nil {
+		t.Fatalf("failed to verify repaired chain: %v", err)
+	}
+	if len(res.Issues) != 0 || res.Head != 7 || res.Consistent != 7 {
+		t.Fatalf("repaired chain mismatch: issues %v, head #%d, consistent #%d", res.Issues, res.Head, res.Consistent)
+	}
+	chain, _ = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	if head := chain.CurrentBlock(); head.Hash() != blocks[6].Hash() {
+		t.Errorf("head block mismatch: have #%d, want #7", head.NumberU64())
+	}
+	if tx, _, _, _ := rawdb.ReadTransaction(db, blocks[3].Transactions()[0].Hash()); tx == nil {
+		t.Errorf("transaction lookup not rebuilt")
+	}
+	if td := chain.GetTdByHash(blocks[4].Hash()); td == nil {
+		t.Errorf("total difficulty not rebuilt")
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/core/rawdb"
+	"github.com/simplechain-org/go-simplechain/core/state"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/ethdb"
+	"github.com/simplechain-org/go-simplechain/log"
+)
+
+// ChainIssueKind is the type of inconsistency detected in a chain database.
+type ChainIssueKind int
+
+const (
+	IssueMissingHeader   ChainIssueKind = iota // Header of a canonical block is missing
+	IssueCanonicalHash                         // Canonical number to hash mapping is missing or wrong
+	IssueHeaderNumber                          // Hash to number mapping of a header is missing or wrong
+	IssueTotalDifficulty                       // Total difficulty of a block is missing or wrong
+	IssueMissingBody                           // Body of a canonical block is missing
+	IssueMissingReceipts                       // Receipts of a canonical block are missing
+	IssueTxLookup                              // Transaction lookup entry is missing or wrong
+	IssueMissingState                          // State of the head block is missing
+)
+
+// String implements fmt.Stringer.
+func (kind ChainIssueKind) String() string {
+	switch kind {
+	case IssueMissingHeader:
+		return "missing header"
+	case IssueCanonicalHash:
+		return "bad canonical hash"
+	case IssueHeaderNumber:
+		return "bad header number"
+	case IssueTotalDifficulty:
+		return "bad total difficulty"
+	case IssueMissingBody:
+		return "missing body"
+	case IssueMissingReceipts:
+		return "missing receipts"
+	case IssueTxLookup:
+		return "bad transaction lookup"
+	case IssueMissingState:
+		return "missing state"
+	default:
+		return fmt.Sprintf("unknown issue %d", int(kind))
+	}
+}
+
+// ChainIssue is a single inconsistency detected in a chain database.
+type ChainIssue struct {
+	Number uint64         // Number of the affected block
+	Hash   common.Hash    // Hash of the affected block
+	Kind   ChainIssueKind // Type of the inconsistency
+	Fixed  bool           // Whether the inconsistency was repaired in place
+}
+
+// ChainVerifyResult is the outcome of checking a chain database.
+type ChainVerifyResult struct {
+	Head       uint64       // Number of the head header the chain was checked from
+	Issues     []ChainIssue // Inconsistencies detected during the check
+	Consistent uint64       // Highest block with complete chain data and state available
+	Rewound    bool         // Whether the chain heads were rewound to the consistent block
+}
+
+// Unfixed returns the number of detected issues that were not repaired.
+func (res *ChainVerifyResult) Unfixed() int {
+	unfixed := 0
+	for _, issue := range res.Issues {
+		if !issue.Fixed {
+			unfixed++
+		}
+	}
+	return unfixed
+}
+
+// VerifyChain walks the canonical chain stored in the database from the head
+// header down to the genesis, checking headers, bodies, receipts, total
+// difficulties, transaction lookups and canonical hash mappings.
+//
+// If repair is requested, derivable data (canonical mappings, header numbers,
+// total difficulties and transaction lookups) is rebuilt in place, and if any
+// chain data or the head state is missing, the chain heads are rewound to the
+// highest block with complete data and state available.
+//
+// The database must not be in use by a running BlockChain.
+func VerifyChain(db ethdb.Database, repair bool) (*ChainVerifyResult, error) {
+	headHash := rawdb.ReadHeadHeaderHash(db)
+	if headHash == (common.Hash{}) {
+		return nil, errors.New("chain head unknown")
+	}
+	headNumber := rawdb.ReadHeaderNumber(db, headHash)
+	if headNumber == nil {
+		return nil, fmt.Errorf("chain head %x number unknown", headHash)
+	}
+	// Bodies and receipts are expected up to the full or fast block head, whichever is higher
+	var bodyHead uint64
+	for _, hash := range []common.Hash{rawdb.ReadHeadBlockHash(db), rawdb.ReadHeadFastBlockHash(db)} {
+		if number := rawdb.ReadHeaderNumber(db, hash); number != nil && *number > bodyHead {
+			bodyHead = *number
+		}
+	}
+	var (
+		res   = &ChainVerifyResult{Head: *headNumber}
+		tail  = rawdb.ReadReceiptsTail(db)
+		limit = *headNumber // Highest block below which all chain data is present
+		batch = db.NewBatch()
+
+		tdFrom    = *headNumber + 1 // Lowest block with missing or invalid total difficulty
+		childTd   *big.Int
+		childDiff *big.Int
+
+		start    = time.Now()
+		reported = time.Now()
+	)
+	report := func(number uint64, hash common.Hash, kind ChainIssueKind, fixed bool) {
+		log.Warn("Chain inconsistency detected", "number", number, "hash", hash, "issue", kind, "fixed", fixed)
+		res.Issues = append(res.Issues, ChainIssue{Number: number, Hash: hash, Kind: kind, Fixed: fixed})
+	}
+	hash := headHash
+	for number := *headNumber; ; number-- {
+		header := rawdb.ReadHeader(db, hash, number)
+		if header == nil {
+			// Chain is disconnected here, continue from the canonical mapping
+			report(number, hash, IssueMissingHeader, false)
+			if number == 0 {
+				return nil, errors.New("genesis header missing")
+			}
+			limit, childTd, childDiff = number-1, nil, nil
+			hash = rawdb.ReadCanonicalHash(db, number-1)
+			continue
+		}
+		if rawdb.ReadCanonicalHash(db, number) != hash {
+			if repair {
+				rawdb.WriteCanonicalHash(batch, hash, number)
+			}
+			report(number, hash, IssueCanonicalHash, repair)
+		}
+		if stored := rawdb.ReadHeaderNumber(db, hash); stored == nil || *stored != number {
+			if repair {
+				rawdb.WriteHeader(batch, header)
+			}
+			report(number, hash, IssueHeaderNumber, repair)
+		}
+		// Total difficulties are validated against the child, fixed in a second pass
+		td := rawdb.ReadTd(db, hash, number)
+		if td == nil {
+			tdFrom = number
+		} else if childTd != nil && new(big.Int).Add(td, childDiff).Cmp(childTd) != 0 {
+			tdFrom = number + 1
+		}
+		childTd, childDiff = td, header.Difficulty
+
+		// Verify the block data if it should be available
+		if number <= bodyHead {
+			if body := rawdb.ReadBody(db, hash, number); body == nil {
+				report(number, hash, IssueMissingBody, false)
+				if number > 0 {
+					limit = number - 1
+				}
+			} else {
+				block := types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles)
+				for i, tx := range block.Transactions() {
+					if lookup, blockNumber, index := rawdb.ReadTxLookupEntry(db, tx.Hash()); lookup != hash || blockNumber != number || index != uint64(i) {
+						if repair {
+							rawdb.WriteTxLookupEntries(batch, block)
+						}
+						report(number, hash, IssueTxLookup, repair)
+						break
+					}
+				}
+			}
+			if number > 0 && number >= tail && rawdb.ReadReceipts(db, hash, number) == nil {
+				report(number, hash, IssueMissingReceipts, false)
+				limit = number - 1
+			}
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return nil, err
+			}
+			batch.Reset()
+		}
+		if time.Since(reported) >= statsReportLimit {
+			log.Info("Verifying chain", "number", number, "issues", len(res.Issues), "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+		if number == 0 {
+			break
+		}
+		hash = header.ParentHash
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	batch.Reset()
+
+	// Recompute the total difficulties from the lowest invalid one upwards
+	if tdFrom <= *headNumber {
+		if tdFrom == 0 {
+			return nil, errors.New("genesis total difficulty missing")
+		}
+		var (
+			hash  = rawdb.ReadCanonicalHash(db, tdFrom)
+			fixed = false
+		)
+		if repair {
+			if td := rawdb.ReadTd(db, rawdb.ReadCanonicalHash(db, tdFrom-1), tdFrom-1); td != nil {
+				for number := tdFrom; number <= *headNumber; number++ {
+					hash := rawdb.ReadCanonicalHash(db, number)
+					header := rawdb.ReadHeader(db, hash, number)
+					if header == nil {
+						break
+					}
+					td = new(big.Int).Add(td, header.Difficulty)
+					rawdb.WriteTd(batch, hash, number, td)
+				}
+				if err := batch.Write(); err != nil {
+					return nil, err
+				}
+				fixed = true
+			}
+		}
+		report(tdFrom, hash, IssueTotalDifficulty, fixed)
+	}
+	// Find the highest block with complete chain data and state available
+	var (
+		target   = limit
+		database = state.NewDatabase(db)
+	)
+	if target > bodyHead {
+		target = bodyHead
+	}
+	for ; ; target-- {
+		hash := rawdb.ReadCanonicalHash(db, target)
+		if header := rawdb.ReadHeader(db, hash, target); header != nil {
+			if _, err := state.New(header.Root, database); err == nil {
+				break
+			}
+			if target == bodyHead {
+				report(target, hash, IssueMissingState, false)
+			}
+		}
+		if target == 0 {
+			return nil, errors.New("genesis state missing")
+		}
+	}
+	res.Consistent = target
+
+	// Rewind the chain heads if data or state is missing above the consistent block
+	if repair && (limit < *headNumber || target < bodyHead) {
+		hash := rawdb.ReadCanonicalHash(db, target)
+		for number := *headNumber; number > target; number-- {
+			rawdb.DeleteCanonicalHash(db, number)
+		}
+		rawdb.WriteHeadHeaderHash(db, hash)
+		rawdb.WriteHeadBlockHash(db, hash)
+		rawdb.WriteHeadFastBlockHash(db, hash)
+
+		log.Warn("Rewound chain to consistent block", "number", target, "hash", hash)
+		res.Rewound = true
+
+		// Anything above the new head was dropped from the canonical chain
+		for i := range res.Issues {
+			if res.Issues[i].Number > target {
+				res.Issues[i].Fixed = true
+			}
+		}
+	}
+	return res, nil
+}
@@ -24,6 +24,10 @@ import (
 // NewTxsEvent is posted when a batch of transactions enter the transaction pool.
 type NewTxsEvent struct{ Txs []*types.Transaction }
 
+// TxPoolEvent is posted when a batch of transactions enter, move within or leave
+// the transaction pool, detailing the reason of each individual change.
+type TxPoolEvent struct{ Changes []TxChange }
+
 // PendingLogsEvent is posted pre mining and notifies of pending logs.
 type PendingLogsEvent struct {
 	Logs []*types.Log
@@ -271,6 +271,24 @@ func DeleteTd(db DatabaseDeleter, hash common.Hash, number uint64) {
 	}
 }
 
+// ReadReceiptsTail retrieves the number of the first canonical block whose
+// receipts were not pruned.
+func ReadReceiptsTail(db DatabaseReader) uint64 {
+	data, _ := db.Get(receiptsTailKey)
+	if len(data) == 0 {
+		return 0
+	}
+	return new(big.Int).SetBytes(data).Uint64()
+}
+
+// WriteReceiptsTail stores the number of the first canonical block whose
+// receipts were not pruned.
+func WriteReceiptsTail(db DatabaseWriter, number uint64) {
+	if err := db.Put(receiptsTailKey, new(big.Int).SetUint64(number).Bytes()); err != nil {
+		log.Crit("Failed to store receipts tail", "err", err)
+	}
+}
+
 // ReadReceipts retrieves all the transaction receipts belonging to a block.
 func ReadReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
 	// Retrieve the flattened receipt slice
@@ -17,6 +17,8 @@
 package rawdb
 
 import (
+	"encoding/binary"
+
 	"github.com/simplechain-org/go-simplechain/common"
 	"github.com/simplechain-org/go-simplechain/core/types"
 	"github.com/simplechain-org/go-simplechain/log"
@@ -105,3 +107,51 @@ func WriteBloomBits(db DatabaseWriter, bit uint, section uint64, head common.Has
 		log.Crit("Failed to store bloom bits", "err", err)
 	}
 }
+
+// ReadBlockTraces retrieves the encoded call traces of all the transactions in a
+// block, as stored by the trace indexer. Nil is returned if the block was not
+// indexed.
+func ReadBlockTraces(db DatabaseReader, hash common.Hash, number uint64) []byte {
+	data, _ := db.Get(blockTracesKey(number, hash))
+	return data
+}
+
+// WriteBlockTraces stores the encoded call traces of all the transactions in a
+// block.
+func WriteBlockTraces(db DatabaseWriter, hash common.Hash, number uint64, traces []byte) {
+	if err := db.Put(blockTracesKey(number, hash), traces); err != nil {
+		log.Crit("Failed to store block traces", "err", err)
+	}
+}
+
+// DeleteBlockTraces removes the call traces associated with a block.
+func DeleteBlockTraces(db DatabaseDeleter, hash common.Hash, number uint64) {
+	if err := db.Delete(blockTracesKey(number, hash)); err != nil {
+		log.Crit("Failed to delete block traces", "err", err)
+	}
+}
+
+// ReadLogIndex retrieves the numbers of the blocks within the given log index
+// section that contain logs emitted by the address with the given first topic.
+// The zero address and the zero hash act as wildcards, as the indexer stores
+// entries for each of them separately.
+func ReadLogIndex(db DatabaseReader, address common.Address, topic common.Hash, section uint64, head common.Hash) []uint64 {
+	data, _ := db.Get(logIndexKey(address, topic, section, head))
+	numbers := make([]uint64, 0, len(data)/8)
+	for i := 0; i+8 <= len(data); i += 8 {
+		numbers = append(numbers, binary.BigEndian.Uint64(data[i:]))
+	}
+	return numbers
+}
+
+// WriteLogIndex stores the numbers of the blocks within the given log index
+// section that contain logs matching the address and first topic.
+func WriteLogIndex(db DatabaseWriter, address common.Address, topic common.Hash, section uint64, head common.Hash, numbers []uint64) {
+	data := make([]byte, 0, 8*len(numbers))
+	for _, number := range numbers {
+		data = append(data, encodeBlockNumber(number)...)
+	}
+	if err := db.Put(logIndexKey(address, topic, section, head), data); err != nil {
+		log.Crit("Failed to store log index", "err", err)
+	}
+}
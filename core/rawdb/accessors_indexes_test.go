@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/simplechain-org/go-simplechain/common"
@@ -66,3 +67,25 @@ func TestLookupStorage(t *testing.T) {
 		}
 	}
 }
+
+// Tests that log index entries can be stored and retrieved.
+func TestLogIndexStorage(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	var (
+		address = common.BytesToAddress([]byte{0x11})
+		topic   = common.BytesToHash([]byte{0x22})
+		head    = common.BytesToHash([]byte{0x33})
+	)
+	if numbers := ReadLogIndex(db, address, topic, 1, head); len(numbers) != 0 {
+		t.Fatalf("non existent log index returned: %v", numbers)
+	}
+	WriteLogIndex(db, address, topic, 1, head, []uint64{4096, 5000, 8191})
+
+	if numbers := ReadLogIndex(db, address, topic, 1, head); !reflect.DeepEqual(numbers, []uint64{4096, 5000, 8191}) {
+		t.Fatalf("log index mismatch: have %v, want %v", numbers, []uint64{4096, 5000, 8191})
+	}
+	if numbers := ReadLogIndex(db, address, topic, 1, common.Hash{}); len(numbers) != 0 {
+		t.Fatalf("log index of different section head returned: %v", numbers)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"math/big"
 
 	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/core/types"
 	"github.com/simplechain-org/go-simplechain/crypto"
 	"github.com/simplechain-org/go-simplechain/rlp"
 )
@@ -197,6 +198,18 @@ func (self *stateObject) setState(key, value common.Hash) {
 	self.dirtyStorage[key] = value
 }
 
+// setStorage discards the entire storage of the account, replacing it with the
+// given slots. The change is not journalled and can't be reverted.
+func (self *stateObject) setStorage(storage map[common.Hash]common.Hash) {
+	self.data.Root = types.EmptyRootHash
+	self.trie = nil
+	self.cachedStorage = make(Storage)
+	self.dirtyStorage = make(Storage)
+	for key, value := range storage {
+		self.setState(key, value)
+	}
+}
+
 // updateTrie writes cached storage modifications into the object's storage trie.
 func (self *stateObject) updateTrie(db Database) Trie {
 	tr := self.getTrie(db)
@@ -32,7 +32,10 @@ import (
 
 	"github.com/simplechain-org/go-simplechain/common"
 	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/crypto"
 	"github.com/simplechain-org/go-simplechain/ethdb"
+	"github.com/simplechain-org/go-simplechain/rlp"
+	"github.com/simplechain-org/go-simplechain/trie"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -433,3 +436,83 @@ func TestCopyOfCopy(t *testing.T) {
 		t.Fatalf("2nd copy fail, expected 42, got %v", got)
 	}
 }
+
+// Tests that account and storage proofs generated by the state verify against
+// the state and storage roots.
+func TestProofs(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+
+	for i := byte(1); i < 64; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		state.AddBalance(addr, big.NewInt(int64(11*i)))
+		state.SetNonce(addr, uint64(42*i))
+		if i%4 == 0 {
+			state.SetState(addr, common.Hash{i}, common.Hash{i, i})
+			state.SetState(addr, common.Hash{i, 1}, common.BigToHash(big.NewInt(int64(i))))
+		}
+	}
+	root, _ := state.Commit(false)
+	state, _ = New(root, state.Database())
+
+	// toProofDb inserts proof nodes into a hash indexed database for verification
+	toProofDb := func(proof [][]byte) *ethdb.MemDatabase {
+		db := ethdb.NewMemDatabase()
+		for _, node := range proof {
+			db.Put(crypto.Keccak256(node), node)
+		}
+		return db
+	}
+	for i := byte(1); i < 64; i++ {
+		addr := common.BytesToAddress([]byte{i})
+
+		proof, err := state.GetProof(addr)
+		if err != nil {
+			t.Fatalf("account %x: failed to generate proof: %v", addr, err)
+		}
+		val, _, err := trie.VerifyProof(root, crypto.Keccak256(addr.Bytes()), toProofDb(proof))
+		if err != nil {
+			t.Fatalf("account %x: failed to verify proof: %v", addr, err)
+		}
+		var account Account
+		if err := rlp.DecodeBytes(val, &account); err != nil {
+			t.Fatalf("account %x: failed to decode proven account: %v", addr, err)
+		}
+		if account.Nonce != state.GetNonce(addr) || account.Balance.Cmp(state.GetBalance(addr)) != 0 {
+			t.Errorf("account %x: proven account mismatch: have %d/%v, want %d/%v", addr, account.Nonce, account.Balance, state.GetNonce(addr), state.GetBalance(addr))
+		}
+		if i%4 != 0 {
+			continue
+		}
+		for _, key := range []common.Hash{{i}, {i, 1}, {i, 2}} {
+			proof, err := state.GetStorageProof(addr, key)
+			if err != nil {
+				t.Fatalf("account %x, slot %x: failed to generate proof: %v", addr, key, err)
+			}
+			val, _, err := trie.VerifyProof(account.Root, crypto.Keccak256(key.Bytes()), toProofDb(proof))
+			if err != nil {
+				t.Fatalf("account %x, slot %x: failed to verify proof: %v", addr, key, err)
+			}
+			var content []byte
+			if val != nil {
+				if err := rlp.DecodeBytes(val, &content); err != nil {
+					t.Fatalf("account %x, slot %x: failed to decode proven value: %v", addr, key, err)
+				}
+			}
+			if have, want := common.BytesToHash(content), state.GetState(addr, key); have != want {
+				t.Errorf("account %x, slot %x: proven value mismatch: have %x, want %x", addr, key, have, want)
+			}
+		}
+	}
+	// Ensure missing accounts are proven absent
+	missing := common.BytesToAddress([]byte{0xff})
+	proof, err := state.GetProof(missing)
+	if err != nil {
+		t.Fatalf("failed to generate absence proof: %v", err)
+	}
+	if val, _, err := trie.VerifyProof(root, crypto.Keccak256(missing.Bytes()), toProofDb(proof)); err != nil || val != nil {
+		t.Errorf("absence proof mismatch: value %x, err %v", val, err)
+	}
+	if _, err := state.GetStorageProof(missing, common.Hash{}); err == nil {
+		t.Errorf("storage proof of missing account succeeded")
+	}
+}
@@ -42,14 +42,20 @@ func (*devNull) Close() error                      { return nil }
 
 // txJournal is a rotating log of transactions with the aim of storing locally
 // created transactions to allow non-executed ones to survive node restarts.
+//
+// The same format is used to snapshot the remote transactions of the pool, in
+// which case the journal is only ever regenerated, never appended to.
 type txJournal struct {
+	kind   string         // Kind of transactions journaled (local or remote), for logging
 	path   string         // Filesystem path to store the transactions at
 	writer io.WriteCloser // Output stream to write new transactions into
 }
 
-// newTxJournal creates a new transaction journal to
-func newTxJournal(path string) *txJournal {
+// newTxJournal creates a new transaction journal to store the given kind of
+// transactions at the specified path.
+func newTxJournal(kind string, path string) *txJournal {
 	return &txJournal{
+		kind: kind,
 		path: path,
 	}
 }
@@ -111,7 +117,7 @@ func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
 			batch = batch[:0]
 		}
 	}
-	log.Info("Loaded local transaction journal", "transactions", total, "dropped", dropped)
+	log.Info("Loaded transaction journal", "kind", journal.kind, "transactions", total, "dropped", dropped)
 
 	return failure
 }
@@ -163,7 +169,7 @@ func (journal *txJournal) rotate(all map[common.Address]types.Transactions) erro
 		return err
 	}
 	journal.writer = sink
-	log.Info("Regenerated local transaction journal", "transactions", journaled, "accounts", len(all))
+	log.Info("Regenerated transaction journal", "kind", journal.kind, "transactions", journaled, "accounts", len(all))
 
 	return nil
 }
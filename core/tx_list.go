@@ -453,8 +453,8 @@ func (l *txPricedList) Cap(threshold *big.Int, local *accountSet) types.Transact
 			save = append(save, tx)
 			break
 		}
-		// Non stale transaction found, discard unless local
-		if local.containsTx(tx) {
+		// Non stale transaction found, discard unless local or private
+		if local.containsTx(tx) || l.all.IsPrivate(tx.Hash()) {
 			save = append(save, tx)
 		} else {
 			drop = append(drop, tx)
@@ -505,8 +505,8 @@ func (l *txPricedList) Discard(count int, local *accountSet) types.Transactions
 			l.stales--
 			continue
 		}
-		// Non stale transaction found, discard unless local
-		if local.containsTx(tx) {
+		// Non stale transaction found, discard unless local or private
+		if local.containsTx(tx) || l.all.IsPrivate(tx.Hash()) {
 			save = append(save, tx)
 		} else {
 			drop = append(drop, tx)
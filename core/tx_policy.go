@@ -0,0 +1,250 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/core/state"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/log"
+)
+
+var (
+	// ErrSenderDenied is returned if the sender of a transaction is not permitted
+	// to submit transactions by the pool's admission policy.
+	ErrSenderDenied = errors.New("sender not permitted")
+
+	// ErrRecipientDenied is returned if the recipient of a transaction is not
+	// permitted by the pool's admission policy.
+	ErrRecipientDenied = errors.New("recipient not permitted")
+
+	// ErrCreationDenied is returned if a contract creation is attempted by a sender
+	// that is not among the approved contract creators.
+	ErrCreationDenied = errors.New("contract creation not permitted")
+
+	// ErrContractLimit is returned if a transaction calls a contract which already
+	// has the maximum permitted number of transactions pooled against it.
+	ErrContractLimit = errors.New("too many pooled transactions for contract")
+
+	// ErrPeerRateLimit is returned if a remote peer exceeded the number of
+	// transactions it may relay to the pool within a time window.
+	ErrPeerRateLimit = errors.New("peer transaction rate exceeded")
+)
+
+// TxPolicyConfig are the operator configurable admission rules applied by the
+// transaction pool on top of the built-in validation rules.
+type TxPolicyConfig struct {
+	AllowSenders    []common.Address `json:"allowSenders"`    // If set, only transactions from these accounts are admitted
+	DenySenders     []common.Address `json:"denySenders"`     // Accounts whose transactions are always rejected
+	AllowRecipients []common.Address `json:"allowRecipients"` // If set, only transactions to these accounts are admitted
+	DenyRecipients  []common.Address `json:"denyRecipients"`  // Accounts to which transactions are always rejected
+	Creators        []common.Address `json:"creators"`        // If set, only these accounts may deploy contracts
+
+	MaxPerContract uint64 `json:"maxPerContract"` // Maximum number of pooled transactions calling a single contract (0 = unlimited)
+
+	PeerRate  float64 `json:"peerRate"`  // Remote transactions per second accepted from a single peer (0 = unlimited)
+	PeerBurst uint64  `json:"peerBurst"` // Maximum number of remote transactions a peer may relay in one burst
+}
+
+// sanitize checks the provided policy configurations and changes anything that's
+// unreasonable or unworkable.
+func (config TxPolicyConfig) sanitize() TxPolicyConfig {
+	conf := config
+	if conf.PeerRate < 0 {
+		log.Warn("Sanitizing invalid txpool peer rate", "provided", conf.PeerRate, "updated", 0)
+		conf.PeerRate = 0
+	}
+	if conf.PeerRate > 0 && conf.PeerBurst < 1 {
+		burst := uint64(math.Ceil(conf.PeerRate))
+		log.Warn("Sanitizing invalid txpool peer burst", "provided", conf.PeerBurst, "updated", burst)
+		conf.PeerBurst = burst
+	}
+	return conf
+}
+
+// TxPolicyContext is the view of the transaction pool handed to admission
+// policies when deciding whether a transaction is acceptable.
+type TxPolicyContext struct {
+	From  common.Address // Sender of the transaction being admitted
+	Local bool           // Whether the transaction was submitted locally
+	State *state.StateDB // State of the current chain head, must not be modified
+
+	pool *TxPool
+}
+
+// Pooled returns the number of transactions currently in the pool which have
+// the given account as their recipient.
+func (ctx *TxPolicyContext) Pooled(to common.Address) int {
+	return ctx.pool.all.Calls(to)
+}
+
+// TxPolicy is an admission rule consulted by the transaction pool after a
+// transaction passed the built-in validation. Returning a non-nil error rejects
+// the transaction with that error.
+//
+// Policies are invoked with the pool lock held and must not call back into the
+// pool.
+type TxPolicy interface {
+	Admit(tx *types.Transaction, ctx *TxPolicyContext) error
+}
+
+// TxPolicyFunc is an adapter to allow the use of ordinary functions as
+// transaction pool admission policies.
+type TxPolicyFunc func(tx *types.Transaction, ctx *TxPolicyContext) error
+
+// Admit calls f(tx, ctx).
+func (f TxPolicyFunc) Admit(tx *types.Transaction, ctx *TxPolicyContext) error {
+	return f(tx, ctx)
+}
+
+// configPolicy is the admission policy assembled from the user configuration.
+type configPolicy struct {
+	allowSenders    map[common.Address]struct{}
+	denySenders     map[common.Address]struct{}
+	allowRecipients map[common.Address]struct{}
+	denyRecipients  map[common.Address]struct{}
+	creators        map[common.Address]struct{}
+	maxPerContract  uint64
+}
+
+// newConfigPolicy creates an admission policy enforcing the given configs.
+func newConfigPolicy(config TxPolicyConfig) *configPolicy {
+	return &configPolicy{
+		allowSenders:    newAddressSet(config.AllowSenders),
+		denySenders:     newAddressSet(config.DenySenders),
+		allowRecipients: newAddressSet(config.AllowRecipients),
+		denyRecipients:  newAddressSet(config.DenyRecipients),
+		creators:        newAddressSet(config.Creators),
+		maxPerContract:  config.MaxPerContract,
+	}
+}
+
+// newAddressSet converts a list of addresses into a lookup set, returning nil
+// for an empty list.
+func newAddressSet(addrs []common.Address) map[common.Address]struct{} {
+	if len(addrs) == 0 {
+		return nil
+	}
+	set := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = struct{}{}
+	}
+	return set
+}
+
+// Admit implements TxPolicy, checking the transaction against the configured
+// access lists and contract limits.
+func (p *configPolicy) Admit(tx *types.Transaction, ctx *TxPolicyContext) error {
+	if _, denied := p.denySenders[ctx.From]; denied {
+		return ErrSenderDenied
+	}
+	if p.allowSenders != nil {
+		if _, allowed := p.allowSenders[ctx.From]; !allowed {
+			return ErrSenderDenied
+		}
+	}
+	to := tx.To()
+	if to == nil {
+		if p.creators != nil {
+			if _, allowed := p.creators[ctx.From]; !allowed {
+				return ErrCreationDenied
+			}
+		}
+		return nil
+	}
+	if _, denied := p.denyRecipients[*to]; denied {
+		return ErrRecipientDenied
+	}
+	if p.allowRecipients != nil {
+		if _, allowed := p.allowRecipients[*to]; !allowed {
+			return ErrRecipientDenied
+		}
+	}
+	if p.maxPerContract > 0 && uint64(ctx.Pooled(*to)) >= p.maxPerContract {
+		if ctx.State.GetCodeSize(*to) > 0 {
+			return ErrContractLimit
+		}
+	}
+	return nil
+}
+
+// txBucket is the token bucket tracking the transaction allowance of a peer.
+type txBucket struct {
+	tokens float64   // Number of transactions the peer may still relay
+	last   time.Time // Last time the allowance was refilled
+}
+
+// txPeerLimiter rate limits the remote transactions relayed by individual peers
+// using a token bucket per peer.
+//
+// Note, the limiter is not thread safe, it relies on the pool lock being held.
+type txPeerLimiter struct {
+	rate    float64              // Number of transactions per second refilled
+	burst   float64              // Maximum number of accumulated transactions
+	buckets map[string]*txBucket // Allowance buckets of the individual peers
+}
+
+// newTxPeerLimiter creates a rate limiter for remote peers, returning nil if
+// rate limiting is disabled.
+func newTxPeerLimiter(rate float64, burst uint64) *txPeerLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &txPeerLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*txBucket),
+	}
+}
+
+// allow reports whether the peer may relay one more transaction, consuming the
+// allowance if so.
+func (l *txPeerLimiter) allow(peer string, now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	bucket := l.buckets[peer]
+	if bucket == nil {
+		bucket = &txBucket{tokens: l.burst, last: now}
+		l.buckets[peer] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// prune drops the buckets of all peers which have their full allowance back,
+// ensuring disconnected peers don't accumulate in memory.
+func (l *txPeerLimiter) prune(now time.Time) {
+	if l == nil {
+		return
+	}
+	for peer, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, peer)
+		}
+	}
+}
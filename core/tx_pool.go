@@ -25,6 +25,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/hashicorp/golang-lru"
 	"github.com/simplechain-org/go-simplechain/common"
 	"github.com/simplechain-org/go-simplechain/core/state"
 	"github.com/simplechain-org/go-simplechain/core/types"
@@ -38,6 +39,10 @@ import (
 const (
 	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
 	chainHeadChanSize = 10
+
+	// privateTxCacheLimit is the number of recent private transactions remembered
+	// to keep them private when reinjected after a reorg.
+	privateTxCacheLimit = 4096
 )
 
 var (
@@ -99,6 +104,8 @@ var (
 	// General tx metrics
 	invalidTxCounter     = metrics.NewRegisteredCounter("txpool/invalid", nil)
 	underpricedTxCounter = metrics.NewRegisteredCounter("txpool/underpriced", nil)
+	deniedTxCounter      = metrics.NewRegisteredCounter("txpool/denied", nil)    // Rejected by an admission policy
+	peerLimitTxCounter   = metrics.NewRegisteredCounter("txpool/peerlimit", nil) // Rejected due to peer rate limiting
 )
 
 // TxStatus is the current status of a transaction as seen by the pool.
@@ -111,6 +118,54 @@ const (
 	TxStatusIncluded
 )
 
+// TxChangeReason is the reason of a transaction entering, moving within or
+// leaving the transaction pool.
+type TxChangeReason uint8
+
+const (
+	TxChangeAdded       TxChangeReason = iota // Transaction accepted into the pool
+	TxChangePromoted                          // Transaction became executable
+	TxChangeDemoted                           // Transaction became non-executable, but is retained
+	TxChangeReplaced                          // Transaction superseded by another with the same nonce
+	TxChangeUnderpriced                       // Transaction dropped due to its gas price
+	TxChangeEvicted                           // Transaction dropped due to pool or account limits
+	TxChangeExpired                           // Transaction dropped after being queued for too long
+	TxChangeIncluded                          // Transaction dropped as its nonce was used on chain
+	TxChangeUnpayable                         // Transaction dropped due to insufficient funds or gas
+)
+
+var txChangeReasonNames = []string{
+	TxChangeAdded:       "added",
+	TxChangePromoted:    "promoted",
+	TxChangeDemoted:     "demoted",
+	TxChangeReplaced:    "replaced",
+	TxChangeUnderpriced: "underpriced",
+	TxChangeEvicted:     "evicted",
+	TxChangeExpired:     "expired",
+	TxChangeIncluded:    "included",
+	TxChangeUnpayable:   "unpayable",
+}
+
+// String implements fmt.Stringer.
+func (r TxChangeReason) String() string {
+	if int(r) < len(txChangeReasonNames) {
+		return txChangeReasonNames[r]
+	}
+	return fmt.Sprintf("unknown(%d)", r)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (r TxChangeReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// TxChange is a single transaction entering, moving within or leaving the pool.
+type TxChange struct {
+	Tx          *types.Transaction // Transaction the change applies to
+	Reason      TxChangeReason     // Reason of the change
+	Replacement *types.Transaction // Transaction superseding Tx if it was replaced
+}
+
 // blockChain provides the state of blockchain and current gas limit to do
 // some pre checks in tx pool and event subscribers.
 type blockChain interface {
@@ -123,9 +178,10 @@ type blockChain interface {
 
 // TxPoolConfig are the configuration parameters of the transaction pool.
 type TxPoolConfig struct {
-	NoLocals  bool          // Whether local transaction handling should be disabled
-	Journal   string        // Journal of local transactions to survive node restarts
-	Rejournal time.Duration // Time interval to regenerate the local transaction journal
+	NoLocals      bool          // Whether local transaction handling should be disabled
+	Journal       string        // Journal of local transactions to survive node restarts
+	RemoteJournal string        // Snapshot of remote transactions to survive node restarts (empty = disabled)
+	Rejournal     time.Duration // Time interval to regenerate the transaction journals
 
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
@@ -136,6 +192,8 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	Policy TxPolicyConfig // Admission rules enforced on top of the built-in validation
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -171,6 +229,7 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool price bump", "provided", conf.PriceBump, "updated", DefaultTxPoolConfig.PriceBump)
 		conf.PriceBump = DefaultTxPoolConfig.PriceBump
 	}
+	conf.Policy = conf.Policy.sanitize()
 	return conf
 }
 
@@ -187,6 +246,7 @@ type TxPool struct {
 	chain        blockChain
 	gasPrice     *big.Int
 	txFeed       event.Feed
+	changeFeed   event.Feed
 	scope        event.SubscriptionScope
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
@@ -197,8 +257,20 @@ type TxPool struct {
 	pendingState  *state.ManagedState // Pending state tracking virtual nonces
 	currentMaxGas uint64              // Current gas limit for transaction caps
 
-	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *txJournal  // Journal of local transaction to back up to disk
+	locals   *accountSet // Set of local transaction to exempt from eviction rules
+	privates *lru.Cache  // Recent transactions submitted through the private lane, surviving inclusion
+	journal  *txJournal  // Journal of local transaction to back up to disk
+	remotes  *txJournal  // Snapshot of remote transactions to back up to disk
+
+	policy   *configPolicy  // Admission policy assembled from the user configuration
+	policies []TxPolicy     // Additional admission policies plugged in by the user
+	limiter  *txPeerLimiter // Rate limiter for transactions relayed by remote peers
+
+	changes     []TxChange    // Changes accumulated since the last change notification
+	changeMu    sync.Mutex    // Lock protecting the flushed changes, independent of the pool lock
+	changeQueue [][]TxChange  // Flushed changes not yet delivered to the subscribers, in order
+	changeReady chan struct{} // Notifies the change delivery loop of newly flushed changes
+	changeQuit  chan struct{} // Terminates the change delivery loop
 
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
@@ -228,15 +300,20 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		beats:       make(map[common.Address]time.Time),
 		all:         newTxLookup(),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
+		changeReady: make(chan struct{}, 1),
+		changeQuit:  make(chan struct{}),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
+		policy:      newConfigPolicy(config.Policy),
+		limiter:     newTxPeerLimiter(config.Policy.PeerRate, config.Policy.PeerBurst),
 	}
 	pool.locals = newAccountSet(pool.signer)
+	pool.privates, _ = lru.New(privateTxCacheLimit)
 	pool.priced = newTxPricedList(pool.all)
 	pool.reset(nil, chain.CurrentBlock().Header())
 
 	// If local transactions and journaling is enabled, load from disk
 	if !config.NoLocals && config.Journal != "" {
-		pool.journal = newTxJournal(config.Journal)
+		pool.journal = newTxJournal("local", config.Journal)
 
 		if err := pool.journal.load(pool.AddLocals); err != nil {
 			log.Warn("Failed to load transaction journal", "err", err)
@@ -245,12 +322,22 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If remote transaction snapshotting is enabled, reload the previous pool.
+	// Loading happens after the locals, so remotes can't evict any of them.
+	if config.RemoteJournal != "" {
+		pool.remotes = newTxJournal("remote", config.RemoteJournal)
+
+		if err := pool.remotes.load(pool.AddRemotes); err != nil {
+			log.Warn("Failed to load remote transaction journal", "err", err)
+		}
+	}
 	// Subscribe events from blockchain
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 
 	// Start the event loop and return
-	pool.wg.Add(1)
+	pool.wg.Add(2)
 	go pool.loop()
+	go pool.changeLoop()
 
 	return pool
 }
@@ -319,12 +406,15 @@ func (pool *TxPool) loop() {
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					for _, tx := range pool.queue[addr].Flatten() {
 						pool.removeTx(tx.Hash(), true)
+						pool.change(tx, TxChangeExpired)
 					}
 				}
 			}
+			pool.limiter.prune(time.Now())
+			pool.flushChanges()
 			pool.mu.Unlock()
 
-		// Handle local transaction journal rotation
+		// Handle local transaction journal rotation and remote snapshotting
 		case <-journal.C:
 			if pool.journal != nil {
 				pool.mu.Lock()
@@ -333,6 +423,13 @@ func (pool *TxPool) loop() {
 				}
 				pool.mu.Unlock()
 			}
+			if pool.remotes != nil {
+				pool.mu.Lock()
+				if err := pool.remotes.rotate(pool.remote()); err != nil {
+					log.Warn("Failed to snapshot remote tx journal", "err", err)
+				}
+				pool.mu.Unlock()
+			}
 		}
 	}
 }
@@ -409,10 +506,19 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.pendingState = state.ManageState(statedb)
 	pool.currentMaxGas = newHead.GasLimit
 
-	// Inject any transactions discarded due to reorgs
+	// Inject any transactions discarded due to reorgs, keeping private ones private
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
-	pool.addTxsLocked(reinject, false)
+	for _, tx := range reinject {
+		if pool.privates.Contains(tx.Hash()) {
+			pool.all.MarkPrivate(tx.Hash())
+		}
+	}
+	for i, err := range pool.addTxsLocked(reinject, false) {
+		if hash := reinject[i].Hash(); err != nil && pool.all.Get(hash) == nil {
+			pool.all.UnmarkPrivate(hash)
+		}
+	}
 
 	// validate the pool of pending transactions, this will remove
 	// any transactions that have been included in the block or
@@ -437,11 +543,20 @@ func (pool *TxPool) Stop() {
 
 	// Unsubscribe subscriptions registered from blockchain
 	pool.chainHeadSub.Unsubscribe()
+	close(pool.changeQuit)
 	pool.wg.Wait()
 
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.remotes != nil {
+		pool.mu.Lock()
+		if err := pool.remotes.rotate(pool.remote()); err != nil {
+			log.Warn("Failed to snapshot remote tx journal", "err", err)
+		}
+		pool.mu.Unlock()
+		pool.remotes.close()
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -451,6 +566,73 @@ func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscripti
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeTxPoolEvent registers a subscription of TxPoolEvent and starts
+// sending event to the given channel.
+func (pool *TxPool) SubscribeTxPoolEvent(ch chan<- TxPoolEvent) event.Subscription {
+	return pool.scope.Track(pool.changeFeed.Subscribe(ch))
+}
+
+// change records a transaction pool change to be announced at the next flush.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) change(tx *types.Transaction, reason TxChangeReason) {
+	pool.changes = append(pool.changes, TxChange{Tx: tx, Reason: reason})
+}
+
+// replaced records a transaction being superseded by another one to be announced
+// at the next flush.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) replaced(old, tx *types.Transaction) {
+	pool.changes = append(pool.changes, TxChange{Tx: old, Reason: TxChangeReplaced, Replacement: tx})
+}
+
+// flushChanges queues the changes accumulated since the last flush for delivery
+// to all subscribers, after the previously flushed ones.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) flushChanges() {
+	if len(pool.changes) == 0 {
+		return
+	}
+	pool.changeMu.Lock()
+	pool.changeQueue = append(pool.changeQueue, pool.changes)
+	pool.changeMu.Unlock()
+	pool.changes = nil
+
+	select {
+	case pool.changeReady <- struct{}{}:
+	default:
+	}
+}
+
+// changeLoop delivers the flushed changes to the subscribers in the order they
+// were flushed, without holding the pool lock while subscribers are slow.
+func (pool *TxPool) changeLoop() {
+	defer pool.wg.Done()
+
+	for {
+		select {
+		case <-pool.changeReady:
+		case <-pool.changeQuit:
+			return
+		}
+		for {
+			pool.changeMu.Lock()
+			if len(pool.changeQueue) == 0 {
+				pool.changeMu.Unlock()
+				break
+			}
+			changes := pool.changeQueue[0]
+			pool.changeQueue[0] = nil
+			pool.changeQueue = pool.changeQueue[1:]
+			pool.changeMu.Unlock()
+
+			pool.changeFeed.Send(TxPoolEvent{changes})
+		}
+	}
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -468,7 +650,9 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	pool.gasPrice = price
 	for _, tx := range pool.priced.Cap(price, pool.locals) {
 		pool.removeTx(tx.Hash(), false)
+		pool.change(tx, TxChangeUnderpriced)
 	}
+	pool.flushChanges()
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
@@ -535,21 +719,60 @@ func (pool *TxPool) Pending() (map[common.Address]types.Transactions, error) {
 }
 
 // local retrieves all currently known local transactions, groupped by origin
-// account and sorted by nonce. The returned transaction set is a copy and can be
-// freely modified by calling code.
+// account and sorted by nonce. Transactions submitted through the private lane
+// are omitted. The returned transaction set is a copy and can be freely modified
+// by calling code.
 func (pool *TxPool) local() map[common.Address]types.Transactions {
 	txs := make(map[common.Address]types.Transactions)
 	for addr := range pool.locals.accounts {
 		if pending := pool.pending[addr]; pending != nil {
-			txs[addr] = append(txs[addr], pending.Flatten()...)
+			txs[addr] = append(txs[addr], pool.public(pending.Flatten())...)
 		}
 		if queued := pool.queue[addr]; queued != nil {
-			txs[addr] = append(txs[addr], queued.Flatten()...)
+			txs[addr] = append(txs[addr], pool.public(queued.Flatten())...)
+		}
+		if len(txs[addr]) == 0 {
+			delete(txs, addr)
+		}
+	}
+	return txs
+}
+
+// remote retrieves all currently known remote transactions, groupped by origin
+// account and sorted by nonce. Transactions submitted through the private lane
+// are omitted. The returned transaction set is a copy and can be freely modified
+// by calling code.
+func (pool *TxPool) remote() map[common.Address]types.Transactions {
+	txs := make(map[common.Address]types.Transactions)
+	for addr, pending := range pool.pending {
+		if !pool.locals.contains(addr) {
+			txs[addr] = append(txs[addr], pool.public(pending.Flatten())...)
+		}
+	}
+	for addr, queued := range pool.queue {
+		if !pool.locals.contains(addr) {
+			txs[addr] = append(txs[addr], pool.public(queued.Flatten())...)
+		}
+	}
+	for addr, list := range txs {
+		if len(list) == 0 {
+			delete(txs, addr)
 		}
 	}
 	return txs
 }
 
+// public filters out all the transactions submitted through the private lane.
+func (pool *TxPool) public(txs types.Transactions) types.Transactions {
+	filtered := txs[:0]
+	for _, tx := range txs {
+		if !pool.all.IsPrivate(tx.Hash()) {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
+
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
@@ -592,6 +815,18 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if tx.Gas() < intrGas {
 		return ErrIntrinsicGas
 	}
+	// Enforce the operator configured and any plugged in admission policies
+	ctx := &TxPolicyContext{From: from, Local: local, State: pool.currentState, pool: pool}
+	if err := pool.policy.Admit(tx, ctx); err != nil {
+		deniedTxCounter.Inc(1)
+		return err
+	}
+	for _, policy := range pool.policies {
+		if err := policy.Admit(tx, ctx); err != nil {
+			deniedTxCounter.Inc(1)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -610,8 +845,12 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		log.Trace("Discarding already known transaction", "hash", hash)
 		return false, fmt.Errorf("known transaction: %x", hash)
 	}
+	// Private transactions are priced like local ones, without their sender
+	// becoming local
+	privileged := local || (pool.all.IsPrivate(hash) && !pool.config.NoLocals)
+
 	// If the transaction fails basic validation, discard it
-	if err := pool.validateTx(tx, local); err != nil {
+	if err := pool.validateTx(tx, privileged); err != nil {
 		log.Trace("Discarding invalid transaction", "hash", hash, "err", err)
 		invalidTxCounter.Inc(1)
 		return false, err
@@ -619,7 +858,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(pool.all.Count()) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
-		if !local && pool.priced.Underpriced(tx, pool.locals) {
+		if !privileged && pool.priced.Underpriced(tx, pool.locals) {
 			log.Trace("Discarding underpriced transaction", "hash", hash, "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
 			return false, ErrUnderpriced
@@ -630,6 +869,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
 			pool.removeTx(tx.Hash(), false)
+			pool.change(tx, TxChangeUnderpriced)
 		}
 	}
 	// If the transaction is replacing an already pending one, do directly
@@ -651,6 +891,12 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		pool.priced.Put(tx)
 		pool.journalTx(from, tx)
 
+		pool.change(tx, TxChangeAdded)
+		pool.change(tx, TxChangePromoted)
+		if old != nil {
+			pool.replaced(old, tx)
+		}
+
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
 
 		// We've directly injected a replacement transaction, notify subsystems
@@ -663,6 +909,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	pool.change(tx, TxChangeAdded)
 	// Mark local addresses and journal local transactions
 	if local {
 		pool.locals.add(from)
@@ -693,6 +940,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed()
 		queuedReplaceCounter.Inc(1)
+		pool.replaced(old, tx)
 	}
 	if pool.all.Get(hash) == nil {
 		pool.all.Add(tx)
@@ -704,8 +952,8 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 // journalTx adds the specified transaction to the local disk journal if it is
 // deemed to have been sent from a local account.
 func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
-	// Only journal if it's enabled and the transaction is local
-	if pool.journal == nil || !pool.locals.contains(from) {
+	// Only journal if it's enabled and the transaction is local and public
+	if pool.journal == nil || !pool.locals.contains(from) || pool.all.IsPrivate(tx.Hash()) {
 		return
 	}
 	if err := pool.journal.insert(tx); err != nil {
@@ -731,6 +979,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.priced.Removed()
 
 		pendingDiscardCounter.Inc(1)
+		pool.replaced(tx, list.txs.Get(tx.Nonce()))
 		return false
 	}
 	// Otherwise discard any previous transaction and mark this
@@ -739,6 +988,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.priced.Removed()
 
 		pendingReplaceCounter.Inc(1)
+		pool.replaced(old, tx)
 	}
 	// Failsafe to work around direct pending inserts (tests)
 	if pool.all.Get(hash) == nil {
@@ -780,6 +1030,105 @@ func (pool *TxPool) AddRemotes(txs []*types.Transaction) []error {
 	return pool.addTxs(txs, false)
 }
 
+// AddRemotesFrom enqueues a batch of transactions relayed by a remote peer into
+// the pool if they are valid and the peer did not exceed its relay allowance.
+// If the senders are not among the locally tracked ones, full pricing constraints
+// will apply.
+func (pool *TxPool) AddRemotesFrom(peer string, txs []*types.Transaction) []error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	// Drop all the transactions above the peer's allowance
+	var (
+		now     = time.Now()
+		errs    = make([]error, len(txs))
+		allowed = make([]*types.Transaction, 0, len(txs))
+		indices = make([]int, 0, len(txs))
+	)
+	for i, tx := range txs {
+		if !pool.limiter.allow(peer, now) {
+			log.Trace("Discarding rate limited transaction", "hash", tx.Hash(), "peer", peer)
+			peerLimitTxCounter.Inc(1)
+			errs[i] = ErrPeerRateLimit
+			continue
+		}
+		allowed = append(allowed, tx)
+		indices = append(indices, i)
+	}
+	// Add the remainder and merge the results
+	for i, err := range pool.addTxsLocked(allowed, false) {
+		errs[indices[i]] = err
+	}
+	return errs
+}
+
+// AddPrivate enqueues a single transaction into the pool if it is valid, marking
+// it as private. Private transactions are priced like local ones and are available
+// to the local miner, but are never propagated to the network or journaled, not
+// even when reinjected after a reorg. Unlike local transactions they don't make
+// their sender local, only they themselves being exempt from price evictions.
+func (pool *TxPool) AddPrivate(tx *types.Transaction) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	// Flag the transaction private before insertion so it's never announced
+	hash := tx.Hash()
+	if pool.all.Get(hash) != nil {
+		log.Trace("Discarding already known transaction", "hash", hash)
+		return fmt.Errorf("known transaction: %x", hash)
+	}
+	pool.all.MarkPrivate(hash)
+
+	replace, err := pool.add(tx, false)
+	if err != nil {
+		pool.all.UnmarkPrivate(hash)
+		return err
+	}
+	pool.privates.Add(hash, struct{}{})
+	if !replace {
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		pool.promoteExecutables([]common.Address{from})
+	}
+	pool.flushChanges()
+	return nil
+}
+
+// IsPrivate reports whether the transaction with the given hash is contained in
+// the pool and was submitted through the private lane.
+func (pool *TxPool) IsPrivate(hash common.Hash) bool {
+	return pool.all.IsPrivate(hash)
+}
+
+// AddPolicy plugs an additional admission policy into the transaction pool. The
+// policy is only consulted for newly arriving transactions.
+func (pool *TxPool) AddPolicy(policy TxPolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.policies = append(pool.policies, policy)
+}
+
+// Policy returns the currently enforced admission policy configuration.
+func (pool *TxPool) Policy() TxPolicyConfig {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.config.Policy
+}
+
+// SetPolicy replaces the configured admission policy of the transaction pool.
+// Transactions already in the pool are not reevaluated.
+func (pool *TxPool) SetPolicy(config TxPolicyConfig) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.config.Policy = config.sanitize()
+	pool.policy = newConfigPolicy(pool.config.Policy)
+	pool.limiter = newTxPeerLimiter(pool.config.Policy.PeerRate, pool.config.Policy.PeerBurst)
+
+	log.Info("Transaction pool admission policy updated")
+}
+
 // addTx enqueues a single transaction into the pool if it is valid.
 func (pool *TxPool) addTx(tx *types.Transaction, local bool) error {
 	pool.mu.Lock()
@@ -795,6 +1144,7 @@ func (pool *TxPool) addTx(tx *types.Transaction, local bool) error {
 		from, _ := types.Sender(pool.signer, tx) // already validated
 		pool.promoteExecutables([]common.Address{from})
 	}
+	pool.flushChanges()
 	return nil
 }
 
@@ -828,6 +1178,7 @@ func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) []error {
 		}
 		pool.promoteExecutables(addrs)
 	}
+	pool.flushChanges()
 	return errs
 }
 
@@ -883,6 +1234,7 @@ func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
 			// Postpone any invalidated transactions
 			for _, tx := range invalids {
 				pool.enqueueTx(tx.Hash(), tx)
+				pool.change(tx, TxChangeDemoted)
 			}
 			// Update the account nonce if needed
 			if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
@@ -926,6 +1278,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			log.Trace("Removed old queued transaction", "hash", hash)
 			pool.all.Remove(hash)
 			pool.priced.Removed()
+			pool.change(tx, TxChangeIncluded)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
@@ -935,6 +1288,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			pool.all.Remove(hash)
 			pool.priced.Removed()
 			queuedNofundsCounter.Inc(1)
+			pool.change(tx, TxChangeUnpayable)
 		}
 		// Gather all executable transactions and promote them
 		for _, tx := range list.Ready(pool.pendingState.GetNonce(addr)) {
@@ -942,6 +1296,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			if pool.promoteTx(addr, hash, tx) {
 				log.Trace("Promoting queued transaction", "hash", hash)
 				promoted = append(promoted, tx)
+				pool.change(tx, TxChangePromoted)
 			}
 		}
 		// Drop all transactions over the allowed limit
@@ -952,6 +1307,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 				pool.priced.Removed()
 				queuedRateLimitCounter.Inc(1)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
+				pool.change(tx, TxChangeEvicted)
 			}
 		}
 		// Delete the entire queue entry if it became empty.
@@ -1005,6 +1361,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 								pool.pendingState.SetNonce(offenders[i], nonce)
 							}
 							log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
+							pool.change(tx, TxChangeEvicted)
 						}
 						pending--
 					}
@@ -1027,6 +1384,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 							pool.pendingState.SetNonce(addr, nonce)
 						}
 						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
+						pool.change(tx, TxChangeEvicted)
 					}
 					pending--
 				}
@@ -1060,6 +1418,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			if size := uint64(list.Len()); size <= drop {
 				for _, tx := range list.Flatten() {
 					pool.removeTx(tx.Hash(), true)
+					pool.change(tx, TxChangeEvicted)
 				}
 				drop -= size
 				queuedRateLimitCounter.Inc(int64(size))
@@ -1069,11 +1428,14 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			txs := list.Flatten()
 			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 				pool.removeTx(txs[i].Hash(), true)
+				pool.change(txs[i], TxChangeEvicted)
 				drop--
 				queuedRateLimitCounter.Inc(1)
 			}
 		}
 	}
+	// Notify subsystems of all the changes made to the pool
+	pool.flushChanges()
 }
 
 // demoteUnexecutables removes invalid and processed transactions from the pools
@@ -1090,6 +1452,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed old pending transaction", "hash", hash)
 			pool.all.Remove(hash)
 			pool.priced.Removed()
+			pool.change(tx, TxChangeIncluded)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
@@ -1099,11 +1462,13 @@ func (pool *TxPool) demoteUnexecutables() {
 			pool.all.Remove(hash)
 			pool.priced.Removed()
 			pendingNofundsCounter.Inc(1)
+			pool.change(tx, TxChangeUnpayable)
 		}
 		for _, tx := range invalids {
 			hash := tx.Hash()
 			log.Trace("Demoting pending transaction", "hash", hash)
 			pool.enqueueTx(hash, tx)
+			pool.change(tx, TxChangeDemoted)
 		}
 		// If there's a gap in front, alert (should never happen) and postpone all transactions
 		if list.Len() > 0 && list.txs.Get(nonce) == nil {
@@ -1111,6 +1476,7 @@ func (pool *TxPool) demoteUnexecutables() {
 				hash := tx.Hash()
 				log.Error("Demoting invalidated transaction", "hash", hash)
 				pool.enqueueTx(hash, tx)
+				pool.change(tx, TxChangeDemoted)
 			}
 		}
 		// Delete the entire queue entry if it became empty.
@@ -1179,14 +1545,18 @@ func (as *accountSet) add(addr common.Address) {
 // peeking into the pool in TxPool.Get without having to acquire the widely scoped
 // TxPool.mu mutex.
 type txLookup struct {
-	all  map[common.Hash]*types.Transaction
-	lock sync.RWMutex
+	all     map[common.Hash]*types.Transaction
+	private map[common.Hash]struct{} // Transactions submitted through the private lane
+	calls   map[common.Address]int   // Number of transactions per recipient account
+	lock    sync.RWMutex
 }
 
 // newTxLookup returns a new txLookup structure.
 func newTxLookup() *txLookup {
 	return &txLookup{
-		all: make(map[common.Hash]*types.Transaction),
+		all:     make(map[common.Hash]*types.Transaction),
+		private: make(map[common.Hash]struct{}),
+		calls:   make(map[common.Address]int),
 	}
 }
 
@@ -1218,12 +1588,53 @@ func (t *txLookup) Count() int {
 	return len(t.all)
 }
 
+// Calls returns the number of transactions in the lookup with the given account
+// as their recipient.
+func (t *txLookup) Calls(to common.Address) int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.calls[to]
+}
+
+// IsPrivate reports whether a transaction is marked as private.
+func (t *txLookup) IsPrivate(hash common.Hash) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	_, ok := t.private[hash]
+	return ok
+}
+
+// MarkPrivate flags a transaction as private. The flag is cleared when the
+// transaction is removed from the lookup.
+func (t *txLookup) MarkPrivate(hash common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.private[hash] = struct{}{}
+}
+
+// UnmarkPrivate clears the private flag of a transaction.
+func (t *txLookup) UnmarkPrivate(hash common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.private, hash)
+}
+
 // Add adds a transaction to the lookup.
 func (t *txLookup) Add(tx *types.Transaction) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	t.all[tx.Hash()] = tx
+	hash := tx.Hash()
+	if _, ok := t.all[hash]; !ok {
+		if to := tx.To(); to != nil {
+			t.calls[*to]++
+		}
+	}
+	t.all[hash] = tx
 }
 
 // Remove removes a transaction from the lookup.
@@ -1231,5 +1642,13 @@ func (t *txLookup) Remove(hash common.Hash) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
+	if tx, ok := t.all[hash]; ok {
+		if to := tx.To(); to != nil {
+			if t.calls[*to]--; t.calls[*to] == 0 {
+				delete(t.calls, *to)
+			}
+		}
+	}
 	delete(t.all, hash)
+	delete(t.private, hash)
 }
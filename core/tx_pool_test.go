@@ -18,6 +18,7 @@ package core
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -1680,6 +1681,240 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that remote transactions, both pending and queued, survive a restart of
+// the pool if snapshotting is enabled, that they are revalidated against the new
+// chain head and that the global pool limits are respected when reloading.
+func TestTransactionRemoteJournaling(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary file for the snapshot
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal: %v", err)
+	}
+	journal := file.Name()
+	defer os.Remove(journal)
+
+	// Clean up the temporary file, we only need the path for now
+	file.Close()
+	os.Remove(journal)
+
+	// Create the original pool to inject the remote transactions into
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.RemoteJournal = journal
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	private, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(private.PublicKey), big.NewInt(1000000000))
+
+	// Add a batch of pending and queued remote transactions, along with a private one
+	txs := types.Transactions{
+		pricedTransaction(0, 100000, big.NewInt(1), keys[0]),
+		pricedTransaction(1, 100000, big.NewInt(1), keys[0]),
+		pricedTransaction(0, 100000, big.NewInt(1), keys[1]),
+		pricedTransaction(2, 100000, big.NewInt(1), keys[1]),
+		pricedTransaction(1, 100000, big.NewInt(1), keys[2]),
+		pricedTransaction(2, 100000, big.NewInt(1), keys[2]),
+	}
+	for i, err := range pool.AddRemotes(txs) {
+		if err != nil {
+			t.Fatalf("tx %d: failed to add remote transaction: %v", i, err)
+		}
+	}
+	if err := pool.AddPrivate(pricedTransaction(0, 100000, big.NewInt(1), private)); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	pending, queued := pool.Stats()
+	if pending != 4 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 4)
+	}
+	if queued != 3 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 3)
+	}
+	// Terminate the old pool, include the first transaction in a block and restart
+	pool.Stop()
+	statedb.SetNonce(crypto.PubkeyToAddress(keys[0].PublicKey), 1)
+	blockchain = &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+
+	pending, queued = pool.Stats()
+	if pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	if queued != 3 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 3)
+	}
+	if pool.Get(txs[0].Hash()) != nil {
+		t.Errorf("included transaction reloaded")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	pool.Stop()
+
+	// Restart the pool with a reduced capacity and ensure the limits are respected
+	config.GlobalSlots = 1
+	config.GlobalQueue = 1
+	blockchain = &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	pending, queued = pool.Stats()
+	if pending+queued > int(config.GlobalSlots+config.GlobalQueue) {
+		t.Fatalf("pool limits exceeded: have %d pending + %d queued, want at most %d", pending, queued, config.GlobalSlots+config.GlobalQueue)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the transaction pool change feed reports additions, promotions,
+// replacements and the reason of every transaction leaving the pool.
+func TestTransactionPoolChangeEvents(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	events := make(chan TxPoolEvent, 32)
+	sub := pool.SubscribeTxPoolEvent(events)
+	defer sub.Unsubscribe()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(1000000))
+
+	// Waits for the given number of changes and returns them
+	collect := func(count int) []TxChange {
+		var changes []TxChange
+		for len(changes) < count {
+			select {
+			case ev := <-events:
+				changes = append(changes, ev.Changes...)
+			case <-time.After(time.Second):
+				t.Fatalf("change #%d not fired", len(changes))
+			}
+		}
+		return changes
+	}
+	// Add a transaction and replace it with a better priced one
+	tx0 := pricedTransaction(0, 100000, big.NewInt(1), key)
+	tx1 := pricedTransaction(0, 100000, big.NewInt(2), key)
+
+	if err := pool.AddRemote(tx0); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	changes := collect(2)
+	if changes[0].Reason != TxChangeAdded || changes[1].Reason != TxChangePromoted {
+		t.Fatalf("addition reasons mismatch: have %v/%v, want %v/%v", changes[0].Reason, changes[1].Reason, TxChangeAdded, TxChangePromoted)
+	}
+	if err := pool.AddRemote(tx1); err != nil {
+		t.Fatalf("failed to add replacement transaction: %v", err)
+	}
+	changes = collect(3)
+	if changes[2].Reason != TxChangeReplaced || changes[2].Tx.Hash() != tx0.Hash() || changes[2].Replacement.Hash() != tx1.Hash() {
+		t.Fatalf("replacement mismatch: have %v %x -> %v", changes[2].Reason, changes[2].Tx.Hash(), changes[2].Replacement)
+	}
+	// Add a queued transaction and drop it via repricing
+	tx2 := pricedTransaction(2, 100000, big.NewInt(1), key)
+	if err := pool.AddRemote(tx2); err != nil {
+		t.Fatalf("failed to add queued transaction: %v", err)
+	}
+	if changes = collect(1); changes[0].Reason != TxChangeAdded {
+		t.Fatalf("queued reason mismatch: have %v, want %v", changes[0].Reason, TxChangeAdded)
+	}
+	pool.SetGasPrice(big.NewInt(2))
+	if changes = collect(1); changes[0].Reason != TxChangeUnderpriced || changes[0].Tx.Hash() != tx2.Hash() {
+		t.Fatalf("repricing mismatch: have %v %x, want %v %x", changes[0].Reason, changes[0].Tx.Hash(), TxChangeUnderpriced, tx2.Hash())
+	}
+	// Include the pending transaction in the chain and ensure it's reported
+	pool.currentState.SetNonce(from, 1)
+	pool.lockedReset(nil, nil)
+
+	if changes = collect(1); changes[0].Reason != TxChangeIncluded || changes[0].Tx.Hash() != tx1.Hash() {
+		t.Fatalf("inclusion mismatch: have %v %x, want %v %x", changes[0].Reason, changes[0].Tx.Hash(), TxChangeIncluded, tx1.Hash())
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected changes fired: %v", ev.Changes)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Tests that transaction pool changes are delivered in the order they happened,
+// even if subscribers lag behind back-to-back changes.
+func TestTransactionPoolChangeEventOrder(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	events := make(chan TxPoolEvent)
+	sub := pool.SubscribeTxPoolEvent(events)
+	defer sub.Unsubscribe()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(1000000000))
+
+	// Fire a series of changes without waiting for their delivery
+	const count = 32
+	for i := uint64(0); i < count; i++ {
+		if err := pool.AddRemote(pricedTransaction(i, 100000, big.NewInt(1), key)); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+		if err := pool.AddRemote(pricedTransaction(i, 100000, big.NewInt(2), key)); err != nil {
+			t.Fatalf("failed to replace transaction %d: %v", i, err)
+		}
+	}
+	pool.currentState.SetNonce(from, count)
+	pool.lockedReset(nil, nil)
+
+	// Every transaction must be reported added before anything else happens to it
+	var (
+		added = make(map[common.Hash]bool)
+		nonce = uint64(0)
+		total = 0
+	)
+	for total < 5*count {
+		select {
+		case ev := <-events:
+			for _, change := range ev.Changes {
+				total++
+				hash := change.Tx.Hash()
+				switch change.Reason {
+				case TxChangeAdded:
+					if change.Tx.Nonce() < nonce {
+						t.Fatalf("transaction %d added after %d", change.Tx.Nonce(), nonce)
+					}
+					nonce, added[hash] = change.Tx.Nonce(), true
+				case TxChangeReplaced:
+					if !added[hash] || !added[change.Replacement.Hash()] {
+						t.Fatalf("transaction %d replaced before being added", change.Tx.Nonce())
+					}
+				case TxChangePromoted, TxChangeIncluded:
+					if !added[hash] {
+						t.Fatalf("transaction %d %v before being added", change.Tx.Nonce(), change.Reason)
+					}
+				default:
+					t.Fatalf("unexpected change %v of transaction %d", change.Reason, change.Tx.Nonce())
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("change #%d not fired", total)
+		}
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
@@ -1736,6 +1971,260 @@ func TestTransactionStatusCheck(t *testing.T) {
 	}
 }
 
+// Tests that the configured sender, recipient and contract creation access lists
+// are enforced by the transaction pool, and that they can be updated at runtime.
+func TestTransactionPolicyAccessLists(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	other, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(1000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(other.PublicKey), big.NewInt(1000000))
+
+	target := common.HexToAddress("0x1234")
+	call := func(nonce uint64, to common.Address, key *ecdsa.PrivateKey) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(1), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		return tx
+	}
+	create := func(nonce uint64, key *ecdsa.PrivateKey) *types.Transaction {
+		tx, _ := types.SignTx(types.NewContractCreation(nonce, big.NewInt(0), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		return tx
+	}
+	// Deny the sender and ensure everything from it is rejected
+	pool.SetPolicy(TxPolicyConfig{DenySenders: []common.Address{from}})
+	if err := pool.AddRemote(call(0, target, key)); err != ErrSenderDenied {
+		t.Fatalf("denied sender error mismatch: have %v, want %v", err, ErrSenderDenied)
+	}
+	// Restrict the recipients and ensure only the permitted one is reachable
+	pool.SetPolicy(TxPolicyConfig{AllowRecipients: []common.Address{target}})
+	if err := pool.AddRemote(call(0, common.HexToAddress("0xdead"), key)); err != ErrRecipientDenied {
+		t.Fatalf("denied recipient error mismatch: have %v, want %v", err, ErrRecipientDenied)
+	}
+	if err := pool.AddRemote(call(0, target, key)); err != nil {
+		t.Fatalf("failed to add permitted transaction: %v", err)
+	}
+	// Restrict contract creations and ensure only approved creators may deploy
+	pool.SetPolicy(TxPolicyConfig{Creators: []common.Address{from}})
+	if err := pool.AddRemote(create(0, other)); err != ErrCreationDenied {
+		t.Fatalf("denied creation error mismatch: have %v, want %v", err, ErrCreationDenied)
+	}
+	if err := pool.AddRemote(create(1, key)); err != nil {
+		t.Fatalf("failed to add permitted contract creation: %v", err)
+	}
+	// Plug in a custom policy and ensure it's consulted too
+	errCustom := errors.New("custom rejection")
+	pool.AddPolicy(TxPolicyFunc(func(tx *types.Transaction, ctx *TxPolicyContext) error {
+		if tx.Nonce() == 2 {
+			return errCustom
+		}
+		return nil
+	}))
+	if err := pool.AddRemote(call(2, target, key)); err != errCustom {
+		t.Fatalf("custom policy error mismatch: have %v, want %v", err, errCustom)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the number of transactions pooled against a single contract is
+// capped, while plain value transfers are left alone.
+func TestTransactionPolicyContractLimit(t *testing.T) {
+	t.Parallel()
+
+	config := testTxPoolConfig
+	config.Policy.MaxPerContract = 2
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	contract, account := common.HexToAddress("0xc0de"), common.HexToAddress("0xacc0")
+	pool.currentState.SetCode(contract, []byte{0x00})
+
+	keys := make([]*ecdsa.PrivateKey, 5)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000))
+	}
+	call := func(to common.Address, key *ecdsa.PrivateKey) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(0, to, big.NewInt(1), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		return tx
+	}
+	for i := 0; i < 2; i++ {
+		if err := pool.AddRemote(call(contract, keys[i])); err != nil {
+			t.Fatalf("contract call %d: failed to add transaction: %v", i, err)
+		}
+	}
+	if err := pool.AddRemote(call(contract, keys[2])); err != ErrContractLimit {
+		t.Fatalf("contract limit error mismatch: have %v, want %v", err, ErrContractLimit)
+	}
+	for i := 2; i < len(keys); i++ {
+		if err := pool.AddRemote(call(account, keys[i])); err != nil {
+			t.Fatalf("transfer %d: failed to add transaction: %v", i, err)
+		}
+	}
+	if calls := pool.all.Calls(account); calls != 3 {
+		t.Fatalf("account call count mismatch: have %d, want %d", calls, 3)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that remote transactions relayed by a single peer are rate limited, and
+// that the allowance of different peers is tracked independently.
+func TestTransactionPolicyPeerRateLimit(t *testing.T) {
+	t.Parallel()
+
+	config := testTxPoolConfig
+	config.Policy.PeerRate = 0.001
+	config.Policy.PeerBurst = 2
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+
+	errs := pool.AddRemotesFrom("alice", []*types.Transaction{transaction(0, 100000, key), transaction(1, 100000, key), transaction(2, 100000, key)})
+	if errs[0] != nil || errs[1] != nil || errs[2] != ErrPeerRateLimit {
+		t.Fatalf("rate limit errors mismatch: have %v, want [nil nil %v]", errs, ErrPeerRateLimit)
+	}
+	if errs := pool.AddRemotesFrom("bob", []*types.Transaction{transaction(2, 100000, key)}); errs[0] != nil {
+		t.Fatalf("failed to add transaction from fresh peer: %v", errs[0])
+	}
+	if pending, _ := pool.Stats(); pending != 3 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 3)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that transactions submitted through the private lane are executable by
+// the miner, but are neither announced as public nor journaled.
+func TestTransactionPrivateLane(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+
+	private, public := transaction(0, 100000, key), transaction(1, 100000, key)
+	if err := pool.AddPrivate(private); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	if err := pool.AddPrivate(private); err == nil {
+		t.Fatalf("duplicate private transaction accepted")
+	}
+	if err := pool.AddLocal(public); err != nil {
+		t.Fatalf("failed to add public transaction: %v", err)
+	}
+	if !pool.IsPrivate(private.Hash()) {
+		t.Errorf("private transaction not marked private")
+	}
+	if pool.IsPrivate(public.Hash()) {
+		t.Errorf("public transaction marked private")
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	pool.mu.RLock()
+	locals := pool.local()
+	pool.mu.RUnlock()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	if len(locals[from]) != 1 || locals[from][0].Hash() != public.Hash() {
+		t.Fatalf("journaled local transactions mismatch: have %v, want [%x]", locals[from], public.Hash())
+	}
+	// Drop the private transaction and ensure the flag is cleared
+	pool.mu.Lock()
+	pool.removeTx(private.Hash(), true)
+	pool.mu.Unlock()
+
+	if pool.IsPrivate(private.Hash()) {
+		t.Errorf("removed transaction still marked private")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// reorgBlockChain is a test chain serving its blocks by hash, to feed reorgs to
+// the transaction pool.
+type reorgBlockChain struct {
+	*testBlockChain
+	blocks map[common.Hash]*types.Block
+}
+
+func (bc *reorgBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return bc.blocks[hash]
+}
+
+// Tests that private transactions don't make their sender local, and that they
+// stay private when reinjected after being reorganised out of the chain.
+func TestTransactionPrivateReorg(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &reorgBlockChain{&testBlockChain{statedb, 1000000, new(event.Feed)}, make(map[common.Hash]*types.Block)}
+
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(1000000))
+
+	tx := transaction(0, 100000, key)
+	if err := pool.AddPrivate(tx); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	if pool.locals.contains(from) {
+		t.Errorf("sender of private transaction marked local")
+	}
+	// Include the transaction in a block, dropping it from the pool
+	newBlock := func(parent *types.Block, txs types.Transactions) *types.Block {
+		block := types.NewBlock(&types.Header{Number: new(big.Int).Add(parent.Number(), common.Big1), ParentHash: parent.Hash(), GasLimit: 1000000}, txs, nil, nil)
+		blockchain.blocks[block.Hash()] = block
+		return block
+	}
+	genesis := types.NewBlock(&types.Header{Number: common.Big0, GasLimit: 1000000}, nil, nil, nil)
+	blockchain.blocks[genesis.Hash()] = genesis
+
+	included := newBlock(genesis, types.Transactions{tx})
+	statedb.SetNonce(from, 1)
+	pool.lockedReset(genesis.Header(), included.Header())
+
+	if pool.Get(tx.Hash()) != nil {
+		t.Fatalf("included transaction still pooled")
+	}
+	// Reorganise the block out of the chain and check the reinjected transaction
+	fork := newBlock(newBlock(genesis, nil), nil)
+	statedb.SetNonce(from, 0)
+	pool.lockedReset(included.Header(), fork.Header())
+
+	if pool.Get(tx.Hash()) == nil {
+		t.Fatalf("reorganised transaction not reinjected")
+	}
+	if !pool.IsPrivate(tx.Hash()) {
+		t.Errorf("reinjected private transaction not marked private")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Benchmarks the speed of validating the contents of the pending queue of the
 // transaction pool.
 func BenchmarkPendingDemotion100(b *testing.B)   { benchmarkPendingDemotion(b, 100) }
@@ -221,6 +221,19 @@ func NewPrivateAdminAPI(eth *Simplechain) *PrivateAdminAPI {
 	return &PrivateAdminAPI{eth: eth}
 }
 
+// TxPoolPolicy returns the admission policy currently enforced by the
+// transaction pool.
+func (api *PrivateAdminAPI) TxPoolPolicy() core.TxPolicyConfig {
+	return api.eth.txPool.Policy()
+}
+
+// SetTxPoolPolicy replaces the admission policy enforced by the transaction
+// pool. The new policy only applies to transactions arriving after the update.
+func (api *PrivateAdminAPI) SetTxPoolPolicy(policy core.TxPolicyConfig) bool {
+	api.eth.txPool.SetPolicy(policy)
+	return true
+}
+
 // ExportChain exports the current blockchain into a local file.
 func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
 	// Make sure we can create the file to export into
@@ -323,12 +336,7 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 		_, stateDb := api.eth.miner.Pending()
 		return stateDb.RawDump(), nil
 	}
-	var block *types.Block
-	if blockNr == rpc.LatestBlockNumber {
-		block = api.eth.blockchain.CurrentBlock()
-	} else {
-		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
-	}
+	block, _ := api.eth.APIBackend.BlockByNumber(context.Background(), blockNr)
 	if block == nil {
 		return state.Dump{}, fmt.Errorf("block #%d not found", blockNr)
 	}
@@ -0,0 +1,551 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/core/rawdb"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/rpc"
+)
+
+const (
+	// traceFilterMaxBlocks is the maximum number of blocks a single trace_filter
+	// request may cover.
+	traceFilterMaxBlocks = 10000
+)
+
+var (
+	// callTraceConfig is the tracer configuration used to gather the call traces
+	// of transactions, including the details of self destructs.
+	callTraceConfig = &TraceConfig{
+		Tracer:       &callTracerName,
+		TracerConfig: json.RawMessage(`{"selfdestructDetails": true}`),
+	}
+	callTracerName = "callTracer"
+
+	// stateDiffTraceConfig is the tracer configuration used to gather the state
+	// changes done by transactions.
+	stateDiffTraceConfig = &TraceConfig{
+		Tracer:       &prestateTracerName,
+		TracerConfig: json.RawMessage(`{"diffMode": true}`),
+	}
+	prestateTracerName = "prestateTracer"
+)
+
+// TraceAction is the operation a call trace describes. Depending on the type of
+// the trace, different fields are populated.
+type TraceAction struct {
+	CallType      string          `json:"callType,omitempty"`      // call
+	From          *common.Address `json:"from,omitempty"`          // call, create
+	To            *common.Address `json:"to,omitempty"`            // call
+	Gas           *hexutil.Uint64 `json:"gas,omitempty"`           // call, create
+	Input         *hexutil.Bytes  `json:"input,omitempty"`         // call
+	Init          *hexutil.Bytes  `json:"init,omitempty"`          // create
+	Value         *hexutil.Big    `json:"value,omitempty"`         // call, create
+	Address       *common.Address `json:"address,omitempty"`       // suicide
+	RefundAddress *common.Address `json:"refundAddress,omitempty"` // suicide
+	Balance       *hexutil.Big    `json:"balance,omitempty"`       // suicide
+}
+
+// TraceActionResult is the outcome of a successful call or create operation.
+type TraceActionResult struct {
+	GasUsed hexutil.Uint64  `json:"gasUsed"`
+	Output  *hexutil.Bytes  `json:"output,omitempty"`  // call
+	Address *common.Address `json:"address,omitempty"` // create
+	Code    *hexutil.Bytes  `json:"code,omitempty"`    // create
+}
+
+// CallTrace is a single flattened call trace in the format of the trace_* RPC
+// namespace. The position of the call within the call tree of its transaction
+// is described by its trace address.
+type CallTrace struct {
+	Action              TraceAction        `json:"action"`
+	BlockHash           common.Hash        `json:"blockHash"`
+	BlockNumber         uint64             `json:"blockNumber"`
+	Error               string             `json:"error,omitempty"`
+	Result              *TraceActionResult `json:"result"`
+	Subtraces           int                `json:"subtraces"`
+	TraceAddress        []int              `json:"traceAddress"`
+	TransactionHash     common.Hash        `json:"transactionHash"`
+	TransactionPosition uint64             `json:"transactionPosition"`
+	Type                string             `json:"type"`
+}
+
+// callFrame is a single call of the call tree produced by the callTracer.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    *common.Address `json:"from"`
+	To      *common.Address `json:"to"`
+	Value   *hexutil.Big    `json:"value"`
+	Gas     *hexutil.Uint64 `json:"gas"`
+	GasUsed *hexutil.Uint64 `json:"gasUsed"`
+	Input   *hexutil.Bytes  `json:"input"`
+	Output  *hexutil.Bytes  `json:"output"`
+	Error   string          `json:"error"`
+	Calls   []*callFrame    `json:"calls"`
+}
+
+// flattenCallFrame converts the call tree of a transaction into a list of call
+// traces in depth first order.
+func flattenCallFrame(frame *callFrame, address []int, block *types.Block, tx *types.Transaction, index uint64) []*CallTrace {
+	trace := &CallTrace{
+		BlockHash:           block.Hash(),
+		BlockNumber:         block.NumberU64(),
+		Error:               frame.Error,
+		Subtraces:           len(frame.Calls),
+		TraceAddress:        append([]int{}, address...),
+		TransactionHash:     tx.Hash(),
+		TransactionPosition: index,
+	}
+	gas, gasUsed := frame.Gas, frame.GasUsed
+	if gas == nil {
+		gas = new(hexutil.Uint64)
+	}
+	if gasUsed == nil {
+		gasUsed = new(hexutil.Uint64)
+	}
+	value := frame.Value
+	if value == nil {
+		value = (*hexutil.Big)(new(big.Int))
+	}
+	switch frame.Type {
+	case "CREATE", "CREATE2":
+		trace.Type = "create"
+		trace.Action = TraceAction{From: frame.From, Gas: gas, Init: frame.Input, Value: value}
+		if frame.Error == "" {
+			trace.Result = &TraceActionResult{GasUsed: *gasUsed, Address: frame.To, Code: frame.Output}
+		}
+	case "SELFDESTRUCT":
+		trace.Type = "suicide"
+		trace.Action = TraceAction{Address: frame.From, RefundAddress: frame.To, Balance: value}
+	default:
+		trace.Type = "call"
+		trace.Action = TraceAction{CallType: strings.ToLower(frame.Type), From: frame.From, To: frame.To, Gas: gas, Input: frame.Input, Value: value}
+		if frame.Error == "" {
+			output := frame.Output
+			if output == nil {
+				output = new(hexutil.Bytes)
+			}
+			trace.Result = &TraceActionResult{GasUsed: *gasUsed, Output: output}
+		}
+	}
+	traces := []*CallTrace{trace}
+	for i, call := range frame.Calls {
+		traces = append(traces, flattenCallFrame(call, append(address, i), block, tx, index)...)
+	}
+	return traces
+}
+
+// PrivateTraceAPI is the collection of call tracing APIs in the format of the
+// trace_* RPC namespace, serving flattened call traces from the trace index if
+// available, or by re-executing the transactions otherwise.
+type PrivateTraceAPI struct {
+	eth   *Simplechain
+	debug *PrivateDebugAPI
+}
+
+// NewPrivateTraceAPI creates a new API definition for the call tracing methods
+// of the Simplechain service.
+func NewPrivateTraceAPI(eth *Simplechain) *PrivateTraceAPI {
+	return &PrivateTraceAPI{eth: eth, debug: NewPrivateDebugAPI(eth.chainConfig, eth)}
+}
+
+// blockByNumber retrieves a canonical block, resolving the block tags.
+func (api *PrivateTraceAPI) blockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.PendingBlockNumber {
+		return nil, errors.New("tracing the pending block is not supported")
+	}
+	block, _ := api.eth.APIBackend.BlockByNumber(ctx, number)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return block, nil
+}
+
+// Block returns the call traces of all the transactions in the given block.
+func (api *PrivateTraceAPI) Block(ctx context.Context, number rpc.BlockNumber) ([]*CallTrace, error) {
+	block, err := api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return api.blockTraces(ctx, block)
+}
+
+// Transaction returns the call traces of the given transaction.
+func (api *PrivateTraceAPI) Transaction(ctx context.Context, hash common.Hash) ([]*CallTrace, error) {
+	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(api.eth.ChainDb(), hash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not found", hash)
+	}
+	block := api.eth.blockchain.GetBlock(blockHash, blockNumber)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	// Serve the traces from the index if available, otherwise trace the transaction only
+	if traces, err := readIndexedTraces(api.eth.ChainDb(), block); traces != nil || err != nil {
+		var result []*CallTrace
+		for _, trace := range traces {
+			if trace.TransactionHash == hash {
+				result = append(result, trace)
+			}
+		}
+		return result, err
+	}
+	frame, err := api.traceTransaction(ctx, blockHash, index, callTraceConfig)
+	if err != nil {
+		return nil, err
+	}
+	call := new(callFrame)
+	if err := json.Unmarshal(frame, call); err != nil {
+		return nil, err
+	}
+	return flattenCallFrame(call, nil, block, tx, index), nil
+}
+
+// traceTransaction re-executes the given transaction with the tracer described
+// by the configuration, returning its raw result.
+func (api *PrivateTraceAPI) traceTransaction(ctx context.Context, blockHash common.Hash, index uint64, config *TraceConfig) (json.RawMessage, error) {
+	msg, vmctx, statedb, err := api.debug.computeTxEnv(blockHash, int(index), defaultTraceReexec)
+	if err != nil {
+		return nil, err
+	}
+	res, err := api.debug.traceTx(ctx, msg, vmctx, statedb, config)
+	if err != nil {
+		return nil, err
+	}
+	return res.(json.RawMessage), nil
+}
+
+// blockTraces returns the call traces of all the transactions in a block from the
+// trace index if available, or by tracing the block otherwise.
+func (api *PrivateTraceAPI) blockTraces(ctx context.Context, block *types.Block) ([]*CallTrace, error) {
+	if traces, err := readIndexedTraces(api.eth.ChainDb(), block); traces != nil || err != nil {
+		return traces, err
+	}
+	return traceBlockCalls(ctx, api.debug, block)
+}
+
+// readIndexedTraces retrieves the call traces of a block from the trace index,
+// returning nil if the block was not indexed.
+func readIndexedTraces(db rawdb.DatabaseReader, block *types.Block) ([]*CallTrace, error) {
+	blob := rawdb.ReadBlockTraces(db, block.Hash(), block.NumberU64())
+	if blob == nil {
+		return nil, nil
+	}
+	traces := make([]*CallTrace, 0)
+	if err := json.Unmarshal(blob, &traces); err != nil {
+		return nil, err
+	}
+	return traces, nil
+}
+
+// traceBlockCalls re-executes all the transactions in a block with the call
+// tracer attached, returning the flattened call traces.
+func traceBlockCalls(ctx context.Context, debug *PrivateDebugAPI, block *types.Block) ([]*CallTrace, error) {
+	traces := make([]*CallTrace, 0)
+	if len(block.Transactions()) == 0 {
+		return traces, nil
+	}
+	results, err := debug.traceBlock(ctx, block, callTraceConfig)
+	if err != nil {
+		return nil, err
+	}
+	for i, tx := range block.Transactions() {
+		if results[i].Error != "" {
+			return nil, fmt.Errorf("tracing transaction %x failed: %v", tx.Hash(), results[i].Error)
+		}
+		call := new(callFrame)
+		if err := json.Unmarshal(results[i].Result.(json.RawMessage), call); err != nil {
+			return nil, err
+		}
+		traces = append(traces, flattenCallFrame(call, nil, block, tx, uint64(i))...)
+	}
+	return traces, nil
+}
+
+// TraceFilterArgs are the criteria of a trace_filter request.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *uint64          `json:"after"`
+	Count       *uint64          `json:"count"`
+}
+
+// matches checks whether a call trace satisfies the address criteria. Traces
+// match if their sender is in the from set and their recipient in the to set,
+// with empty sets matching everything.
+func (args *TraceFilterArgs) matches(trace *CallTrace) bool {
+	contains := func(set []common.Address, addrs ...*common.Address) bool {
+		if len(set) == 0 {
+			return true
+		}
+		for _, addr := range addrs {
+			if addr == nil {
+				continue
+			}
+			for _, want := range set {
+				if *addr == want {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	var created *common.Address
+	if trace.Result != nil {
+		created = trace.Result.Address
+	}
+	return contains(args.FromAddress, trace.Action.From, trace.Action.Address) &&
+		contains(args.ToAddress, trace.Action.To, trace.Action.RefundAddress, created)
+}
+
+// Filter returns the call traces within a block range matching the given sender
+// and recipient addresses, skipping the first After matches and returning at
+// most Count of them.
+func (api *PrivateTraceAPI) Filter(ctx context.Context, args TraceFilterArgs) ([]*CallTrace, error) {
+	// Resolve the block range to filter
+	head := api.eth.blockchain.CurrentBlock().NumberU64()
+
+	from, to := uint64(0), head
+	if args.FromBlock != nil && *args.FromBlock >= 0 {
+		from = uint64(*args.FromBlock)
+	}
+	if args.ToBlock != nil && *args.ToBlock >= 0 {
+		to = uint64(*args.ToBlock)
+	}
+	if to > head {
+		to = head
+	}
+	if from > to {
+		return nil, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	if to-from >= traceFilterMaxBlocks {
+		return nil, fmt.Errorf("block range %d-%d exceeds limit of %d blocks", from, to, traceFilterMaxBlocks)
+	}
+	var (
+		skip  uint64
+		count = ^uint64(0)
+	)
+	if args.After != nil {
+		skip = *args.After
+	}
+	if args.Count != nil {
+		count = *args.Count
+	}
+	// Gather the matching traces block by block
+	result := make([]*CallTrace, 0)
+	for number := from; number <= to && uint64(len(result)) < count; number++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		block := api.eth.blockchain.GetBlockByNumber(number)
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+		traces, err := api.blockTraces(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+		for _, trace := range traces {
+			if !args.matches(trace) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			result = append(result, trace)
+			if uint64(len(result)) >= count {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// TraceResults is the outcome of replaying a transaction with the requested
+// trace types.
+type TraceResults struct {
+	Output    hexutil.Bytes                        `json:"output"`
+	StateDiff map[common.Address]*AccountStateDiff `json:"stateDiff"`
+	Trace     []*CallTrace                         `json:"trace"`
+	VmTrace   interface{}                          `json:"vmTrace"`
+}
+
+// AccountStateDiff are the changes done to an account by a transaction. Every
+// field is either "=" if unchanged, or an object with a "+" (created), "-"
+// (deleted) or "*" (modified, with from and to values) key.
+type AccountStateDiff struct {
+	Balance interface{}                 `json:"balance"`
+	Nonce   interface{}                 `json:"nonce"`
+	Code    interface{}                 `json:"code"`
+	Storage map[common.Hash]interface{} `json:"storage"`
+}
+
+// prestateAccount is the state of an account as reported by the prestateTracer
+// in diff mode.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   *uint64                     `json:"nonce"`
+	Code    *hexutil.Bytes              `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// fullAccountDiff reports all the fields of an account as created or deleted,
+// depending on the marker ("+" or "-") used.
+func fullAccountDiff(account *prestateAccount, marker string) *AccountStateDiff {
+	var (
+		balance = (*hexutil.Big)(new(big.Int))
+		nonce   hexutil.Uint64
+		code    = hexutil.Bytes{}
+	)
+	if account.Balance != nil {
+		balance = account.Balance
+	}
+	if account.Nonce != nil {
+		nonce = hexutil.Uint64(*account.Nonce)
+	}
+	if account.Code != nil {
+		code = *account.Code
+	}
+	diff := &AccountStateDiff{
+		Balance: map[string]interface{}{marker: balance},
+		Nonce:   map[string]interface{}{marker: nonce},
+		Code:    map[string]interface{}{marker: code},
+		Storage: make(map[common.Hash]interface{}),
+	}
+	for key, val := range account.Storage {
+		diff.Storage[key] = map[string]interface{}{marker: val}
+	}
+	return diff
+}
+
+// modifiedDiff returns the diff of an account field modified from one value to
+// another one.
+func modifiedDiff(from, to interface{}) interface{} {
+	return map[string]interface{}{"*": map[string]interface{}{"from": from, "to": to}}
+}
+
+// newAccountStateDiff converts the pre and post states of an account reported
+// by the prestateTracer into the format of the trace namespace. Only modified
+// fields are reported in both states, apart from created and deleted accounts,
+// which are missing from the pre or post state respectively.
+func newAccountStateDiff(pre, post *prestateAccount) *AccountStateDiff {
+	switch {
+	case pre == nil:
+		return fullAccountDiff(post, "+")
+	case post == nil:
+		return fullAccountDiff(pre, "-")
+	}
+	diff := &AccountStateDiff{Balance: "=", Nonce: "=", Code: "=", Storage: make(map[common.Hash]interface{})}
+	if pre.Balance != nil && post.Balance != nil {
+		diff.Balance = modifiedDiff(pre.Balance, post.Balance)
+	}
+	if pre.Nonce != nil && post.Nonce != nil {
+		diff.Nonce = modifiedDiff(hexutil.Uint64(*pre.Nonce), hexutil.Uint64(*post.Nonce))
+	}
+	if pre.Code != nil && post.Code != nil {
+		diff.Code = modifiedDiff(pre.Code, post.Code)
+	}
+	// Storage slots missing on either side are empty
+	for key, val := range pre.Storage {
+		diff.Storage[key] = modifiedDiff(val, post.Storage[key])
+	}
+	for key, val := range post.Storage {
+		if _, ok := pre.Storage[key]; !ok {
+			diff.Storage[key] = modifiedDiff(common.Hash{}, val)
+		}
+	}
+	return diff
+}
+
+// ReplayTransaction re-executes the given transaction, returning the requested
+// trace types. Supported types are "trace" for the call traces and "stateDiff"
+// for the state changes done by the transaction.
+func (api *PrivateTraceAPI) ReplayTransaction(ctx context.Context, hash common.Hash, traceTypes []string) (*TraceResults, error) {
+	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(api.eth.ChainDb(), hash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not found", hash)
+	}
+	block := api.eth.blockchain.GetBlock(blockHash, blockNumber)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	var withTrace, withStateDiff bool
+	for _, typ := range traceTypes {
+		switch typ {
+		case "trace":
+			withTrace = true
+		case "stateDiff":
+			withStateDiff = true
+		default:
+			return nil, fmt.Errorf("unsupported trace type %q", typ)
+		}
+	}
+	// The call trace is always needed for the output of the transaction
+	res, err := api.traceTransaction(ctx, blockHash, index, callTraceConfig)
+	if err != nil {
+		return nil, err
+	}
+	call := new(callFrame)
+	if err := json.Unmarshal(res, call); err != nil {
+		return nil, err
+	}
+	result := new(TraceResults)
+	if call.Output != nil {
+		result.Output = *call.Output
+	} else {
+		result.Output = hexutil.Bytes{}
+	}
+	if withTrace {
+		result.Trace = flattenCallFrame(call, nil, block, tx, index)
+	}
+	if withStateDiff {
+		res, err := api.traceTransaction(ctx, blockHash, index, stateDiffTraceConfig)
+		if err != nil {
+			return nil, err
+		}
+		var diff struct {
+			Pre  map[common.Address]*prestateAccount `json:"pre"`
+			Post map[common.Address]*prestateAccount `json:"post"`
+		}
+		if err := json.Unmarshal(res, &diff); err != nil {
+			return nil, err
+		}
+		result.StateDiff = make(map[common.Address]*AccountStateDiff)
+		for addr, pre := range diff.Pre {
+			result.StateDiff[addr] = newAccountStateDiff(pre, diff.Post[addr])
+		}
+		for addr, post := range diff.Post {
+			if _, ok := diff.Pre[addr]; !ok {
+				result.StateDiff[addr] = newAccountStateDiff(nil, post)
+			}
+		}
+	}
+	return result, nil
+}
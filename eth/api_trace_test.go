@@ -0,0 +1,316 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/consensus/ethash"
+	"github.com/simplechain-org/go-simplechain/core"
+	"github.com/simplechain-org/go-simplechain/core/rawdb"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/core/vm"
+	"github.com/simplechain-org/go-simplechain/ethdb"
+	"github.com/simplechain-org/go-simplechain/params"
+	"github.com/simplechain-org/go-simplechain/rpc"
+)
+
+var (
+	traceContract  = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	traceRecipient = common.HexToAddress("0x2000000000000000000000000000000000000002")
+)
+
+// newTraceTestBackend creates a Simplechain service with a chain of two blocks,
+// the first of which contains a transaction calling a contract which transfers
+// some of the received value to a plain account.
+func newTraceTestBackend(t *testing.T) (*Simplechain, *types.Transaction) {
+	// call(0xffff, recipient, 1, 0, 0, 0, 0); stop
+	code := append([]byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x01, 0x73}, traceRecipient.Bytes()...)
+	code = append(code, 0x61, 0xff, 0xff, 0xf1, 0x00)
+
+	var (
+		engine = ethash.NewFaker()
+		db     = ethdb.NewMemDatabase()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank:      {Balance: big.NewInt(1000000)},
+				traceContract: {Balance: big.NewInt(0), Code: code},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	tx, _ := types.SignTx(types.NewTransaction(0, traceContract, big.NewInt(10), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, testBankKey)
+
+	chain, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 2, func(i int, block *core.BlockGen) {
+		if i == 0 {
+			block.AddTx(tx)
+		}
+	})
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	eth := &Simplechain{
+		config:      &Config{SafeDepth: 1, FinalizedDepth: 2},
+		chainDb:     db,
+		chainConfig: gspec.Config,
+		engine:      engine,
+		blockchain:  blockchain,
+	}
+	eth.APIBackend = &EthAPIBackend{eth, nil}
+	return eth, tx
+}
+
+// Tests that the safe and finalized block tags are resolved to the blocks the
+// configured depths below the head when tracing and dumping blocks.
+func TestTraceBlockTags(t *testing.T) {
+	eth, tx := newTraceTestBackend(t)
+	api := NewPrivateTraceAPI(eth)
+
+	traces, err := api.Block(context.Background(), rpc.SafeBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to trace safe block: %v", err)
+	}
+	if len(traces) != 2 || traces[0].BlockNumber != 1 || traces[0].TransactionHash != tx.Hash() {
+		t.Errorf("safe block traces mismatch: %+v", traces)
+	}
+	if traces, err := api.Block(context.Background(), rpc.FinalizedBlockNumber); err != nil || len(traces) != 0 {
+		t.Errorf("finalized block traces mismatch: %v, %v", traces, err)
+	}
+	if _, err := api.Block(context.Background(), rpc.PendingBlockNumber); err == nil {
+		t.Error("traced the pending block")
+	}
+	stream, err := api.debug.TraceBlockByNumber(context.Background(), rpc.SafeBlockNumber, nil)
+	if err != nil {
+		t.Fatalf("failed to trace safe block: %v", err)
+	}
+	var results int
+	if err := stream(func(interface{}) error { results++; return nil }); err != nil {
+		t.Fatalf("failed to stream safe block traces: %v", err)
+	}
+	if results != 1 {
+		t.Errorf("safe block trace count mismatch: have %d, want 1", results)
+	}
+	debug := NewPublicDebugAPI(eth)
+	dump, err := debug.DumpBlock(rpc.FinalizedBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to dump finalized block: %v", err)
+	}
+	if want := eth.blockchain.Genesis().Root(); dump.Root != common.Bytes2Hex(want[:]) {
+		t.Errorf("finalized dump root mismatch: have %s, want %x", dump.Root, want)
+	}
+}
+
+// Tests that the call traces of a block are flattened in the format of the
+// trace namespace, and that the trace index serves the same traces.
+func TestTraceBlock(t *testing.T) {
+	eth, tx := newTraceTestBackend(t)
+	api := NewPrivateTraceAPI(eth)
+
+	traces, err := api.Block(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("trace count mismatch: have %d, want 2", len(traces))
+	}
+	outer, inner := traces[0], traces[1]
+	if outer.Type != "call" || outer.Action.CallType != "call" || *outer.Action.From != testBank || *outer.Action.To != traceContract {
+		t.Errorf("outer call mismatch: %+v", outer.Action)
+	}
+	if outer.Action.Value.ToInt().Int64() != 10 || outer.Subtraces != 1 || len(outer.TraceAddress) != 0 || outer.Result == nil {
+		t.Errorf("outer call details mismatch: %+v", outer)
+	}
+	if *inner.Action.From != traceContract || *inner.Action.To != traceRecipient || inner.Action.Value.ToInt().Int64() != 1 {
+		t.Errorf("inner call mismatch: %+v", inner.Action)
+	}
+	if !reflect.DeepEqual(inner.TraceAddress, []int{0}) || inner.Subtraces != 0 {
+		t.Errorf("inner call position mismatch: %+v", inner)
+	}
+	for _, trace := range traces {
+		if trace.TransactionHash != tx.Hash() || trace.TransactionPosition != 0 || trace.BlockNumber != 1 {
+			t.Errorf("trace context mismatch: %+v", trace)
+		}
+	}
+	// Empty blocks have no traces
+	if traces, err := api.Block(context.Background(), 2); err != nil || len(traces) != 0 {
+		t.Errorf("empty block traces mismatch: %v, %v", traces, err)
+	}
+	// Index the block and ensure the traces are served from the index
+	indexer := &TraceIndexer{db: eth.ChainDb(), debug: NewPrivateDebugAPI(eth.chainConfig, eth)}
+	if err := indexer.Reset(0, common.Hash{}); err != nil {
+		t.Fatalf("failed to reset indexer: %v", err)
+	}
+	for number := uint64(0); number <= 2; number++ {
+		indexer.Process(eth.blockchain.GetHeaderByNumber(number))
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatalf("failed to commit index: %v", err)
+	}
+	block := eth.blockchain.GetBlockByNumber(1)
+	if rawdb.ReadBlockTraces(eth.ChainDb(), block.Hash(), 1) == nil {
+		t.Fatalf("block traces not indexed")
+	}
+	indexed, err := api.Block(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to retrieve indexed traces: %v", err)
+	}
+	want, _ := json.Marshal(traces)
+	have, _ := json.Marshal(indexed)
+	if string(have) != string(want) {
+		t.Errorf("indexed traces mismatch:\nhave %s\nwant %s", have, want)
+	}
+	// Transaction traces must match the ones of the block
+	txTraces, err := api.Transaction(context.Background(), tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	if have, _ := json.Marshal(txTraces); string(have) != string(want) {
+		t.Errorf("transaction traces mismatch:\nhave %s\nwant %s", have, want)
+	}
+}
+
+// Tests that traces are filtered by sender and recipient and paginated.
+func TestTraceFilter(t *testing.T) {
+	eth, _ := newTraceTestBackend(t)
+	api := NewPrivateTraceAPI(eth)
+
+	var (
+		from  = rpc.BlockNumber(0)
+		to    = rpc.LatestBlockNumber
+		one   = uint64(1)
+		zero  = uint64(0)
+		tests = []struct {
+			args  TraceFilterArgs
+			calls []common.Address // Recipients of the expected traces
+		}{
+			{TraceFilterArgs{}, []common.Address{traceContract, traceRecipient}},
+			{TraceFilterArgs{FromBlock: &from, ToBlock: &to}, []common.Address{traceContract, traceRecipient}},
+			{TraceFilterArgs{FromAddress: []common.Address{testBank}}, []common.Address{traceContract}},
+			{TraceFilterArgs{ToAddress: []common.Address{traceRecipient}}, []common.Address{traceRecipient}},
+			{TraceFilterArgs{FromAddress: []common.Address{testBank}, ToAddress: []common.Address{traceRecipient}}, nil},
+			{TraceFilterArgs{After: &one}, []common.Address{traceRecipient}},
+			{TraceFilterArgs{Count: &one}, []common.Address{traceContract}},
+			{TraceFilterArgs{Count: &zero}, nil},
+		}
+	)
+	for i, tt := range tests {
+		traces, err := api.Filter(context.Background(), tt.args)
+		if err != nil {
+			t.Fatalf("test %d: failed to filter traces: %v", i, err)
+		}
+		if len(traces) != len(tt.calls) {
+			t.Errorf("test %d: trace count mismatch: have %d, want %d", i, len(traces), len(tt.calls))
+			continue
+		}
+		for j, trace := range traces {
+			if *trace.Action.To != tt.calls[j] {
+				t.Errorf("test %d, trace %d: recipient mismatch: have %x, want %x", i, j, *trace.Action.To, tt.calls[j])
+			}
+		}
+	}
+	// Invalid ranges must be rejected
+	high := rpc.BlockNumber(2)
+	if _, err := api.Filter(context.Background(), TraceFilterArgs{FromBlock: &high, ToBlock: &from}); err == nil {
+		t.Errorf("inverted block range accepted")
+	}
+}
+
+// Tests that replaying a transaction reports its call traces and state changes.
+func TestTraceReplayTransaction(t *testing.T) {
+	eth, tx := newTraceTestBackend(t)
+	api := NewPrivateTraceAPI(eth)
+
+	res, err := api.ReplayTransaction(context.Background(), tx.Hash(), []string{"trace", "stateDiff"})
+	if err != nil {
+		t.Fatalf("failed to replay transaction: %v", err)
+	}
+	if len(res.Trace) != 2 {
+		t.Errorf("trace count mismatch: have %d, want 2", len(res.Trace))
+	}
+	// The recipient is created by the transfer
+	recipient := res.StateDiff[traceRecipient]
+	if recipient == nil {
+		t.Fatalf("recipient missing from state diff")
+	}
+	if balance, ok := recipient.Balance.(map[string]interface{}); !ok || balance["+"].(*hexutil.Big).ToInt().Int64() != 1 {
+		t.Errorf("recipient balance diff mismatch: %v", recipient.Balance)
+	}
+	// The contract keeps the rest of the value, its code is unchanged
+	contract := res.StateDiff[traceContract]
+	if contract == nil {
+		t.Fatalf("contract missing from state diff")
+	}
+	want, _ := json.Marshal(map[string]interface{}{"*": map[string]interface{}{"from": "0x0", "to": "0x9"}})
+	if have, _ := json.Marshal(contract.Balance); string(have) != string(want) {
+		t.Errorf("contract balance diff mismatch: have %s, want %s", have, want)
+	}
+	if contract.Code != "=" {
+		t.Errorf("contract code diff mismatch: have %v, want =", contract.Code)
+	}
+	if _, err := api.ReplayTransaction(context.Background(), tx.Hash(), []string{"vmTrace"}); err == nil {
+		t.Errorf("unsupported trace type accepted")
+	}
+}
+
+// Tests that the block traces of the debug namespace are streamed to clients,
+// matching the traces collected in memory.
+func TestDebugTraceBlockStream(t *testing.T) {
+	eth, _ := newTraceTestBackend(t)
+	debug := NewPrivateDebugAPI(eth.chainConfig, eth)
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("debug", debug); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	defer server.Stop()
+	hs := httptest.NewServer(server)
+	defer hs.Close()
+
+	client, err := rpc.DialHTTP(hs.URL)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	var streamed []interface{}
+	if err := client.Call(&streamed, "debug_traceBlockByNumber", "0x1"); err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	collected, err := debug.traceBlock(context.Background(), eth.blockchain.GetBlockByNumber(1), nil)
+	if err != nil {
+		t.Fatalf("failed to trace block in memory: %v", err)
+	}
+	var want []interface{}
+	blob, _ := json.Marshal(collected)
+	json.Unmarshal(blob, &want)
+	if len(streamed) != 1 || !reflect.DeepEqual(streamed, want) {
+		t.Errorf("streamed traces mismatch:\nhave %v\nwant %v", streamed, want)
+	}
+	// Empty blocks stream empty lists
+	if err := client.Call(&streamed, "debug_traceBlockByNumber", "0x2"); err != nil || len(streamed) != 0 {
+		t.Errorf("empty block traces mismatch: %v, %v", streamed, err)
+	}
+}
@@ -19,6 +19,7 @@ package eth
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -26,6 +27,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/simplechain-org/go-simplechain/accounts/abi"
 	"github.com/simplechain-org/go-simplechain/common"
 	"github.com/simplechain-org/go-simplechain/common/hexutil"
 	"github.com/simplechain-org/go-simplechain/core"
@@ -55,9 +57,11 @@ const (
 // TraceConfig holds extra parameters to trace functions.
 type TraceConfig struct {
 	*vm.LogConfig
-	Tracer  *string
-	Timeout *string
-	Reexec  *uint64
+	Tracer       *string
+	TracerConfig json.RawMessage // Configuration of native tracers, e.g. {"diffMode": true}
+	Timeout      *string
+	Reexec       *uint64
+	RevertReason bool // Decode the revert reason of failed executions into the result
 }
 
 // txTraceResult is the result of a single transaction trace.
@@ -342,48 +346,40 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 
 // TraceBlockByNumber returns the structured logs created during the execution of
 // EVM and returns them as a JSON object.
-func (api *PrivateDebugAPI) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceConfig) ([]*txTraceResult, error) {
+func (api *PrivateDebugAPI) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceConfig) (rpc.ResultStream, error) {
 	// Fetch the block that we want to trace
-	var block *types.Block
+	block, _ := api.eth.APIBackend.BlockByNumber(ctx, number)
 
-	switch number {
-	case rpc.PendingBlockNumber:
-		block = api.eth.miner.PendingBlock()
-	case rpc.LatestBlockNumber:
-		block = api.eth.blockchain.CurrentBlock()
-	default:
-		block = api.eth.blockchain.GetBlockByNumber(uint64(number))
-	}
 	// Trace the block if it was found
 	if block == nil {
 		return nil, fmt.Errorf("block #%d not found", number)
 	}
-	return api.traceBlock(ctx, block, config)
+	return api.streamBlock(block, config)
 }
 
 // TraceBlockByHash returns the structured logs created during the execution of
 // EVM and returns them as a JSON object.
-func (api *PrivateDebugAPI) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceConfig) ([]*txTraceResult, error) {
+func (api *PrivateDebugAPI) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceConfig) (rpc.ResultStream, error) {
 	block := api.eth.blockchain.GetBlockByHash(hash)
 	if block == nil {
 		return nil, fmt.Errorf("block #%x not found", hash)
 	}
-	return api.traceBlock(ctx, block, config)
+	return api.streamBlock(block, config)
 }
 
 // TraceBlock returns the structured logs created during the execution of EVM
 // and returns them as a JSON object.
-func (api *PrivateDebugAPI) TraceBlock(ctx context.Context, blob []byte, config *TraceConfig) ([]*txTraceResult, error) {
+func (api *PrivateDebugAPI) TraceBlock(ctx context.Context, blob []byte, config *TraceConfig) (rpc.ResultStream, error) {
 	block := new(types.Block)
 	if err := rlp.Decode(bytes.NewReader(blob), block); err != nil {
 		return nil, fmt.Errorf("could not decode block: %v", err)
 	}
-	return api.traceBlock(ctx, block, config)
+	return api.streamBlock(block, config)
 }
 
 // TraceBlockFromFile returns the structured logs created during the execution of
 // EVM and returns them as a JSON object.
-func (api *PrivateDebugAPI) TraceBlockFromFile(ctx context.Context, file string, config *TraceConfig) ([]*txTraceResult, error) {
+func (api *PrivateDebugAPI) TraceBlockFromFile(ctx context.Context, file string, config *TraceConfig) (rpc.ResultStream, error) {
 	blob, err := ioutil.ReadFile(file)
 	if err != nil {
 		return nil, fmt.Errorf("could not read file: %v", err)
@@ -395,6 +391,41 @@ func (api *PrivateDebugAPI) TraceBlockFromFile(ctx context.Context, file string,
 // executes all the transactions contained within. The return value will be one item
 // per transaction, dependent on the requestd tracer.
 func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block, config *TraceConfig) ([]*txTraceResult, error) {
+	stream, err := api.prepareBlockTrace(block, config)
+	if err != nil {
+		return nil, err
+	}
+	var results []*txTraceResult
+	err = stream(ctx, func(res *txTraceResult) error {
+		results = append(results, res)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// streamBlock configures a new tracer according to the provided configuration,
+// and returns a stream executing all the transactions contained within while the
+// response is written, producing one item per transaction as they complete. The
+// stream runs after the request returned, so it isn't bound by its context.
+func (api *PrivateDebugAPI) streamBlock(block *types.Block, config *TraceConfig) (rpc.ResultStream, error) {
+	stream, err := api.prepareBlockTrace(block, config)
+	if err != nil {
+		return nil, err
+	}
+	return func(emit func(interface{}) error) error {
+		return stream(context.Background(), func(res *txTraceResult) error {
+			return emit(res)
+		})
+	}, nil
+}
+
+// prepareBlockTrace computes the state the given block is traced on, returning
+// a function executing all the transactions contained within concurrently and
+// passing their trace results to emit in order, as soon as they are available.
+func (api *PrivateDebugAPI) prepareBlockTrace(block *types.Block, config *TraceConfig) (func(ctx context.Context, emit func(*txTraceResult) error) error, error) {
 	// Create the parent state database
 	if err := api.eth.engine.VerifyHeader(api.eth.blockchain, block.Header(), true); err != nil {
 		return nil, err
@@ -411,65 +442,101 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 	if err != nil {
 		return nil, err
 	}
-	// Execute all the transaction contained within the block concurrently
-	var (
-		signer = types.MakeSigner(api.config, block.Number())
+	return func(ctx context.Context, emit func(*txTraceResult) error) error {
+		// Execute all the transaction contained within the block concurrently
+		var (
+			signer = types.MakeSigner(api.config, block.Number())
 
-		txs     = block.Transactions()
-		results = make([]*txTraceResult, len(txs))
+			txs     = block.Transactions()
+			results = make([]*txTraceResult, len(txs))
+			traced  = make([]chan struct{}, len(txs))
 
-		pend = new(sync.WaitGroup)
-		jobs = make(chan *txTraceTask, len(txs))
-	)
-	threads := runtime.NumCPU()
-	if threads > len(txs) {
-		threads = len(txs)
-	}
-	for th := 0; th < threads; th++ {
+			pend   = new(sync.WaitGroup)
+			jobs   = make(chan *txTraceTask, len(txs))
+			fed    = make(chan struct{})
+			failed = make(chan error, 1)
+			quit   = make(chan struct{})
+		)
+		for i := range traced {
+			traced[i] = make(chan struct{})
+		}
+		threads := runtime.NumCPU()
+		if threads > len(txs) {
+			threads = len(txs)
+		}
+		for th := 0; th < threads; th++ {
+			pend.Add(1)
+			go func() {
+				defer pend.Done()
+
+				// Fetch and execute the next transaction trace tasks
+				for task := range jobs {
+					msg, _ := txs[task.index].AsMessage(signer)
+					vmctx := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
+
+					res, err := api.traceTx(ctx, msg, vmctx, task.statedb, config)
+					if err != nil {
+						results[task.index] = &txTraceResult{Error: err.Error()}
+					} else {
+						results[task.index] = &txTraceResult{Result: res}
+					}
+					close(traced[task.index])
+				}
+			}()
+		}
+		// Feed the transactions into the tracers in the background
 		pend.Add(1)
 		go func() {
 			defer pend.Done()
+			defer close(fed)
+			defer close(jobs)
+
+			for i, tx := range txs {
+				// Send the trace task over for execution, unless the stream was aborted
+				select {
+				case <-quit:
+					return
+				default:
+				}
+				jobs <- &txTraceTask{statedb: statedb.Copy(), index: i}
 
-			// Fetch and execute the next transaction trace tasks
-			for task := range jobs {
-				msg, _ := txs[task.index].AsMessage(signer)
+				// Generate the next state snapshot fast without tracing
+				msg, _ := tx.AsMessage(signer)
 				vmctx := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
 
-				res, err := api.traceTx(ctx, msg, vmctx, task.statedb, config)
-				if err != nil {
-					results[task.index] = &txTraceResult{Error: err.Error()}
-					continue
+				vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{})
+				if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+					failed <- err
+					return
 				}
-				results[task.index] = &txTraceResult{Result: res}
+				// Finalize the state so any modifications are written to the trie
+				statedb.Finalise(true)
 			}
 		}()
-	}
-	// Feed the transactions into the tracers and return
-	var failed error
-	for i, tx := range txs {
-		// Send the trace task over for execution
-		jobs <- &txTraceTask{statedb: statedb.Copy(), index: i}
-
-		// Generate the next state snapshot fast without tracing
-		msg, _ := tx.AsMessage(signer)
-		vmctx := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
+		defer pend.Wait()
+		defer close(quit)
 
-		vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{})
-		if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
-			failed = err
-			break
+		// Emit the results in order, releasing them once passed on. If execution
+		// failed in between, abort
+		for i := range txs {
+			select {
+			case <-traced[i]:
+			case err := <-failed:
+				return err
+			}
+			if err := emit(results[i]); err != nil {
+				return err
+			}
+			results[i] = nil
 		}
-		// Finalize the state so any modifications are written to the trie
-		statedb.Finalise(true)
-	}
-	close(jobs)
-	pend.Wait()
-
-	// If execution failed in between, abort
-	if failed != nil {
-		return nil, failed
-	}
-	return results, nil
+		<-fed
+		select {
+		case err := <-failed:
+			return err
+		default:
+			return nil
+		}
+	}, nil
 }
 
 // computeStateDB retrieves the state database associated with a certain block.
@@ -566,44 +633,76 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, hash common.Ha
 // be tracer dependent.
 func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, vmctx vm.Context, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
 	// Assemble the structured logger or the JavaScript tracer
-	var (
-		tracer vm.Tracer
-		err    error
-	)
+	tracer, cancel, err := newTracer(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	// Run the transaction with tracing enabled.
+	vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{Debug: true, Tracer: tracer})
+
+	ret, gas, failed, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	result, err := tracerResult(tracer, ret, gas, failed)
+	if err != nil {
+		return nil, err
+	}
+	// Attach the revert reason to structured traces if requested
+	if res, ok := result.(*ethapi.ExecutionResult); ok && failed && config != nil && config.RevertReason {
+		if reason, err := abi.UnpackRevert(ret); err == nil {
+			res.RevertReason = reason
+		}
+	}
+	return result, nil
+}
+
+// newTracer assembles the structured logger or the JavaScript tracer requested
+// by the trace configuration. The returned cancel function must be invoked once
+// tracing finished to release the resources tracking the trace timeout.
+func newTracer(ctx context.Context, config *TraceConfig) (vm.Tracer, context.CancelFunc, error) {
 	switch {
 	case config != nil && config.Tracer != nil:
 		// Define a meaningful timeout of a single transaction trace
 		timeout := defaultTraceTimeout
 		if config.Timeout != nil {
+			var err error
 			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		}
-		// Constuct the JavaScript tracer to execute with
-		if tracer, err = tracers.New(*config.Tracer); err != nil {
-			return nil, err
+		// Construct the tracer to execute with, preferring native implementations
+		// over the JavaScript ones
+		tracer, native, err := tracers.NewNative(*config.Tracer, config.TracerConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !native {
+			if tracer, err = tracers.New(*config.Tracer); err != nil {
+				return nil, nil, err
+			}
 		}
 		// Handle timeouts and RPC cancellations
 		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
 		go func() {
 			<-deadlineCtx.Done()
-			tracer.(*tracers.Tracer).Stop(errors.New("execution timeout"))
+			tracer.Stop(errors.New("execution timeout"))
 		}()
-		defer cancel()
+		return tracer, cancel, nil
 
 	case config == nil:
-		tracer = vm.NewStructLogger(nil)
+		return vm.NewStructLogger(nil), func() {}, nil
 
 	default:
-		tracer = vm.NewStructLogger(config.LogConfig)
+		return vm.NewStructLogger(config.LogConfig), func() {}, nil
 	}
-	// Run the transaction with tracing enabled.
-	vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{Debug: true, Tracer: tracer})
+}
 
-	ret, gas, failed, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
-	if err != nil {
-		return nil, fmt.Errorf("tracing failed: %v", err)
-	}
+// tracerResult formats the output of a tracer after it traced the execution of
+// a message.
+func tracerResult(tracer vm.Tracer, ret []byte, gas uint64, failed bool) (interface{}, error) {
 	// Depending on the tracer type, format and return the output
 	switch tracer := tracer.(type) {
 	case *vm.StructLogger:
@@ -614,7 +713,7 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 			StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
 		}, nil
 
-	case *tracers.Tracer:
+	case tracers.ResultTracer:
 		return tracer.GetResult()
 
 	default:
@@ -622,6 +721,72 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 	}
 }
 
+// BundleTraceConfig holds extra parameters to the call bundle simulation.
+type BundleTraceConfig struct {
+	TraceConfig
+	StateOverrides *ethapi.StateOverride
+	BlockOverrides *ethapi.BlockOverrides
+}
+
+// bundleCallResult is the result of a single traced call of a bundle.
+type bundleCallResult struct {
+	*ethapi.CallResult
+	Trace interface{} `json:"trace,omitempty"`
+}
+
+// CallBundle executes a sequence of calls on the state of the given block number,
+// with the state changes of each call carried over to the next ones, returning
+// the result of every call along with its trace. It doesn't make any changes in
+// the state/blockchain.
+func (api *PrivateDebugAPI) CallBundle(ctx context.Context, bundle []ethapi.CallArgs, blockNr rpc.BlockNumber, config *BundleTraceConfig) ([]*bundleCallResult, error) {
+	statedb, header, err := api.eth.APIBackend.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	var (
+		traceConfig    *TraceConfig
+		blockOverrides *ethapi.BlockOverrides
+	)
+	if config != nil {
+		if err := config.StateOverrides.Apply(statedb); err != nil {
+			return nil, err
+		}
+		traceConfig, blockOverrides = &config.TraceConfig, config.BlockOverrides
+	}
+	// Trace each call of the bundle with a freshly configured tracer
+	var (
+		results = make([]*bundleCallResult, len(bundle))
+		tracer  vm.Tracer
+		cancel  = func() {}
+	)
+	defer func() { cancel() }()
+
+	hooks := &ethapi.BundleHooks{
+		Config: func(i int) (vm.Config, error) {
+			var err error
+			if tracer, cancel, err = newTracer(ctx, traceConfig); err != nil {
+				cancel = func() {}
+				return vm.Config{}, err
+			}
+			return vm.Config{Debug: true, Tracer: tracer}, nil
+		},
+		Done: func(i int, result *ethapi.CallResult) error {
+			defer cancel()
+
+			trace, err := tracerResult(tracer, result.ReturnValue, uint64(result.GasUsed), result.Error != "")
+			if err != nil {
+				return err
+			}
+			results[i] = &bundleCallResult{CallResult: result, Trace: trace}
+			return nil
+		},
+	}
+	if _, err := ethapi.ApplyBundle(ctx, api.eth.APIBackend, bundle, statedb, header, blockOverrides, hooks); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
 // computeTxEnv returns the execution environment of a certain transaction.
 func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, reexec uint64) (core.Message, vm.Context, *state.StateDB, error) {
 	// Create the parent state database
@@ -81,6 +81,8 @@ type Simplechain struct {
 
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
+	traceIndexer  *core.ChainIndexer             // Call trace indexer operating during block imports, if enabled
+	logIndexer    *core.ChainIndexer             // Log address and topic indexer operating during block imports, if enabled
 
 	APIBackend *EthAPIBackend
 
@@ -144,7 +146,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Simplechain, error) {
 	}
 	var (
 		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, ReceiptsLimit: config.ReceiptsHistory}
 	)
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, eth.chainConfig, eth.engine, vmConfig)
 	if err != nil {
@@ -157,10 +159,21 @@ func New(ctx *node.ServiceContext, config *Config) (*Simplechain, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
+	if config.TraceIndex {
+		eth.traceIndexer = NewTraceIndexer(eth)
+		eth.traceIndexer.Start(eth.blockchain)
+	}
+	if config.LogIndex {
+		eth.logIndexer = NewLogIndexer(eth)
+		eth.logIndexer.Start(eth.blockchain)
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.RemoteJournal != "" {
+		config.TxPool.RemoteJournal = ctx.ResolvePath(config.TxPool.RemoteJournal)
+	}
 	eth.txPool = core.NewTxPool(config.TxPool, eth.chainConfig, eth.blockchain)
 
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb); err != nil {
@@ -272,7 +285,7 @@ func (s *Simplechain) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.APIBackend, false),
+			Service:   filters.NewPublicFilterAPI(s.APIBackend, false, filters.Config{LogRangeLimit: s.config.LogQueryRangeLimit, LogResultLimit: s.config.LogQueryResultLimit}),
 			Public:    true,
 		}, {
 			Namespace: "admin",
@@ -287,6 +300,10 @@ func (s *Simplechain) APIs() []rpc.API {
 			Namespace: "debug",
 			Version:   "1.0",
 			Service:   NewPrivateDebugAPI(s.chainConfig, s),
+		}, {
+			Namespace: "trace",
+			Version:   "1.0",
+			Service:   NewPrivateTraceAPI(s),
 		}, {
 			Namespace: "net",
 			Version:   "1.0",
@@ -410,6 +427,12 @@ func (s *Simplechain) Start(srvr *p2p.Server) error {
 // Simplechain protocol.
 func (s *Simplechain) Stop() error {
 	s.bloomIndexer.Close()
+	if s.traceIndexer != nil {
+		s.traceIndexer.Close()
+	}
+	if s.logIndexer != nil {
+		s.logIndexer.Close()
+	}
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	if s.lesServer != nil {
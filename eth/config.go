@@ -50,6 +50,9 @@ var DefaultConfig = Config{
 	TrieTimeout:   60 * time.Minute,
 	GasPrice:      big.NewInt(params.GWei),
 
+	SafeDepth:      6,
+	FinalizedDepth: 12,
+
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
 		Blocks:     20,
@@ -83,6 +86,28 @@ type Config struct {
 	SyncMode  downloader.SyncMode
 	NoPruning bool
 
+	// ReceiptsHistory is the number of recent blocks to retain receipts for. Older
+	// receipts are pruned and regenerated on demand by re-executing their block.
+	// Zero retains the receipts of the entire chain.
+	ReceiptsHistory uint64 `toml:",omitempty"`
+
+	// TraceIndex enables the background indexing of the call traces of the chain
+	// for the trace namespace.
+	TraceIndex bool `toml:",omitempty"`
+
+	// LogIndex enables the background indexing of the addresses and first topics
+	// of the logs of the chain, speeding up log queries over wide block ranges.
+	LogIndex bool `toml:",omitempty"`
+
+	// Limits of the log queries served over RPC, zero meaning unlimited.
+	LogQueryRangeLimit  uint64 // Maximum number of blocks a single log query may span
+	LogQueryResultLimit int    // Maximum number of logs a single log query may return
+
+	// Confirmation depths below the chain head at which blocks are considered
+	// safe and finalized, resolving the "safe" and "finalized" block tags.
+	SafeDepth      uint64
+	FinalizedDepth uint64
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
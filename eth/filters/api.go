@@ -18,6 +18,7 @@ package filters
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,6 +29,7 @@ import (
 	"github.com/simplechain-org/go-simplechain"
 	"github.com/simplechain-org/go-simplechain/common"
 	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/core"
 	"github.com/simplechain-org/go-simplechain/core/types"
 	"github.com/simplechain-org/go-simplechain/ethdb"
 	"github.com/simplechain-org/go-simplechain/event"
@@ -38,6 +40,120 @@ var (
 	deadline = 5 * time.Minute // consider a filter inactive if it has not been polled for within deadline
 )
 
+const (
+	// logLimitErrorCode is the error code of log queries exceeding a limit of the server.
+	logLimitErrorCode = -32005
+
+	// defaultLogPageSize is the number of logs returned per page if the server
+	// does not limit the number of results of a log query.
+	defaultLogPageSize = 1000
+)
+
+// Config contains the server side limits applied to the log queries of the
+// filter API. Zero values disable the respective limit.
+type Config struct {
+	LogRangeLimit  uint64 // Maximum number of blocks a single log query may span
+	LogResultLimit int    // Maximum number of logs a single log query may return
+}
+
+// limitError is returned for log queries exceeding a limit of the server.
+type limitError struct {
+	message string
+	limit   uint64
+}
+
+// Error implements error, returning the description of the exceeded limit.
+func (e *limitError) Error() string {
+	return e.message
+}
+
+// ErrorCode returns the JSON error code for a log query exceeding a limit.
+// See: https://github.com/ethereum/wiki/wiki/JSON-RPC-Error-Codes-Improvement-Proposal
+func (e *limitError) ErrorCode() int {
+	return logLimitErrorCode
+}
+
+// ErrorData returns the value of the exceeded limit.
+func (e *limitError) ErrorData() interface{} {
+	return map[string]interface{}{"limit": hexutil.Uint64(e.limit)}
+}
+
+// NewRangeFilter creates a filter for the given block range, rejecting ranges
+// spanning more blocks than the range limit.
+func (c Config) NewRangeFilter(ctx context.Context, backend Backend, begin, end int64, addresses []common.Address, topics [][]common.Hash) (*Filter, error) {
+	if limit := c.LogRangeLimit; limit > 0 {
+		from, to := uint64(begin), uint64(end)
+		if begin < 0 || end < 0 {
+			header, err := backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+			if header == nil || err != nil {
+				return nil, err
+			}
+			if begin < 0 {
+				from = header.Number.Uint64()
+			}
+			if end < 0 {
+				to = header.Number.Uint64()
+			}
+		}
+		if to >= from && to-from >= limit {
+			return nil, &limitError{fmt.Sprintf("query spans %d blocks, exceeding the limit of %d blocks", to-from+1, limit), limit}
+		}
+	}
+	return NewRangeFilter(backend, begin, end, addresses, topics), nil
+}
+
+// FilterLogs runs a filter, rejecting it if it matches more logs than the
+// result limit.
+func (c Config) FilterLogs(ctx context.Context, filter *Filter) ([]*types.Log, error) {
+	limit := c.LogResultLimit
+	filter.SetLimit(limit)
+
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(logs) > limit {
+		return nil, &limitError{fmt.Sprintf("query returned more than %d results, narrow the block range or use eth_getLogsPage", limit), uint64(limit)}
+	}
+	return logs, nil
+}
+
+// LogCursor is the position of a log within the chain, marking where a paginated
+// log query continues. It is encoded as an opaque hex string.
+type LogCursor struct {
+	BlockNumber uint64
+	LogIndex    uint
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c LogCursor) MarshalText() ([]byte, error) {
+	enc := make([]byte, 16)
+	binary.BigEndian.PutUint64(enc, c.BlockNumber)
+	binary.BigEndian.PutUint64(enc[8:], uint64(c.LogIndex))
+	return hexutil.Bytes(enc).MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *LogCursor) UnmarshalText(input []byte) error {
+	var dec hexutil.Bytes
+	if err := dec.UnmarshalText(input); err != nil {
+		return err
+	}
+	if len(dec) != 16 {
+		return errors.New("invalid log cursor")
+	}
+	c.BlockNumber = binary.BigEndian.Uint64(dec)
+	c.LogIndex = uint(binary.BigEndian.Uint64(dec[8:]))
+	return nil
+}
+
+// LogsPage is a page of the logs matching a query, along with the cursor to
+// retrieve the next page with. The cursor is omitted from the last page.
+type LogsPage struct {
+	Logs   []*types.Log `json:"logs"`
+	Cursor *LogCursor   `json:"cursor,omitempty"`
+}
+
 // filter is a helper struct that holds meta information over the filter type
 // and associated subscription in the event system.
 type filter struct {
@@ -57,17 +173,20 @@ type PublicFilterAPI struct {
 	quit      chan struct{}
 	chainDb   ethdb.Database
 	events    *EventSystem
+	config    Config
 	filtersMu sync.Mutex
 	filters   map[rpc.ID]*filter
 }
 
-// NewPublicFilterAPI returns a new PublicFilterAPI instance.
-func NewPublicFilterAPI(backend Backend, lightMode bool) *PublicFilterAPI {
+// NewPublicFilterAPI returns a new PublicFilterAPI instance, serving log queries
+// within the limits of the given config.
+func NewPublicFilterAPI(backend Backend, lightMode bool, config Config) *PublicFilterAPI {
 	api := &PublicFilterAPI{
 		backend: backend,
 		mux:     backend.EventMux(),
 		chainDb: backend.ChainDb(),
 		events:  NewEventSystem(backend.EventMux(), backend, lightMode),
+		config:  config,
 		filters: make(map[rpc.ID]*filter),
 	}
 	go api.timeoutLoop()
@@ -166,6 +285,54 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	return rpcSub, nil
 }
 
+// txPoolChange is the notification sent to "txpoolEvents" subscribers for every
+// transaction entering, moving within or leaving the transaction pool.
+type txPoolChange struct {
+	Hash        common.Hash         `json:"hash"`
+	Reason      core.TxChangeReason `json:"reason"`
+	Replacement *common.Hash        `json:"replacement,omitempty"`
+}
+
+// TxpoolEvents creates a subscription that is triggered each time a transaction
+// enters, moves within or leaves the transaction pool, reporting the reason of
+// the change (e.g. replaced, evicted, expired or included).
+func (api *PublicFilterAPI) TxpoolEvents(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan core.TxPoolEvent, 128)
+		eventsSub := api.backend.SubscribeTxPoolEvent(events)
+		defer eventsSub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				for _, change := range ev.Changes {
+					notification := &txPoolChange{Hash: change.Tx.Hash(), Reason: change.Reason}
+					if change.Replacement != nil {
+						hash := change.Replacement.Hash()
+						notification.Replacement = &hash
+					}
+					notifier.Notify(rpcSub.ID, notification)
+				}
+			case <-eventsSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with eth_getFilterChanges.
 func (api *PublicFilterAPI) NewBlockFilter() rpc.ID {
@@ -229,22 +396,111 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 	return rpcSub, nil
 }
 
-// Logs creates a subscription that fires for all new log that match the given filter criteria.
-func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
+// FinalizedHeads send a notification each time a new header is finalized by the
+// confirmation depth configured on the node. Every finalized header is reported
+// in order, even if the chain head advanced by multiple blocks at once.
+func (api *PublicFilterAPI) FinalizedHeads(ctx context.Context) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	finalized, err := api.backend.HeaderByNumber(ctx, rpc.FinalizedBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if finalized == nil {
+		return nil, errors.New("finalized header unavailable")
+	}
+	// Buffer notifications until activation, no finalized header may be dropped
+	rpcSub := notifier.CreateBufferedSubscription()
+
+	// Subscribe before returning to not miss heads arriving in the meantime
+	headers := make(chan *types.Header)
+	headersSub := api.events.SubscribeNewHeads(headers)
+
+	go func() {
+		defer headersSub.Unsubscribe()
 
+		last := finalized.Number.Uint64()
+		for {
+			select {
+			case <-headers:
+				header, _ := api.backend.HeaderByNumber(context.Background(), rpc.FinalizedBlockNumber)
+				if header == nil {
+					continue
+				}
+				for number := last + 1; number <= header.Number.Uint64(); number++ {
+					h, _ := api.backend.HeaderByNumber(context.Background(), rpc.BlockNumber(number))
+					if h == nil {
+						break
+					}
+					notifier.Notify(rpcSub.ID, h)
+					last = number
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// Logs creates a subscription that fires for all new log that match the given filter criteria.
+//
+// If the criteria start at a historical block, the matching logs of the canonical
+// chain are backfilled before streaming the new ones. A resume token, made of the
+// block hash and log index of the last log received, continues a subscription
+// right after that log, first reporting the delivered logs that were since
+// reorged out of the chain as removed.
+func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria, resume *LogResumeToken) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if err := api.resolveTags(ctx, &crit); err != nil {
+		return nil, err
+	}
+	// Resolve the block to backfill historical logs from, if any
+	var (
+		backfill *logBackfill
+		err      error
+	)
+	if resume != nil {
+		if backfill, err = api.resumeLogs(ctx, crit, resume); err != nil {
+			return nil, err
+		}
+	} else if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 {
+		backfill = &logBackfill{from: crit.FromBlock.Uint64()}
+	}
+	if backfill != nil {
+		crit.FromBlock = new(big.Int).SetUint64(backfill.from)
+		if err := api.boundBackfill(ctx, crit, backfill); err != nil {
+			return nil, err
+		}
+	}
+	// Subscribe to the new logs before backfilling, so none are missed in between
 	var (
-		rpcSub      = notifier.CreateSubscription()
+		rpcSub      *rpc.Subscription
 		matchedLogs = make(chan []*types.Log)
 	)
+	if backfill != nil {
+		rpcSub = notifier.CreateBufferedSubscription()
+	} else {
+		rpcSub = notifier.CreateSubscription()
+	}
 
 	logsSub, err := api.events.SubscribeLogs(simplechain.FilterQuery(crit), matchedLogs)
 	if err != nil {
 		return nil, err
 	}
+	if backfill != nil {
+		backfill.filter = NewRangeFilter(api.backend, int64(backfill.from), int64(backfill.to), crit.Addresses, crit.Topics)
+		go api.backfillLogs(notifier, rpcSub, logsSub, matchedLogs, backfill)
+		return rpcSub, nil
+	}
 
 	go func() {
 
@@ -283,6 +539,9 @@ type FilterCriteria simplechain.FilterQuery
 //
 // In case "fromBlock" > "toBlock" an error is returned.
 func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
+	if err := api.resolveTags(context.Background(), &crit); err != nil {
+		return rpc.ID(""), err
+	}
 	logs := make(chan []*types.Log)
 	logsSub, err := api.events.SubscribeLogs(simplechain.FilterQuery(crit), logs)
 	if err != nil {
@@ -316,29 +575,109 @@ func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 
 // GetLogs returns logs matching the given argument that are stored within the state.
 func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
-	var filter *Filter
+	return api.queryLogs(ctx, crit)
+}
+
+// GetLogsPage returns a page of the logs matching the given argument, starting
+// at the cursor of the previous page if given. The size of the pages is bound by
+// the result limit of the server, and the returned cursor is omitted from the
+// last page.
+func (api *PublicFilterAPI) GetLogsPage(ctx context.Context, crit FilterCriteria, cursor *LogCursor) (*LogsPage, error) {
+	// Continue a range query from the block of the cursor
+	if cursor != nil && crit.BlockHash == nil {
+		if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 && crit.FromBlock.Uint64() > cursor.BlockNumber {
+			return nil, errors.New("log cursor before the start of the range")
+		}
+		crit.FromBlock = new(big.Int).SetUint64(cursor.BlockNumber)
+	}
+	filter, err := api.newLogFilter(ctx, crit)
+	if err != nil {
+		return nil, err
+	}
+	size := api.config.LogResultLimit
+	if size == 0 {
+		size = defaultLogPageSize
+	}
+	filter.SetLimit(size)
+
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stopped := filter.full(logs)
+
+	// Drop the logs of the cursor block already returned on the previous page
+	if cursor != nil {
+		for len(logs) > 0 && logs[0].BlockNumber == cursor.BlockNumber && logs[0].Index < cursor.LogIndex {
+			logs = logs[1:]
+		}
+	}
+	// Cut the page at the size limit, or continue after the last searched block
+	// if the filter stopped early because of the logs dropped above
+	page := &LogsPage{Logs: returnLogs(logs)}
+	switch {
+	case len(logs) > size:
+		page.Logs, page.Cursor = logs[:size], &LogCursor{BlockNumber: logs[size].BlockNumber, LogIndex: logs[size].Index}
+	case stopped:
+		page.Cursor = &LogCursor{BlockNumber: uint64(filter.begin)}
+	}
+	return page, nil
+}
+
+// newLogFilter creates the filter serving a log query, rejecting range queries
+// that span more blocks than permitted by the limits of the server.
+func (api *PublicFilterAPI) newLogFilter(ctx context.Context, crit FilterCriteria) (*Filter, error) {
 	if crit.BlockHash != nil {
 		// Block filter requested, construct a single-shot filter
-		filter = NewBlockFilter(api.backend, *crit.BlockHash, crit.Addresses, crit.Topics)
-	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if crit.FromBlock != nil {
-			begin = crit.FromBlock.Int64()
+		return NewBlockFilter(api.backend, *crit.BlockHash, crit.Addresses, crit.Topics), nil
+	}
+	// Convert the RPC block numbers into internal representations
+	if err := api.resolveTags(ctx, &crit); err != nil {
+		return nil, err
+	}
+	begin := rpc.LatestBlockNumber.Int64()
+	if crit.FromBlock != nil {
+		begin = crit.FromBlock.Int64()
+	}
+	end := rpc.LatestBlockNumber.Int64()
+	if crit.ToBlock != nil {
+		end = crit.ToBlock.Int64()
+	}
+	return api.config.NewRangeFilter(ctx, api.backend, begin, end, crit.Addresses, crit.Topics)
+}
+
+// resolveTags replaces the safe and finalized block tags bounding the range of
+// the criteria with the numbers of the blocks they currently refer to.
+func (api *PublicFilterAPI) resolveTags(ctx context.Context, crit *FilterCriteria) error {
+	for _, number := range []**big.Int{&crit.FromBlock, &crit.ToBlock} {
+		if *number == nil {
+			continue
 		}
-		end := rpc.LatestBlockNumber.Int64()
-		if crit.ToBlock != nil {
-			end = crit.ToBlock.Int64()
+		if tag := rpc.BlockNumber((*number).Int64()); tag == rpc.SafeBlockNumber || tag == rpc.FinalizedBlockNumber {
+			header, err := api.backend.HeaderByNumber(ctx, tag)
+			if err != nil {
+				return err
+			}
+			if header == nil {
+				return errors.New("confirmed block unavailable")
+			}
+			*number = new(big.Int).Set(header.Number)
 		}
-		// Construct the range filter
-		filter = NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics)
 	}
-	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
+	return nil
+}
+
+// queryLogs runs a log query, rejecting it if it exceeds the limits of the server.
+func (api *PublicFilterAPI) queryLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+	filter, err := api.newLogFilter(ctx, crit)
 	if err != nil {
 		return nil, err
 	}
-	return returnLogs(logs), err
+	logs, err := api.config.FilterLogs(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return returnLogs(logs), nil
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -367,29 +706,7 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 		return nil, fmt.Errorf("filter not found")
 	}
 
-	var filter *Filter
-	if f.crit.BlockHash != nil {
-		// Block filter requested, construct a single-shot filter
-		filter = NewBlockFilter(api.backend, *f.crit.BlockHash, f.crit.Addresses, f.crit.Topics)
-	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if f.crit.FromBlock != nil {
-			begin = f.crit.FromBlock.Int64()
-		}
-		end := rpc.LatestBlockNumber.Int64()
-		if f.crit.ToBlock != nil {
-			end = f.crit.ToBlock.Int64()
-		}
-		// Construct the range filter
-		filter = NewRangeFilter(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
-	}
-	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return returnLogs(logs), nil
+	return api.queryLogs(ctx, f.crit)
 }
 
 // GetFilterChanges returns the logs for the filter with the given id since
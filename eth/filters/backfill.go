@@ -0,0 +1,232 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/log"
+	"github.com/simplechain-org/go-simplechain/rpc"
+)
+
+// backfillPageSize is the number of logs gathered at once while backfilling a
+// log subscription, bounding the memory used by a single subscriber.
+const backfillPageSize = 1000
+
+// LogResumeToken identifies the last log delivered by a log subscription, after
+// which a reconnecting subscriber continues. It consists of the blockHash and
+// logIndex fields of the log.
+type LogResumeToken struct {
+	BlockHash common.Hash  `json:"blockHash"`
+	LogIndex  hexutil.Uint `json:"logIndex"`
+}
+
+// logBackfill describes the historical logs delivered to a log subscription
+// before streaming the new ones.
+type logBackfill struct {
+	from, to uint64          // Range of canonical blocks to backfill the logs of
+	removed  []*types.Log    // Delivered logs since reorged out, reported as removed first
+	skip     *LogResumeToken // Last log already delivered from the first block, if resuming
+	filter   *Filter         // Filter retrieving the logs of the range
+}
+
+// resumeLogs creates the backfill continuing a log subscription after the log of
+// a resume token. If the block of the token was reorged out, the delivered logs
+// of the abandoned blocks are reported as removed and the backfill starts after
+// their last canonical ancestor.
+func (api *PublicFilterAPI) resumeLogs(ctx context.Context, crit FilterCriteria, resume *LogResumeToken) (*logBackfill, error) {
+	header, err := api.backend.HeaderByHash(ctx, resume.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("unknown resume block %x", resume.BlockHash)
+	}
+	backfill := new(logBackfill)
+	for {
+		canonical, err := api.backend.HeaderByNumber(ctx, rpc.BlockNumber(header.Number.Int64()))
+		if err != nil {
+			return nil, err
+		}
+		if canonical != nil && canonical.Hash() == header.Hash() {
+			break
+		}
+		// The block was reorged out, report its delivered logs as removed
+		logsList, err := api.backend.GetLogs(ctx, header.Hash())
+		if err != nil {
+			return nil, err
+		}
+		var unfiltered []*types.Log
+		for _, logs := range logsList {
+			unfiltered = append(unfiltered, logs...)
+		}
+		logs := filterLogs(unfiltered, nil, nil, crit.Addresses, crit.Topics)
+		for i := len(logs) - 1; i >= 0; i-- {
+			if header.Hash() == resume.BlockHash && logs[i].Index > uint(resume.LogIndex) {
+				continue
+			}
+			removed := *logs[i]
+			removed.Removed = true
+			backfill.removed = append(backfill.removed, &removed)
+		}
+		if header, err = api.backend.HeaderByHash(ctx, header.ParentHash); err != nil {
+			return nil, err
+		}
+		if header == nil {
+			return nil, errors.New("resume block ancestry unavailable")
+		}
+	}
+	if header.Hash() == resume.BlockHash {
+		backfill.from, backfill.skip = header.Number.Uint64(), resume
+	} else {
+		backfill.from = header.Number.Uint64() + 1
+	}
+	return backfill, nil
+}
+
+// boundBackfill ends a backfill at the current head, or at the end of the range
+// of the criteria if that's earlier, rejecting it if it spans more blocks than
+// permitted by the limits of the server.
+func (api *PublicFilterAPI) boundBackfill(ctx context.Context, crit FilterCriteria, backfill *logBackfill) error {
+	header, err := api.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return errors.New("chain head unavailable")
+	}
+	backfill.to = header.Number.Uint64()
+	if crit.ToBlock != nil && crit.ToBlock.Sign() >= 0 && crit.ToBlock.Uint64() < backfill.to {
+		backfill.to = crit.ToBlock.Uint64()
+	}
+	if limit := api.config.LogRangeLimit; limit > 0 && backfill.to >= backfill.from && backfill.to-backfill.from >= limit {
+		return &limitError{fmt.Sprintf("backfill spans %d blocks, exceeding the limit of %d blocks", backfill.to-backfill.from+1, limit), limit}
+	}
+	return nil
+}
+
+// backfillLogs delivers the historical logs of a subscription while queueing the
+// new logs arriving meanwhile, and then streams the new logs, dropping the ones
+// already delivered by the backfill.
+func (api *PublicFilterAPI) backfillLogs(notifier *rpc.Notifier, rpcSub *rpc.Subscription, logsSub *Subscription, matchedLogs chan []*types.Log, backfill *logBackfill) {
+	defer logsSub.Unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		queue     [][]*types.Log
+		done      = make(chan error, 1)
+		delivered = make(map[common.Hash]bool) // Blocks of the backfill range with delivered logs
+	)
+	go func() {
+		done <- backfill.deliver(ctx, notifier, rpcSub.ID, delivered)
+	}()
+	for done != nil {
+		select {
+		case logs := <-matchedLogs:
+			queue = append(queue, logs)
+		case err := <-done:
+			if err != nil {
+				log.Debug("Failed to backfill logs", "id", rpcSub.ID, "err", err)
+				return
+			}
+			done = nil
+		case <-rpcSub.Err(): // client send an unsubscribe request
+			return
+		case <-notifier.Closed(): // connection dropped
+			return
+		}
+	}
+	// New logs of the backfill range are only delivered if they weren't already,
+	// and removed ones only if they were.
+	notify := func(logs []*types.Log) {
+		var added, removed []common.Hash
+		for _, l := range logs {
+			if l.BlockNumber <= backfill.to {
+				if l.Removed != delivered[l.BlockHash] {
+					continue
+				}
+				if l.Removed {
+					removed = append(removed, l.BlockHash)
+				} else {
+					added = append(added, l.BlockHash)
+				}
+			}
+			notifier.Notify(rpcSub.ID, l)
+		}
+		for _, hash := range added {
+			delivered[hash] = true
+		}
+		for _, hash := range removed {
+			delete(delivered, hash)
+		}
+	}
+	for _, logs := range queue {
+		notify(logs)
+	}
+	queue = nil
+
+	for {
+		select {
+		case logs := <-matchedLogs:
+			notify(logs)
+		case <-rpcSub.Err(): // client send an unsubscribe request
+			return
+		case <-notifier.Closed(): // connection dropped
+			return
+		}
+	}
+}
+
+// deliver notifies the subscriber of the removed logs of a resumed subscription
+// and of the logs of the backfill range, recording the blocks it delivered logs
+// of.
+func (b *logBackfill) deliver(ctx context.Context, notifier *rpc.Notifier, id rpc.ID, delivered map[common.Hash]bool) error {
+	for _, l := range b.removed {
+		if err := notifier.Notify(id, l); err != nil {
+			return err
+		}
+	}
+	b.filter.SetLimit(backfillPageSize)
+	for b.filter.begin <= int64(b.to) {
+		begin := b.filter.begin
+
+		logs, err := b.filter.Logs(ctx)
+		if err != nil {
+			return err
+		}
+		if b.filter.begin == begin {
+			return fmt.Errorf("block #%d unavailable", begin)
+		}
+		for _, l := range logs {
+			if b.skip != nil && l.BlockHash == b.skip.BlockHash && l.Index <= uint(b.skip.LogIndex) {
+				continue
+			}
+			if err := notifier.Notify(id, l); err != nil {
+				return err
+			}
+			delivered[l.BlockHash] = true
+		}
+	}
+	return nil
+}
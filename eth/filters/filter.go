@@ -20,10 +20,12 @@ import (
 	"context"
 	"errors"
 	"math/big"
+	"sort"
 
 	"github.com/simplechain-org/go-simplechain/common"
 	"github.com/simplechain-org/go-simplechain/core"
 	"github.com/simplechain-org/go-simplechain/core/bloombits"
+	"github.com/simplechain-org/go-simplechain/core/rawdb"
 	"github.com/simplechain-org/go-simplechain/core/types"
 	"github.com/simplechain-org/go-simplechain/ethdb"
 	"github.com/simplechain-org/go-simplechain/event"
@@ -39,11 +41,13 @@ type Backend interface {
 	GetLogs(ctx context.Context, blockHash common.Hash) ([][]*types.Log, error)
 
 	SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription
+	SubscribeTxPoolEvent(chan<- core.TxPoolEvent) event.Subscription
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
 
 	BloomStatus() (uint64, uint64)
+	LogIndexStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 }
 
@@ -59,6 +63,7 @@ type Filter struct {
 	begin, end int64       // Range interval if filtering multiple blocks
 
 	matcher *bloombits.Matcher
+	limit   int // Number of logs after which range filtering stops early, zero if unlimited
 }
 
 // NewRangeFilter creates a new filter which uses a bloom filter on blocks to
@@ -114,6 +119,18 @@ func newFilter(backend Backend, addresses []common.Address, topics [][]common.Ha
 	}
 }
 
+// SetLimit makes range filtering stop at the first block boundary after more
+// than limit logs were gathered, leaving the start of the filter at the next
+// block to search. Zero disables the limit.
+func (f *Filter) SetLimit(limit int) {
+	f.limit = limit
+}
+
+// full reports whether the number of gathered logs exceeds the filter's limit.
+func (f *Filter) full(logs []*types.Log) bool {
+	return f.limit > 0 && len(logs) > f.limit
+}
+
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
@@ -142,30 +159,118 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.end == -1 {
 		end = head
 	}
-	// Gather all indexed logs, and finish with non indexed ones
+	// Gather all logs covered by the log index first, if the criteria can make
+	// use of it, continue with the bloom indexed ones and finish with the rest
 	var (
 		logs []*types.Log
 		err  error
 	)
+	if size, sections := f.backend.LogIndexStatus(); f.logIndexable() {
+		if indexed := sections * size; indexed > uint64(f.begin) {
+			if indexed > end {
+				logs, err = f.logIndexLogs(ctx, size, end, logs)
+			} else {
+				logs, err = f.logIndexLogs(ctx, size, indexed-1, logs)
+			}
+			if err != nil || f.full(logs) {
+				return logs, err
+			}
+		}
+	}
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			logs, err = f.indexedLogs(ctx, end, logs)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			logs, err = f.indexedLogs(ctx, indexed-1, logs)
 		}
-		if err != nil {
+		if err != nil || f.full(logs) {
 			return logs, err
 		}
 	}
-	rest, err := f.unindexedLogs(ctx, end)
-	logs = append(logs, rest...)
-	return logs, err
+	return f.unindexedLogs(ctx, end, logs)
+}
+
+// logIndexable reports whether the filter criteria restrict the addresses or the
+// first topic of the logs, which the log index can resolve.
+func (f *Filter) logIndexable() bool {
+	return len(f.addresses) > 0 || (len(f.topics) > 0 && len(f.topics[0]) > 0)
+}
+
+// logIndexLogs appends the logs matching the filter criteria based on the log
+// index, which maps addresses and first topics to the blocks containing them.
+func (f *Filter) logIndexLogs(ctx context.Context, size, end uint64, logs []*types.Log) ([]*types.Log, error) {
+	for section := uint64(f.begin) / size; section*size <= end; section++ {
+		select {
+		case <-ctx.Done():
+			return logs, ctx.Err()
+		default:
+		}
+		for _, number := range f.logIndexBlocks(section, rawdb.ReadCanonicalHash(f.db, (section+1)*size-1)) {
+			if number < uint64(f.begin) {
+				continue
+			}
+			if number > end {
+				break
+			}
+			f.begin = int64(number) + 1
+
+			// Retrieve the suggested block and pull any truly matching logs
+			header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if header == nil || err != nil {
+				return logs, err
+			}
+			found, err := f.checkMatches(ctx, header)
+			if err != nil {
+				return logs, err
+			}
+			if logs = append(logs, found...); f.full(logs) {
+				return logs, nil
+			}
+		}
+	}
+	f.begin = int64(end) + 1
+	return logs, nil
+}
+
+// logIndexBlocks returns the sorted numbers of the blocks within a log index
+// section that may contain logs matching the address and first topic criteria.
+func (f *Filter) logIndexBlocks(section uint64, head common.Hash) []uint64 {
+	var topics []common.Hash
+	if len(f.topics) > 0 {
+		topics = f.topics[0]
+	}
+	var numbers []uint64
+	switch {
+	case len(f.addresses) == 0:
+		for _, topic := range topics {
+			numbers = append(numbers, rawdb.ReadLogIndex(f.db, common.Address{}, topic, section, head)...)
+		}
+	case len(topics) == 0:
+		for _, address := range f.addresses {
+			numbers = append(numbers, rawdb.ReadLogIndex(f.db, address, common.Hash{}, section, head)...)
+		}
+	default:
+		for _, address := range f.addresses {
+			for _, topic := range topics {
+				numbers = append(numbers, rawdb.ReadLogIndex(f.db, address, topic, section, head)...)
+			}
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	unique := numbers[:0]
+	for i, number := range numbers {
+		if i == 0 || number != numbers[i-1] {
+			unique = append(unique, number)
+		}
+	}
+	return unique
 }
 
-// indexedLogs returns the logs matching the filter criteria based on the bloom
+// indexedLogs appends the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
-func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+func (f *Filter) indexedLogs(ctx context.Context, end uint64, logs []*types.Log) ([]*types.Log, error) {
 	// Create a matcher session and request servicing from the backend
 	matches := make(chan uint64, 64)
 
@@ -178,8 +283,6 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	f.backend.ServiceFilter(ctx, session)
 
 	// Iterate over the matches until exhausted or context closed
-	var logs []*types.Log
-
 	for {
 		select {
 		case number, ok := <-matches:
@@ -202,7 +305,9 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 			if err != nil {
 				return logs, err
 			}
-			logs = append(logs, found...)
+			if logs = append(logs, found...); f.full(logs) {
+				return logs, nil
+			}
 
 		case <-ctx.Done():
 			return logs, ctx.Err()
@@ -210,11 +315,9 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	}
 }
 
-// indexedLogs returns the logs matching the filter criteria based on raw block
+// unindexedLogs appends the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
-func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
-	var logs []*types.Log
-
+func (f *Filter) unindexedLogs(ctx context.Context, end uint64, logs []*types.Log) ([]*types.Log, error) {
 	for ; f.begin <= int64(end); f.begin++ {
 		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
 		if header == nil || err != nil {
@@ -224,7 +327,10 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, e
 		if err != nil {
 			return logs, err
 		}
-		logs = append(logs, found...)
+		if logs = append(logs, found...); f.full(logs) {
+			f.begin++
+			return logs, nil
+		}
 	}
 	return logs, nil
 }
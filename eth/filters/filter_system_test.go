@@ -18,6 +18,7 @@ package filters
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -46,6 +47,7 @@ type testBackend struct {
 	rmLogsFeed *event.Feed
 	logsFeed   *event.Feed
 	chainFeed  *event.Feed
+	txPoolFeed *event.Feed
 }
 
 func (b *testBackend) ChainDb() ethdb.Database {
@@ -108,6 +110,10 @@ func (b *testBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Sub
 	return b.txFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeTxPoolEvent(ch chan<- core.TxPoolEvent) event.Subscription {
+	return b.txPoolFeed.Subscribe(ch)
+}
+
 func (b *testBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return b.rmLogsFeed.Subscribe(ch)
 }
@@ -120,6 +126,10 @@ func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subsc
 	return b.chainFeed.Subscribe(ch)
 }
 
+func (b *testBackend) LogIndexStatus() (uint64, uint64) {
+	return 0, 0
+}
+
 func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
@@ -166,8 +176,8 @@ func TestBlockSubscription(t *testing.T) {
 		rmLogsFeed  = new(event.Feed)
 		logsFeed    = new(event.Feed)
 		chainFeed   = new(event.Feed)
-		backend     = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api         = NewPublicFilterAPI(backend, false)
+		backend     = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api         = NewPublicFilterAPI(backend, false, Config{})
 		genesis     = new(core.Genesis).MustCommit(db)
 		chain, _    = core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {})
 		chainEvents = []core.ChainEvent{}
@@ -223,8 +233,8 @@ func TestPendingTxFilter(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api        = NewPublicFilterAPI(backend, false, Config{})
 
 		transactions = []*types.Transaction{
 			types.NewTransaction(0, common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268"), new(big.Int), 0, new(big.Int), nil),
@@ -283,8 +293,8 @@ func TestLogFilterCreation(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api        = NewPublicFilterAPI(backend, false, Config{})
 
 		testCases = []struct {
 			crit    FilterCriteria
@@ -332,8 +342,8 @@ func TestInvalidLogFilterCreation(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api        = NewPublicFilterAPI(backend, false, Config{})
 	)
 
 	// different situations where log filter creation should fail.
@@ -359,8 +369,8 @@ func TestInvalidGetLogsRequest(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api        = NewPublicFilterAPI(backend, false, Config{})
 		blockHash  = common.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111")
 	)
 
@@ -389,8 +399,8 @@ func TestLogFilter(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api        = NewPublicFilterAPI(backend, false, Config{})
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
 		secondAddr     = common.HexToAddress("0x2222222222222222222222222222222222222222")
@@ -508,8 +518,8 @@ func TestPendingLogsSubscription(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api        = NewPublicFilterAPI(backend, false, Config{})
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
 		secondAddr     = common.HexToAddress("0x2222222222222222222222222222222222222222")
@@ -614,3 +624,209 @@ func TestPendingLogsSubscription(t *testing.T) {
 		}
 	}
 }
+
+// subscribeLogs subscribes to the logs of the filter API over an in-process RPC
+// connection.
+func subscribeLogs(t *testing.T, api *PublicFilterAPI, args ...interface{}) (*rpc.Client, chan types.Log) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatalf("failed to register filter API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+
+	logs := make(chan types.Log, 16)
+	if _, err := client.EthSubscribe(context.Background(), logs, append([]interface{}{"logs"}, args...)...); err != nil {
+		t.Fatalf("failed to subscribe to logs: %v", err)
+	}
+	return client, logs
+}
+
+// expectLogs waits for the given logs of a subscription, identified by block
+// number, log index and removal flag.
+func expectLogs(t *testing.T, logs chan types.Log, want []types.Log) {
+	for i, expected := range want {
+		select {
+		case log := <-logs:
+			if log.BlockNumber != expected.BlockNumber || log.Index != expected.Index || log.Removed != expected.Removed {
+				t.Fatalf("log %d mismatch: have #%d/%d removed %v, want #%d/%d removed %v", i, log.BlockNumber, log.Index, log.Removed, expected.BlockNumber, expected.Index, expected.Removed)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("log %d not delivered", i)
+		}
+	}
+	select {
+	case log := <-logs:
+		t.Fatalf("unexpected log delivered: #%d/%d removed %v", log.BlockNumber, log.Index, log.Removed)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// Tests that log subscriptions starting at a historical block backfill the logs
+// of the canonical chain, and then stream the new ones without duplicates.
+func TestLogSubscriptionBackfill(t *testing.T) {
+	backend := newLogIndexTestBackend(t)
+	api := NewPublicFilterAPI(backend, false, Config{})
+
+	client, logs := subscribeLogs(t, api, map[string]interface{}{"fromBlock": "0x0", "topics": [][]common.Hash{{logIndexTopicA}}})
+	defer client.Close()
+
+	expectLogs(t, logs, []types.Log{{BlockNumber: 2}, {BlockNumber: 2, Index: 1}, {BlockNumber: 5}, {BlockNumber: 12}})
+
+	// Already delivered logs are dropped, new and removed ones are streamed
+	hash := rawdb.ReadCanonicalHash(backend.db, 12)
+	delivered := rawdb.ReadReceipts(backend.db, hash, 12)[0].Logs[0]
+	backend.logsFeed.Send([]*types.Log{delivered})
+	backend.logsFeed.Send([]*types.Log{{Topics: []common.Hash{logIndexTopicA}, BlockNumber: 21, BlockHash: common.Hash{0x21}}})
+	expectLogs(t, logs, []types.Log{{BlockNumber: 21}})
+
+	removed := *delivered
+	removed.Removed = true
+	backend.rmLogsFeed.Send(core.RemovedLogsEvent{Logs: []*types.Log{&removed}})
+	expectLogs(t, logs, []types.Log{{BlockNumber: 12, Removed: true}})
+
+	// Backfills exceeding the range limit are rejected
+	api = NewPublicFilterAPI(backend, false, Config{LogRangeLimit: 10})
+	server := rpc.NewServer()
+	server.RegisterName("eth", api)
+	limited := rpc.DialInProc(server)
+	defer limited.Close()
+
+	if _, err := limited.EthSubscribe(context.Background(), make(chan types.Log), "logs", map[string]interface{}{"fromBlock": "0x0"}); err == nil {
+		t.Errorf("backfill exceeding the range limit accepted")
+	}
+}
+
+// Tests that log subscriptions resume right after the log of a resume token, and
+// report the delivered logs of reorged out blocks as removed.
+func TestLogSubscriptionResume(t *testing.T) {
+	backend := newLogIndexTestBackend(t)
+	api := NewPublicFilterAPI(backend, false, Config{})
+	crit := map[string]interface{}{"topics": [][]common.Hash{{logIndexTopicA}}}
+
+	// Resuming within a canonical block skips the logs delivered from it
+	token := &LogResumeToken{BlockHash: rawdb.ReadCanonicalHash(backend.db, 2)}
+	client, logs := subscribeLogs(t, api, crit, token)
+	defer client.Close()
+
+	expectLogs(t, logs, []types.Log{{BlockNumber: 2, Index: 1}, {BlockNumber: 5}, {BlockNumber: 12}})
+
+	// Resuming from a reorged out block removes its delivered logs first
+	parent := rawdb.ReadBlock(backend.db, rawdb.ReadCanonicalHash(backend.db, 4), 4)
+	fork, receipts := core.GenerateChain(params.TestChainConfig, parent, ethash.NewFaker(), backend.db, 1, func(i int, gen *core.BlockGen) {
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{
+			{Address: logIndexAddr1, Topics: []common.Hash{logIndexTopicA}, BlockNumber: 5},
+			{Address: logIndexAddr1, Topics: []common.Hash{logIndexTopicA}, BlockNumber: 5, Index: 1},
+		}
+		gen.AddUncheckedReceipt(receipt)
+	})
+	for _, log := range receipts[0][0].Logs {
+		log.BlockHash = fork[0].Hash()
+	}
+	rawdb.WriteBlock(backend.db, fork[0])
+	rawdb.WriteReceipts(backend.db, fork[0].Hash(), 5, receipts[0])
+
+	token = &LogResumeToken{BlockHash: fork[0].Hash()}
+	client, logs = subscribeLogs(t, api, crit, token)
+	defer client.Close()
+
+	expectLogs(t, logs, []types.Log{{BlockNumber: 5, Removed: true}, {BlockNumber: 5}, {BlockNumber: 12}})
+
+	// Unknown resume blocks are rejected
+	if _, err := client.EthSubscribe(context.Background(), make(chan types.Log), "logs", crit, &LogResumeToken{BlockHash: common.Hash{0xff}}); err == nil {
+		t.Errorf("unknown resume block accepted")
+	}
+}
+
+// confirmedBackend is a testBackend resolving the safe and finalized block tags
+// with fixed confirmation depths.
+type confirmedBackend struct {
+	*logIndexBackend
+}
+
+func (b *confirmedBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
+	var depth uint64
+	switch blockNr {
+	case rpc.SafeBlockNumber:
+		depth = 5
+	case rpc.FinalizedBlockNumber:
+		depth = 10
+	default:
+		return b.testBackend.HeaderByNumber(ctx, blockNr)
+	}
+	head, _ := b.testBackend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head.Number.Uint64() < depth {
+		return b.testBackend.HeaderByNumber(ctx, rpc.EarliestBlockNumber)
+	}
+	return b.testBackend.HeaderByNumber(ctx, rpc.BlockNumber(head.Number.Uint64()-depth))
+}
+
+// Tests that the safe and finalized block tags are accepted as log query bounds.
+func TestConfirmedBlockTags(t *testing.T) {
+	backend := &confirmedBackend{newLogIndexTestBackend(t)}
+	api := NewPublicFilterAPI(backend, false, Config{})
+
+	tests := []struct {
+		crit string
+		want []uint64
+	}{
+		{`{"fromBlock": "0x0", "toBlock": "finalized"}`, []uint64{2, 2, 5}},
+		{`{"fromBlock": "finalized"}`, []uint64{12}},
+		{`{"fromBlock": "finalized", "toBlock": "safe"}`, []uint64{12}},
+		{`{"fromBlock": "safe"}`, []uint64{}},
+	}
+	for i, tt := range tests {
+		var crit FilterCriteria
+		if err := json.Unmarshal([]byte(tt.crit), &crit); err != nil {
+			t.Fatalf("test %d: failed to decode criteria: %v", i, err)
+		}
+		crit.Topics = [][]common.Hash{{logIndexTopicA}}
+
+		logs, err := api.GetLogs(context.Background(), crit)
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve logs: %v", i, err)
+		}
+		if have := logBlocks(logs); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: log blocks mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that finalized head subscriptions report every newly finalized header in
+// order as the chain head advances.
+func TestFinalizedHeadsSubscription(t *testing.T) {
+	backend := &confirmedBackend{newLogIndexTestBackend(t)}
+	rawdb.WriteHeadBlockHash(backend.db, rawdb.ReadCanonicalHash(backend.db, 13))
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", NewPublicFilterAPI(backend, false, Config{})); err != nil {
+		t.Fatalf("failed to register filter API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	headers := make(chan *types.Header, 16)
+	if _, err := client.EthSubscribe(context.Background(), headers, "finalizedHeads"); err != nil {
+		t.Fatalf("failed to subscribe to finalized heads: %v", err)
+	}
+	// Advance the chain by three blocks at once
+	head := rawdb.ReadBlock(backend.db, rawdb.ReadCanonicalHash(backend.db, 16), 16)
+	rawdb.WriteHeadBlockHash(backend.db, head.Hash())
+	backend.chainFeed.Send(core.ChainEvent{Block: head, Hash: head.Hash()})
+
+	for want := uint64(4); want <= 6; want++ {
+		select {
+		case header := <-headers:
+			if header.Number.Uint64() != want {
+				t.Fatalf("finalized header mismatch: have #%d, want #%d", header.Number, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("finalized header #%d not delivered", want)
+		}
+	}
+	select {
+	case header := <-headers:
+		t.Fatalf("unexpected finalized header #%d", header.Number)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -18,9 +18,11 @@ package filters
 
 import (
 	"context"
+	"encoding/json"
 	"io/ioutil"
 	"math/big"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/simplechain-org/go-simplechain/common"
@@ -32,6 +34,7 @@ import (
 	"github.com/simplechain-org/go-simplechain/ethdb"
 	"github.com/simplechain-org/go-simplechain/event"
 	"github.com/simplechain-org/go-simplechain/params"
+	"github.com/simplechain-org/go-simplechain/rpc"
 )
 
 func makeReceipt(addr common.Address) *types.Receipt {
@@ -57,7 +60,7 @@ func BenchmarkFilters(b *testing.B) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr1      = crypto.PubkeyToAddress(key1.PublicKey)
 		addr2      = common.BytesToAddress([]byte("jeff"))
@@ -116,7 +119,7 @@ func TestFilters(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr       = crypto.PubkeyToAddress(key1.PublicKey)
 
@@ -230,3 +233,228 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+// logIndexBackend is a testBackend reporting a number of available log index
+// sections.
+type logIndexBackend struct {
+	*testBackend
+	sections uint64
+}
+
+func (b *logIndexBackend) LogIndexStatus() (uint64, uint64) {
+	return logIndexTestSection, b.sections
+}
+
+// logIndexTestSection is the log index section size used by the tests.
+const logIndexTestSection = 8
+
+var (
+	logIndexAddr1  = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	logIndexAddr2  = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	logIndexTopicA = common.BytesToHash([]byte("topicA"))
+	logIndexTopicB = common.BytesToHash([]byte("topicB"))
+)
+
+// newLogIndexTestBackend creates a chain of 20 blocks with logs in the blocks 2,
+// 1, 2, 5, 6 and 12, and writes the log index of its first section.
+func newLogIndexTestBackend(t *testing.T) *logIndexBackend {
+	var (
+		db      = ethdb.NewMemDatabase()
+		backend = &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)}
+		genesis = core.GenesisBlockForTesting(db, logIndexAddr1, big.NewInt(1000000))
+		emitted = map[int][]*types.Log{
+			1:  {{Address: logIndexAddr2, Topics: []common.Hash{logIndexTopicB}}},
+			2:  {{Address: logIndexAddr1, Topics: []common.Hash{logIndexTopicA}}, {Address: logIndexAddr1, Topics: []common.Hash{logIndexTopicA}}},
+			5:  {{Address: logIndexAddr2, Topics: []common.Hash{logIndexTopicA}}},
+			6:  {{Address: logIndexAddr1, Topics: []common.Hash{logIndexTopicB}}},
+			12: {{Address: logIndexAddr1, Topics: []common.Hash{logIndexTopicA}}},
+		}
+	)
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 20, func(i int, gen *core.BlockGen) {
+		if logs, ok := emitted[i+1]; ok {
+			for index, log := range logs {
+				log.BlockNumber, log.Index = uint64(i+1), uint(index)
+			}
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = logs
+			gen.AddUncheckedReceipt(receipt)
+		}
+	})
+	for i, block := range chain {
+		for _, receipt := range receipts[i] {
+			for _, log := range receipt.Logs {
+				log.BlockHash = block.Hash()
+			}
+		}
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Index the logs of the first section, keyed the same way the log indexer does
+	entries := make(map[common.Address]map[common.Hash][]uint64)
+	add := func(address common.Address, topic common.Hash, number uint64) {
+		if entries[address] == nil {
+			entries[address] = make(map[common.Hash][]uint64)
+		}
+		if numbers := entries[address][topic]; len(numbers) == 0 || numbers[len(numbers)-1] != number {
+			entries[address][topic] = append(numbers, number)
+		}
+	}
+	for number := uint64(1); number < logIndexTestSection; number++ {
+		for _, log := range emitted[int(number)] {
+			add(log.Address, common.Hash{}, number)
+			add(log.Address, log.Topics[0], number)
+			add(common.Address{}, log.Topics[0], number)
+		}
+	}
+	head := rawdb.ReadCanonicalHash(db, logIndexTestSection-1)
+	for address, topics := range entries {
+		for topic, numbers := range topics {
+			rawdb.WriteLogIndex(db, address, topic, 0, head, numbers)
+		}
+	}
+	return &logIndexBackend{testBackend: backend, sections: 1}
+}
+
+// logBlocks returns the block numbers of a list of logs.
+func logBlocks(logs []*types.Log) []uint64 {
+	numbers := []uint64{}
+	for _, log := range logs {
+		numbers = append(numbers, log.BlockNumber)
+	}
+	return numbers
+}
+
+// Tests that range filters use the log index for the sections it covers, and
+// return the same logs as without the index.
+func TestLogIndexFilter(t *testing.T) {
+	backend := newLogIndexTestBackend(t)
+
+	tests := []struct {
+		begin, end int64
+		addresses  []common.Address
+		topics     [][]common.Hash
+		want       []uint64
+	}{
+		{0, -1, []common.Address{logIndexAddr1}, nil, []uint64{2, 2, 6, 12}},
+		{0, -1, nil, [][]common.Hash{{logIndexTopicA}}, []uint64{2, 2, 5, 12}},
+		{0, -1, []common.Address{logIndexAddr1}, [][]common.Hash{{logIndexTopicA}}, []uint64{2, 2, 12}},
+		{0, -1, []common.Address{logIndexAddr1, logIndexAddr2}, [][]common.Hash{{logIndexTopicB}}, []uint64{1, 6}},
+		{3, 10, []common.Address{logIndexAddr1}, [][]common.Hash{{logIndexTopicA, logIndexTopicB}}, []uint64{6}},
+		{3, 4, []common.Address{logIndexAddr2}, nil, []uint64{}},
+		{0, -1, nil, [][]common.Hash{nil, {logIndexTopicA}}, []uint64{}},
+	}
+	for i, tt := range tests {
+		for sections := uint64(0); sections <= 1; sections++ {
+			backend.sections = sections
+
+			logs, err := NewRangeFilter(backend, tt.begin, tt.end, tt.addresses, tt.topics).Logs(context.Background())
+			if err != nil {
+				t.Fatalf("test %d, sections %d: failed to filter logs: %v", i, sections, err)
+			}
+			if have := logBlocks(logs); !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("test %d, sections %d: log blocks mismatch: have %v, want %v", i, sections, have, tt.want)
+			}
+		}
+	}
+	// Blocks missing from the index are not searched within the indexed sections
+	backend.sections = 1
+	rawdb.WriteLogIndex(backend.db, logIndexAddr2, common.Hash{}, 0, rawdb.ReadCanonicalHash(backend.db, logIndexTestSection-1), nil)
+
+	logs, _ := NewRangeFilter(backend, 0, -1, []common.Address{logIndexAddr2}, nil).Logs(context.Background())
+	if len(logs) != 0 {
+		t.Errorf("unindexed logs returned from indexed section: %v", logBlocks(logs))
+	}
+}
+
+// Tests that limited range filters stop at the first block boundary after the
+// limit is exceeded, from both the log index and the unindexed blocks.
+func TestFilterLimit(t *testing.T) {
+	backend := newLogIndexTestBackend(t)
+
+	for sections := uint64(0); sections <= 1; sections++ {
+		backend.sections = sections
+
+		filter := NewRangeFilter(backend, 0, -1, []common.Address{logIndexAddr1}, nil)
+		filter.SetLimit(1)
+
+		logs, err := filter.Logs(context.Background())
+		if err != nil {
+			t.Fatalf("sections %d: failed to filter logs: %v", sections, err)
+		}
+		if have := logBlocks(logs); !reflect.DeepEqual(have, []uint64{2, 2}) {
+			t.Errorf("sections %d: log blocks mismatch: have %v, want %v", sections, have, []uint64{2, 2})
+		}
+		if filter.begin != 3 {
+			t.Errorf("sections %d: filter start mismatch: have %d, want 3", sections, filter.begin)
+		}
+		// Continuing the filter returns the rest of the logs
+		filter.SetLimit(0)
+		if logs, _ = filter.Logs(context.Background()); !reflect.DeepEqual(logBlocks(logs), []uint64{6, 12}) {
+			t.Errorf("sections %d: continued log blocks mismatch: have %v, want %v", sections, logBlocks(logs), []uint64{6, 12})
+		}
+	}
+}
+
+// Tests that the log queries of the filter API are rejected if they exceed the
+// limits of the server, and that paginated queries return all logs in order.
+func TestLogQueryLimits(t *testing.T) {
+	backend := newLogIndexTestBackend(t)
+	api := NewPublicFilterAPI(backend, false, Config{LogRangeLimit: 10, LogResultLimit: 2})
+
+	from, to := big.NewInt(0), big.NewInt(9)
+	crit := FilterCriteria{FromBlock: from, ToBlock: to, Addresses: []common.Address{logIndexAddr1}}
+
+	// Range limit
+	if _, err := api.GetLogs(context.Background(), FilterCriteria{FromBlock: from, Addresses: crit.Addresses}); err == nil {
+		t.Errorf("query exceeding the range limit accepted")
+	} else if rpcErr, ok := err.(rpc.Error); !ok || rpcErr.ErrorCode() != logLimitErrorCode {
+		t.Errorf("range limit error mismatch: %v", err)
+	}
+	// Result limit
+	if logs, err := api.GetLogs(context.Background(), FilterCriteria{FromBlock: from, ToBlock: big.NewInt(5), Addresses: crit.Addresses}); err != nil || len(logs) != 2 {
+		t.Errorf("query within the limits failed: %v, %d logs", err, len(logs))
+	}
+	if _, err := api.GetLogs(context.Background(), crit); err == nil {
+		t.Errorf("query exceeding the result limit accepted")
+	} else if rpcErr, ok := err.(rpc.Error); !ok || rpcErr.ErrorCode() != logLimitErrorCode {
+		t.Errorf("result limit error mismatch: %v", err)
+	}
+	// Pagination through all the logs, with a cursor in the middle of a block
+	for _, pageCrit := range []FilterCriteria{
+		{FromBlock: big.NewInt(0), ToBlock: big.NewInt(9), Topics: [][]common.Hash{{logIndexTopicA, logIndexTopicB}}},
+		{FromBlock: big.NewInt(10), ToBlock: big.NewInt(19), Topics: [][]common.Hash{{logIndexTopicA}}},
+	} {
+		var (
+			cursor *LogCursor
+			have   []*types.Log
+		)
+		for pages := 0; ; pages++ {
+			if pages > 10 {
+				t.Fatalf("pagination did not terminate")
+			}
+			page, err := api.GetLogsPage(context.Background(), pageCrit, cursor)
+			if err != nil {
+				t.Fatalf("failed to retrieve log page: %v", err)
+			}
+			if len(page.Logs) > 2 {
+				t.Errorf("page size exceeds the result limit: %d", len(page.Logs))
+			}
+			have = append(have, page.Logs...)
+			if cursor = page.Cursor; cursor == nil {
+				break
+			}
+			// Cursors must survive a JSON round trip
+			blob, _ := json.Marshal(cursor)
+			cursor = new(LogCursor)
+			if err := json.Unmarshal(blob, cursor); err != nil {
+				t.Fatalf("failed to decode cursor %s: %v", blob, err)
+			}
+		}
+		want, _ := NewRangeFilter(backend, pageCrit.FromBlock.Int64(), pageCrit.ToBlock.Int64(), nil, pageCrit.Topics).Logs(context.Background())
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("paginated logs mismatch: have %v, want %v", logBlocks(have), logBlocks(want))
+		}
+	}
+}
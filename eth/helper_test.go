@@ -108,6 +108,17 @@ func (p *testTxPool) AddRemotes(txs []*types.Transaction) []error {
 	return make([]error, len(txs))
 }
 
+// AddRemotesFrom appends a batch of transactions to the pool regardless of the
+// peer relaying them.
+func (p *testTxPool) AddRemotesFrom(peer string, txs []*types.Transaction) []error {
+	return p.AddRemotes(txs)
+}
+
+// IsPrivate reports that none of the transactions in the pool are private.
+func (p *testTxPool) IsPrivate(hash common.Hash) bool {
+	return false
+}
+
 // Pending returns all the transactions known to the pool
 func (p *testTxPool) Pending() (map[common.Address]types.Transactions, error) {
 	p.lock.RLock()
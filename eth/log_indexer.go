@@ -0,0 +1,130 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/core"
+	"github.com/simplechain-org/go-simplechain/core/rawdb"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/ethdb"
+)
+
+const (
+	// logIndexSection is the number of blocks the log indexer processes in one
+	// go, and the granularity at which index entries are stored.
+	logIndexSection = 4096
+
+	// logIndexConfirms is the number of confirmation blocks before a section is
+	// considered probably final and its logs are indexed.
+	logIndexConfirms = 256
+
+	// logIndexThrottling is the time to wait between processing two consecutive
+	// index sections. It's useful during chain upgrades to prevent disk overload.
+	logIndexThrottling = 100 * time.Millisecond
+)
+
+// logIndexEntry is the (address, first topic) pair a log index entry is keyed
+// by, either of which may be zero to act as a wildcard.
+type logIndexEntry struct {
+	address common.Address
+	topic   common.Hash
+}
+
+// LogIndexer implements a core.ChainIndexer, building a secondary index of the
+// logs of the canonical chain that maps the emitting address and the first topic
+// of every log to the blocks containing it.
+//
+// Every log is indexed under (address, topic0), (address, *) and (*, topic0), so
+// that filters on the address, on the first topic or on both can be resolved.
+type LogIndexer struct {
+	db    ethdb.Database   // Database instance to write the index into
+	chain *core.BlockChain // Chain to retrieve the receipts of the blocks from
+
+	section uint64                     // Section number being processed currently
+	head    common.Hash                // Head of the section being processed
+	entries map[logIndexEntry][]uint64 // Blocks accumulated for each index entry
+	err     error                      // First error encountered while indexing the section
+}
+
+// NewLogIndexer returns a chain indexer that maps the addresses and first topics
+// of the logs of the canonical chain to the blocks containing them.
+func NewLogIndexer(eth *Simplechain) *core.ChainIndexer {
+	backend := &LogIndexer{
+		db:    eth.ChainDb(),
+		chain: eth.blockchain,
+	}
+	table := ethdb.NewTable(eth.ChainDb(), string(rawdb.LogIndexPrefix))
+
+	return core.NewChainIndexer(eth.ChainDb(), table, backend, logIndexSection, logIndexConfirms, logIndexThrottling, "logs")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new log index section.
+func (l *LogIndexer) Reset(section uint64, lastSectionHead common.Hash) error {
+	l.section, l.head, l.err = section, common.Hash{}, nil
+	l.entries = make(map[logIndexEntry][]uint64)
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the logs of a new block to
+// the index. Any failure is reported when the section is committed.
+func (l *LogIndexer) Process(header *types.Header) {
+	l.head = header.Hash()
+	if l.err != nil || header.Bloom == (types.Bloom{}) {
+		return
+	}
+	receipts := l.chain.GetReceiptsByHash(header.Hash())
+	if receipts == nil {
+		l.err = fmt.Errorf("receipts of block #%d [%x…] not found", header.Number, header.Hash().Bytes()[:4])
+		return
+	}
+	number := header.Number.Uint64()
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			l.add(logIndexEntry{address: log.Address}, number)
+			if len(log.Topics) > 0 {
+				l.add(logIndexEntry{address: log.Address, topic: log.Topics[0]}, number)
+				l.add(logIndexEntry{topic: log.Topics[0]}, number)
+			}
+		}
+	}
+}
+
+// add appends a block number to an index entry, unless it's already included.
+func (l *LogIndexer) add(entry logIndexEntry, number uint64) {
+	numbers := l.entries[entry]
+	if len(numbers) > 0 && numbers[len(numbers)-1] == number {
+		return
+	}
+	l.entries[entry] = append(numbers, number)
+}
+
+// Commit implements core.ChainIndexerBackend, writing the index entries of the
+// section out into the database.
+func (l *LogIndexer) Commit() error {
+	if l.err != nil {
+		return l.err
+	}
+	batch := l.db.NewBatch()
+	for entry, numbers := range l.entries {
+		rawdb.WriteLogIndex(batch, entry.address, entry.topic, l.section, l.head, numbers)
+	}
+	return batch.Write()
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/consensus/ethash"
+	"github.com/simplechain-org/go-simplechain/core"
+	"github.com/simplechain-org/go-simplechain/core/rawdb"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/core/vm"
+	"github.com/simplechain-org/go-simplechain/ethdb"
+	"github.com/simplechain-org/go-simplechain/params"
+)
+
+// Tests that the log indexer maps the addresses and first topics of the logs to
+// the blocks containing them, including the wildcard entries.
+func TestLogIndexer(t *testing.T) {
+	var (
+		emitter = common.HexToAddress("0x3000000000000000000000000000000000000003")
+		topic   = common.BigToHash(big.NewInt(0xaa))
+
+		engine = ethash.NewFaker()
+		db     = ethdb.NewMemDatabase()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank: {Balance: big.NewInt(1000000)},
+				// log1(0, 0, 0xaa); stop
+				emitter: {Balance: big.NewInt(0), Code: []byte{0x60, 0xaa, 0x60, 0x00, 0x60, 0x00, 0xa1, 0x00}},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	chain, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 4, func(i int, block *core.BlockGen) {
+		if i == 0 || i == 2 {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), emitter, big.NewInt(0), 100000, big.NewInt(1), nil), signer, testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	defer blockchain.Stop()
+
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	indexer := &LogIndexer{db: db, chain: blockchain}
+	if err := indexer.Reset(0, common.Hash{}); err != nil {
+		t.Fatalf("failed to reset indexer: %v", err)
+	}
+	for number := uint64(0); number <= 4; number++ {
+		indexer.Process(blockchain.GetHeaderByNumber(number))
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatalf("failed to commit index: %v", err)
+	}
+	head := blockchain.GetHeaderByNumber(4).Hash()
+
+	tests := []struct {
+		address common.Address
+		topic   common.Hash
+		want    []uint64
+	}{
+		{emitter, topic, []uint64{1, 3}},
+		{emitter, common.Hash{}, []uint64{1, 3}},
+		{common.Address{}, topic, []uint64{1, 3}},
+		{testBank, common.Hash{}, []uint64{}},
+		{emitter, common.Hash{0x01}, []uint64{}},
+	}
+	for i, tt := range tests {
+		if have := rawdb.ReadLogIndex(db, tt.address, tt.topic, 0, head); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: indexed blocks mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
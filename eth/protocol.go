@@ -99,6 +99,14 @@ type txPool interface {
 	// AddRemotes should add the given transactions to the pool.
 	AddRemotes([]*types.Transaction) []error
 
+	// AddRemotesFrom should add the given transactions relayed by the peer
+	// with the given id to the pool.
+	AddRemotesFrom(string, []*types.Transaction) []error
+
+	// IsPrivate should report whether the transaction with the given hash
+	// must not be propagated to the network.
+	IsPrivate(common.Hash) bool
+
 	// Pending should return pending transactions.
 	// The slice should be modifiable by the caller.
 	Pending() (map[common.Address]types.Transactions, error)
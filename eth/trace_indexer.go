@@ -0,0 +1,109 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/core"
+	"github.com/simplechain-org/go-simplechain/core/rawdb"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/ethdb"
+)
+
+const (
+	// traceIndexSection is the number of blocks the trace indexer processes in
+	// one go.
+	traceIndexSection = 64
+
+	// traceIndexConfirms is the number of confirmation blocks before a section is
+	// considered probably final and its traces are indexed.
+	traceIndexConfirms = 16
+
+	// traceIndexThrottling is the time to wait between processing two consecutive
+	// index sections, as tracing blocks is expensive.
+	traceIndexThrottling = 100 * time.Millisecond
+)
+
+// TraceIndexer implements a core.ChainIndexer, tracing all the transactions of
+// the canonical chain and storing their flattened call traces per block, so the
+// trace namespace can serve them without re-executing.
+//
+// Tracing historical blocks requires their state to be available, so indexing a
+// chain from the genesis needs an archive node.
+type TraceIndexer struct {
+	db    ethdb.Database   // Database instance to write the block traces into
+	debug *PrivateDebugAPI // Tracing API to re-execute the blocks with
+
+	batch ethdb.Batch // Batch accumulating the traces of the current section
+	err   error       // First error encountered while tracing the section
+}
+
+// NewTraceIndexer returns a chain indexer that stores the call traces of the
+// canonical chain for fast trace filtering.
+func NewTraceIndexer(eth *Simplechain) *core.ChainIndexer {
+	backend := &TraceIndexer{
+		db:    eth.ChainDb(),
+		debug: NewPrivateDebugAPI(eth.chainConfig, eth),
+	}
+	table := ethdb.NewTable(eth.ChainDb(), string(rawdb.TraceIndexPrefix))
+
+	return core.NewChainIndexer(eth.ChainDb(), table, backend, traceIndexSection, traceIndexConfirms, traceIndexThrottling, "traces")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new trace index section.
+func (t *TraceIndexer) Reset(section uint64, lastSectionHead common.Hash) error {
+	t.batch, t.err = t.db.NewBatch(), nil
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, tracing the transactions of a new
+// block. Any failure is reported when the section is committed.
+func (t *TraceIndexer) Process(header *types.Header) {
+	if t.err != nil {
+		return
+	}
+	block := t.debug.eth.blockchain.GetBlock(header.Hash(), header.Number.Uint64())
+	if block == nil {
+		t.err = fmt.Errorf("block #%d [%x…] not found", header.Number, header.Hash().Bytes()[:4])
+		return
+	}
+	traces, err := traceBlockCalls(context.Background(), t.debug, block)
+	if err != nil {
+		t.err = fmt.Errorf("failed to trace block #%d: %v", header.Number, err)
+		return
+	}
+	blob, err := json.Marshal(traces)
+	if err != nil {
+		t.err = err
+		return
+	}
+	rawdb.WriteBlockTraces(t.batch, header.Hash(), header.Number.Uint64(), blob)
+}
+
+// Commit implements core.ChainIndexerBackend, writing the traces of the section
+// out into the database.
+func (t *TraceIndexer) Commit() error {
+	if t.err != nil {
+		return t.err
+	}
+	return t.batch.Write()
+}
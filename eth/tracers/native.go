@@ -0,0 +1,118 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/core/vm"
+)
+
+// ResultTracer is a transaction tracer which can be interrupted and which
+// assembles its findings into a JSON result. It is implemented by both the
+// JavaScript tracers and the native Go ones.
+type ResultTracer interface {
+	vm.Tracer
+
+	// GetResult returns the JSON encoded result of the trace, or any error
+	// encountered during tracing.
+	GetResult() (json.RawMessage, error)
+
+	// Stop terminates execution of the tracer at the first opportune moment.
+	Stop(err error)
+}
+
+// nativeCtor creates a native tracer with an optional JSON configuration.
+type nativeCtor func(config json.RawMessage) (ResultTracer, error)
+
+// natives contains all the native Go tracers by name. They mirror the output of
+// the built in JavaScript tracers of the same name, but are orders of magnitude
+// faster.
+var natives = map[string]nativeCtor{
+	"callTracer":     newCallTracer,
+	"prestateTracer": newPrestateTracer,
+	"4byteTracer":    newFourByteTracer,
+}
+
+// NewNative creates the native tracer registered under the given name. The
+// returned flag is false if no native implementation exists for the name.
+func NewNative(name string, config json.RawMessage) (ResultTracer, bool, error) {
+	ctor, ok := natives[name]
+	if !ok {
+		return nil, false, nil
+	}
+	tracer, err := ctor(config)
+	return tracer, true, err
+}
+
+// interrupter implements the interruption logic shared by the native tracers.
+type interrupter struct {
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (i *interrupter) Stop(err error) {
+	i.reason = err
+	atomic.StoreUint32(&i.interrupt, 1)
+}
+
+// stopped returns the reason of the interruption if the tracer was stopped.
+func (i *interrupter) stopped() error {
+	if atomic.LoadUint32(&i.interrupt) > 0 {
+		return i.reason
+	}
+	return nil
+}
+
+// isPrecompiled reports whether the address is a precompiled contract, matching
+// the isPrecompiled helper of the JavaScript tracers.
+func isPrecompiled(addr common.Address) bool {
+	_, ok := vm.PrecompiledContractsByzantium[addr]
+	return ok
+}
+
+// memorySlice returns a copy of the memory range [begin, end), or an empty slice
+// if the range is out of bounds, matching the memory access of the JavaScript
+// tracers.
+func memorySlice(memory *vm.Memory, begin, end int64) []byte {
+	if begin < 0 || begin > end || memory.Len() < int(end) {
+		return []byte{}
+	}
+	if slice := memory.Get(begin, end-begin); slice != nil {
+		return slice
+	}
+	return []byte{}
+}
+
+// stackUint64 returns the n'th item from the top of the stack as an uint64,
+// saturating on overflow.
+func stackUint64(stack *vm.Stack, n int) uint64 {
+	return bigUint64(stack.Back(n))
+}
+
+// bigUint64 returns the value of the big integer as an uint64, saturating on
+// overflow.
+func bigUint64(v *big.Int) uint64 {
+	if !v.IsUint64() {
+		return ^uint64(0)
+	}
+	return v.Uint64()
+}
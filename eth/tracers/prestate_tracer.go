@@ -0,0 +1,324 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/core/vm"
+	"github.com/simplechain-org/go-simplechain/crypto"
+)
+
+// prestateAccount is the state of a single account as reported by the prestate
+// tracer.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   uint64                      `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// accountDiff contains the fields of an account modified by a transaction, as
+// reported by the prestate tracer in diff mode.
+type accountDiff struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   *uint64                     `json:"nonce,omitempty"`
+	Code    *hexutil.Bytes              `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// prestateDiff is the result of the prestate tracer in diff mode.
+type prestateDiff struct {
+	Pre  map[common.Address]*accountDiff `json:"pre"`
+	Post map[common.Address]*accountDiff `json:"post"`
+}
+
+// prestateConfig are the configuration options of the prestate tracer.
+type prestateConfig struct {
+	DiffMode bool `json:"diffMode"` // Report the changes done by the transaction
+}
+
+// trackedAccount is the original state of an account accessed by a transaction.
+type trackedAccount struct {
+	existed bool
+	balance *big.Int
+	nonce   uint64
+	code    []byte
+	storage map[common.Hash]common.Hash // Original value of all accessed slots
+}
+
+// prestateTracer is the native Go implementation of the prestateTracer, which
+// outputs sufficient information to create a local execution of the transaction
+// from a custom assembled genesis block.
+//
+// In diff mode, the tracer reports the original and final values of the account
+// fields and storage slots modified by the transaction instead. Just as in the
+// default mode, the original balance of the sender is reconstructed from its
+// final one and the transferred value, so the gas costs are not reflected.
+type prestateTracer struct {
+	interrupter
+	config prestateConfig
+
+	db       vm.StateDB
+	accounts map[common.Address]*trackedAccount
+	order    []common.Address // Order in which the accounts were accessed
+
+	create bool
+	from   common.Address
+	to     common.Address
+	value  *big.Int
+
+	failed error // Error which aborted the tracing, if any
+}
+
+// newPrestateTracer creates a native prestate tracer.
+func newPrestateTracer(config json.RawMessage) (ResultTracer, error) {
+	tracer := &prestateTracer{accounts: make(map[common.Address]*trackedAccount)}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &tracer.config); err != nil {
+			return nil, err
+		}
+	}
+	return tracer, nil
+}
+
+// lookupAccount records the current state of the account if it wasn't accessed
+// yet.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.accounts[addr]; ok {
+		return
+	}
+	t.accounts[addr] = &trackedAccount{
+		existed: t.db.Exist(addr),
+		balance: new(big.Int).Set(t.db.GetBalance(addr)),
+		nonce:   t.db.GetNonce(addr),
+		code:    common.CopyBytes(t.db.GetCode(addr)),
+		storage: make(map[common.Hash]common.Hash),
+	}
+	t.order = append(t.order, addr)
+}
+
+// lookupStorage records the current value of the storage slot if it wasn't
+// accessed yet.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	account := t.accounts[addr]
+	if _, ok := account.storage[key]; !ok {
+		account.storage[key] = t.db.GetState(addr, key)
+	}
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *prestateTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.create, t.from, t.to, t.value = create, from, to, value
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *prestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.failed != nil {
+		return nil
+	}
+	if t.failed = t.stopped(); t.failed != nil {
+		return nil
+	}
+	// Add the current account if we just started tracing. Balance will potentially
+	// be wrong here, since this will include the value sent along with the message.
+	// We fix that in GetResult.
+	if t.db == nil {
+		t.db = env.StateDB
+		t.lookupAccount(contract.Address())
+		if t.config.DiffMode {
+			t.lookupAccount(env.Coinbase)
+		}
+	}
+	// Whenever new state is accessed, add it to the prestate
+	switch op {
+	case vm.EXTCODECOPY, vm.EXTCODESIZE, vm.BALANCE:
+		t.lookupAccount(common.BigToAddress(stack.Back(0)))
+	case vm.CREATE:
+		from := contract.Address()
+		t.lookupAccount(crypto.CreateAddress(from, env.StateDB.GetNonce(from)))
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.lookupAccount(common.BigToAddress(stack.Back(1)))
+	case vm.SSTORE, vm.SLOAD:
+		t.lookupStorage(contract.Address(), common.BigToHash(stack.Back(0)))
+	case vm.SELFDESTRUCT:
+		if t.config.DiffMode {
+			t.lookupAccount(common.BigToAddress(stack.Back(0)))
+		}
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *prestateTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the assembled prestate allocations, or the pre and post
+// state changes in diff mode.
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	if t.failed != nil {
+		return nil, t.failed
+	}
+	if t.db == nil {
+		return nil, errors.New("no execution steps traced")
+	}
+	// At this point, we need to deduct the 'value' from the outer transaction,
+	// and move it back to the origin. The caller's nonce is also decremented.
+	t.lookupAccount(t.from)
+
+	value := t.value
+	if value == nil {
+		value = new(big.Int)
+	}
+	if to, ok := t.accounts[t.to]; ok {
+		to.balance = new(big.Int).Sub(to.balance, value)
+	}
+	from := t.accounts[t.from]
+	from.balance = new(big.Int).Add(from.balance, value)
+	from.nonce--
+
+	// We can blindly drop the contract prestate of creations, as any existing
+	// state would have caused the transaction to be rejected as invalid.
+	if t.create {
+		t.accounts[t.to].existed = false
+	}
+	if t.config.DiffMode {
+		return json.Marshal(t.diff())
+	}
+	prestate := make(map[common.Address]*prestateAccount)
+	for _, addr := range t.order {
+		account := t.accounts[addr]
+		if t.create && addr == t.to {
+			continue
+		}
+		code := hexutil.Bytes(account.code)
+		if code == nil {
+			code = hexutil.Bytes{}
+		}
+		storage := make(map[common.Hash]common.Hash)
+		for key, val := range account.storage {
+			if val != (common.Hash{}) {
+				storage[key] = val
+			}
+		}
+		prestate[addr] = &prestateAccount{
+			Balance: (*hexutil.Big)(account.balance),
+			Nonce:   account.nonce,
+			Code:    code,
+			Storage: storage,
+		}
+	}
+	return json.Marshal(prestate)
+}
+
+// diff assembles the original and final values of the modified account fields
+// and storage slots. Accounts not existing before the transaction are omitted
+// from the pre state and reported in full in the post state, while deleted ones
+// are reported in full in the pre state and omitted from the post state.
+func (t *prestateTracer) diff() *prestateDiff {
+	result := &prestateDiff{
+		Pre:  make(map[common.Address]*accountDiff),
+		Post: make(map[common.Address]*accountDiff),
+	}
+	for _, addr := range t.order {
+		var (
+			account  = t.accounts[addr]
+			pre      = &accountDiff{Storage: make(map[common.Hash]common.Hash)}
+			post     = &accountDiff{Storage: make(map[common.Hash]common.Hash)}
+			modified = false
+			exists   = t.db.Exist(addr) && !t.db.HasSuicided(addr)
+		)
+		if balance := t.db.GetBalance(addr); balance.Cmp(account.balance) != 0 {
+			pre.Balance, post.Balance = (*hexutil.Big)(account.balance), (*hexutil.Big)(new(big.Int).Set(balance))
+			modified = true
+		}
+		if nonce := t.db.GetNonce(addr); nonce != account.nonce {
+			preNonce := account.nonce
+			pre.Nonce, post.Nonce = &preNonce, &nonce
+			modified = true
+		}
+		if code := t.db.GetCode(addr); !bytes.Equal(code, account.code) {
+			preCode, postCode := hexutil.Bytes(account.code), hexutil.Bytes(common.CopyBytes(code))
+			pre.Code, post.Code = &preCode, &postCode
+			modified = true
+		}
+		for key, val := range account.storage {
+			current := t.db.GetState(addr, key)
+			if current == val {
+				continue
+			}
+			if val != (common.Hash{}) {
+				pre.Storage[key] = val
+			}
+			if current != (common.Hash{}) {
+				post.Storage[key] = current
+			}
+			modified = true
+		}
+		if !modified && exists == account.existed {
+			continue
+		}
+		// Created and deleted accounts are reported in full on the side they exist
+		switch {
+		case account.existed && exists:
+			result.Pre[addr], result.Post[addr] = pre, post
+		case account.existed:
+			result.Pre[addr] = t.fullAccount(account.balance, account.nonce, account.code, account.storage)
+		case exists:
+			storage := make(map[common.Hash]common.Hash)
+			for key := range account.storage {
+				storage[key] = t.db.GetState(addr, key)
+			}
+			result.Post[addr] = t.fullAccount(t.db.GetBalance(addr), t.db.GetNonce(addr), t.db.GetCode(addr), storage)
+		}
+	}
+	return result
+}
+
+// fullAccount assembles a diff entry reporting all the fields of an account,
+// omitting empty storage slots.
+func (t *prestateTracer) fullAccount(balance *big.Int, nonce uint64, code []byte, storage map[common.Hash]common.Hash) *accountDiff {
+	diff := &accountDiff{
+		Balance: (*hexutil.Big)(new(big.Int).Set(balance)),
+		Nonce:   &nonce,
+		Storage: make(map[common.Hash]common.Hash),
+	}
+	if len(code) > 0 {
+		blob := hexutil.Bytes(common.CopyBytes(code))
+		diff.Code = &blob
+	}
+	for key, val := range storage {
+		if val != (common.Hash{}) {
+			diff.Storage[key] = val
+		}
+	}
+	return diff
+}
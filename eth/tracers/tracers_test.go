@@ -119,6 +119,19 @@ type callTracerTest struct {
 // Iterates over all the input-output datasets in the tracer test harness and
 // runs the JavaScript tracers against them.
 func TestCallTracer(t *testing.T) {
+	testCallTracer(t, func() (ResultTracer, error) { return New("callTracer") })
+}
+
+// Iterates over all the input-output datasets in the tracer test harness and
+// runs the native call tracer against them.
+func TestCallTracerNative(t *testing.T) {
+	testCallTracer(t, func() (ResultTracer, error) {
+		tracer, _, err := NewNative("callTracer", nil)
+		return tracer, err
+	})
+}
+
+func testCallTracer(t *testing.T, newTracer func() (ResultTracer, error)) {
 	files, err := ioutil.ReadDir("testdata")
 	if err != nil {
 		t.Fatalf("failed to retrieve tracer test suite: %v", err)
@@ -131,63 +144,155 @@ func TestCallTracer(t *testing.T) {
 		t.Run(camel(strings.TrimSuffix(strings.TrimPrefix(file.Name(), "call_tracer_"), ".json")), func(t *testing.T) {
 			t.Parallel()
 
-			// Call tracer test found, read if from disk
-			blob, err := ioutil.ReadFile(filepath.Join("testdata", file.Name()))
+			test := loadCallTracerTest(t, file.Name())
+			tracer, err := newTracer()
 			if err != nil {
-				t.Fatalf("failed to read testcase: %v", err)
-			}
-			test := new(callTracerTest)
-			if err := json.Unmarshal(blob, test); err != nil {
-				t.Fatalf("failed to parse testcase: %v", err)
+				t.Fatalf("failed to create call tracer: %v", err)
 			}
-			// Configure a blockchain with the given prestate
-			tx := new(types.Transaction)
-			if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
-				t.Fatalf("failed to parse testcase input: %v", err)
+			res := runCallTracerTest(t, test, tracer)
+
+			ret := new(callTrace)
+			if err := json.Unmarshal(res, ret); err != nil {
+				t.Fatalf("failed to unmarshal trace result: %v", err)
 			}
-			signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
-			origin, _ := signer.Sender(tx)
-
-			context := vm.Context{
-				CanTransfer: core.CanTransfer,
-				Transfer:    core.Transfer,
-				Origin:      origin,
-				Coinbase:    test.Context.Miner,
-				BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
-				Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
-				Difficulty:  (*big.Int)(test.Context.Difficulty),
-				GasLimit:    uint64(test.Context.GasLimit),
-				GasPrice:    tx.GasPrice(),
+			if !reflect.DeepEqual(ret, test.Result) {
+				t.Fatalf("trace mismatch: have %+v, want %+v", ret, test.Result)
 			}
-			statedb := tests.MakePreState(ethdb.NewMemDatabase(), test.Genesis.Alloc)
+		})
+	}
+}
 
-			// Create the tracer, the EVM environment and run it
-			tracer, err := New("callTracer")
-			if err != nil {
-				t.Fatalf("failed to create call tracer: %v", err)
+// Tests that the native tracers produce the same output as their JavaScript
+// counterparts on all the datasets in the tracer test harness.
+func TestNativeTracersMatchJS(t *testing.T) {
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	for _, name := range []string{"callTracer", "prestateTracer", "4byteTracer"} {
+		for _, file := range files {
+			if !strings.HasPrefix(file.Name(), "call_tracer_") {
+				continue
 			}
-			evm := vm.NewEVM(context, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+			test := loadCallTracerTest(t, file.Name())
 
-			msg, err := tx.AsMessage(signer)
+			jsTracer, err := New(name)
 			if err != nil {
-				t.Fatalf("failed to prepare transaction for tracing: %v", err)
+				t.Fatalf("%s: failed to create JavaScript tracer: %v", name, err)
 			}
-			st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
-			if _, _, _, err = st.TransitionDb(); err != nil {
-				t.Fatalf("failed to execute transaction: %v", err)
+			var want interface{}
+			if err := json.Unmarshal(runCallTracerTest(t, test, jsTracer), &want); err != nil {
+				t.Fatalf("%s/%s: failed to unmarshal JavaScript trace: %v", name, file.Name(), err)
 			}
-			// Retrieve the trace result and compare against the etalon
-			res, err := tracer.GetResult()
-			if err != nil {
-				t.Fatalf("failed to retrieve trace result: %v", err)
+			nativeTracer, ok, err := NewNative(name, nil)
+			if !ok || err != nil {
+				t.Fatalf("%s: failed to create native tracer: %v", name, err)
 			}
-			ret := new(callTrace)
-			if err := json.Unmarshal(res, ret); err != nil {
-				t.Fatalf("failed to unmarshal trace result: %v", err)
+			var have interface{}
+			if err := json.Unmarshal(runCallTracerTest(t, test, nativeTracer), &have); err != nil {
+				t.Fatalf("%s/%s: failed to unmarshal native trace: %v", name, file.Name(), err)
 			}
-			if !reflect.DeepEqual(ret, test.Result) {
-				t.Fatalf("trace mismatch: have %+v, want %+v", ret, test.Result)
+			// Execution times naturally differ between the runs
+			if name == "callTracer" {
+				delete(want.(map[string]interface{}), "time")
+				delete(have.(map[string]interface{}), "time")
 			}
-		})
+			if !reflect.DeepEqual(have, want) {
+				t.Errorf("%s/%s: trace mismatch: have %+v, want %+v", name, file.Name(), have, want)
+			}
+		}
+	}
+}
+
+// Tests that the prestate tracer in diff mode reports the state changes made by
+// the traced transaction.
+func TestPrestateTracerDiff(t *testing.T) {
+	test := loadCallTracerTest(t, "call_tracer_simple.json")
+
+	tracer, _, err := NewNative("prestateTracer", json.RawMessage(`{"diffMode": true}`))
+	if err != nil {
+		t.Fatalf("failed to create prestate tracer: %v", err)
+	}
+	diff := new(prestateDiff)
+	if err := json.Unmarshal(runCallTracerTest(t, test, tracer), diff); err != nil {
+		t.Fatalf("failed to unmarshal trace result: %v", err)
+	}
+	from, to := test.Result.From, test.Result.To
+
+	pre, post := diff.Pre[from], diff.Post[from]
+	if pre == nil || post == nil {
+		t.Fatalf("sender missing from diff: pre %v, post %v", pre, post)
+	}
+	if pre.Nonce == nil || post.Nonce == nil || *post.Nonce != *pre.Nonce+1 {
+		t.Errorf("sender nonce change mismatch: pre %v, post %v", pre.Nonce, post.Nonce)
+	}
+	if pre.Code != nil || post.Code != nil {
+		t.Errorf("unchanged sender code reported")
+	}
+	// The called contract modifies its storage, every slot must be reported
+	// with differing values
+	if diff.Post[to] == nil || len(diff.Post[to].Storage) == 0 {
+		t.Fatalf("contract storage changes missing: %+v", diff.Post[to])
+	}
+	for key, val := range diff.Post[to].Storage {
+		if diff.Pre[to] != nil && diff.Pre[to].Storage[key] == val {
+			t.Errorf("unchanged slot %x reported", key)
+		}
+	}
+}
+
+// loadCallTracerTest reads a dataset of the tracer test harness from disk.
+func loadCallTracerTest(t *testing.T, name string) *callTracerTest {
+	blob, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read testcase: %v", err)
+	}
+	test := new(callTracerTest)
+	if err := json.Unmarshal(blob, test); err != nil {
+		t.Fatalf("failed to parse testcase: %v", err)
+	}
+	return test
+}
+
+// runCallTracerTest executes the transaction of a tracer test dataset on top of
+// its prestate with the given tracer attached, returning the trace result.
+func runCallTracerTest(t *testing.T, test *callTracerTest, tracer ResultTracer) json.RawMessage {
+	// Configure a blockchain with the given prestate
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
+		t.Fatalf("failed to parse testcase input: %v", err)
+	}
+	signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
+	origin, _ := signer.Sender(tx)
+
+	context := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Origin:      origin,
+		Coinbase:    test.Context.Miner,
+		BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+		Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+		Difficulty:  (*big.Int)(test.Context.Difficulty),
+		GasLimit:    uint64(test.Context.GasLimit),
+		GasPrice:    tx.GasPrice(),
+	}
+	statedb := tests.MakePreState(ethdb.NewMemDatabase(), test.Genesis.Alloc)
+
+	// Create the EVM environment and run the tracer in it
+	evm := vm.NewEVM(context, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+
+	msg, err := tx.AsMessage(signer)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction for tracing: %v", err)
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if _, _, _, err = st.TransitionDb(); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	// Retrieve the trace result
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
 	}
+	return res
 }
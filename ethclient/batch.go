@@ -0,0 +1,116 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/rpc"
+)
+
+// maxBatchSize is the maximum number of requests sent in a single batch, kept
+// well below the default batch limit of the nodes. Larger batches are split.
+const maxBatchSize = 256
+
+// batchCall sends the given requests in as few batches as allowed.
+func (ec *Client) batchCall(ctx context.Context, reqs []rpc.BatchElem) error {
+	for len(reqs) > 0 {
+		n := len(reqs)
+		if n > maxBatchSize {
+			n = maxBatchSize
+		}
+		if err := ec.c.BatchCallContext(ctx, reqs[:n]); err != nil {
+			return err
+		}
+		reqs = reqs[n:]
+	}
+	return nil
+}
+
+// batchAccounts issues a request for each of the given accounts in batches and
+// reports the first one failing.
+func (ec *Client) batchAccounts(ctx context.Context, method string, accounts []common.Address, blockNumber *big.Int, result func(i int) interface{}) error {
+	reqs := make([]rpc.BatchElem, len(accounts))
+	for i, account := range accounts {
+		reqs[i] = rpc.BatchElem{
+			Method: method,
+			Args:   []interface{}{account, toBlockNumArg(blockNumber)},
+			Result: result(i),
+		}
+	}
+	if err := ec.batchCall(ctx, reqs); err != nil {
+		return err
+	}
+	for i := range reqs {
+		if reqs[i].Error != nil {
+			return fmt.Errorf("account %x: %v", accounts[i], reqs[i].Error)
+		}
+	}
+	return nil
+}
+
+// BalancesAt returns the wei balances of the given accounts, retrieved in batch
+// requests. The block number can be nil, in which case the balances are taken
+// from the latest known block.
+func (ec *Client) BalancesAt(ctx context.Context, accounts []common.Address, blockNumber *big.Int) ([]*big.Int, error) {
+	results := make([]hexutil.Big, len(accounts))
+	err := ec.batchAccounts(ctx, "eth_getBalance", accounts, blockNumber, func(i int) interface{} { return &results[i] })
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]*big.Int, len(accounts))
+	for i := range results {
+		balances[i] = (*big.Int)(&results[i])
+	}
+	return balances, nil
+}
+
+// NoncesAt returns the nonces of the given accounts, retrieved in batch
+// requests. The block number can be nil, in which case the nonces are taken
+// from the latest known block.
+func (ec *Client) NoncesAt(ctx context.Context, accounts []common.Address, blockNumber *big.Int) ([]uint64, error) {
+	results := make([]hexutil.Uint64, len(accounts))
+	err := ec.batchAccounts(ctx, "eth_getTransactionCount", accounts, blockNumber, func(i int) interface{} { return &results[i] })
+	if err != nil {
+		return nil, err
+	}
+	nonces := make([]uint64, len(accounts))
+	for i := range results {
+		nonces[i] = uint64(results[i])
+	}
+	return nonces, nil
+}
+
+// CodesAt returns the contract codes of the given accounts, retrieved in batch
+// requests. The block number can be nil, in which case the codes are taken
+// from the latest known block.
+func (ec *Client) CodesAt(ctx context.Context, accounts []common.Address, blockNumber *big.Int) ([][]byte, error) {
+	results := make([]hexutil.Bytes, len(accounts))
+	err := ec.batchAccounts(ctx, "eth_getCode", accounts, blockNumber, func(i int) interface{} { return &results[i] })
+	if err != nil {
+		return nil, err
+	}
+	codes := make([][]byte, len(accounts))
+	for i := range results {
+		codes[i] = results[i]
+	}
+	return codes, nil
+}
@@ -32,12 +32,22 @@ import (
 	"github.com/simplechain-org/go-simplechain/rpc"
 )
 
+// Block numbers selecting the blocks considered safe and finalized by the
+// confirmation depths configured on the remote node. They may be passed
+// anywhere a block number is accepted.
+var (
+	SafeBlockNumber      = big.NewInt(int64(rpc.SafeBlockNumber))
+	FinalizedBlockNumber = big.NewInt(int64(rpc.FinalizedBlockNumber))
+)
+
 // Client defines typed wrappers for the Simplechain RPC API.
 type Client struct {
 	c *rpc.Client
 }
 
-// Dial connects a client to the given URL.
+// Dial connects a client to the given URL. Besides the HTTP, websocket and IPC
+// transports, the binary one is selected with the "rlp://host:port" (TCP) and
+// "rlpipc://path" (IPC) schemes.
 func Dial(rawurl string) (*Client, error) {
 	return DialContext(context.Background(), rawurl)
 }
@@ -239,19 +249,91 @@ func (ec *Client) TransactionCount(ctx context.Context, blockHash common.Hash) (
 
 // TransactionInBlock returns a single transaction at index in the given block.
 func (ec *Client) TransactionInBlock(ctx context.Context, blockHash common.Hash, index uint) (*types.Transaction, error) {
+	return ec.transactionInBlock(ctx, "eth_getTransactionByBlockHashAndIndex", blockHash, hexutil.Uint64(index))
+}
+
+// TransactionInBlockByNumber returns a single transaction at index in the block
+// with the given number. The block number can be nil, in which case the
+// transaction is taken from the latest known block.
+func (ec *Client) TransactionInBlockByNumber(ctx context.Context, number *big.Int, index uint) (*types.Transaction, error) {
+	return ec.transactionInBlock(ctx, "eth_getTransactionByBlockNumberAndIndex", toBlockNumArg(number), hexutil.Uint64(index))
+}
+
+func (ec *Client) transactionInBlock(ctx context.Context, method string, args ...interface{}) (*types.Transaction, error) {
 	var json *rpcTransaction
-	err := ec.c.CallContext(ctx, &json, "eth_getTransactionByBlockHashAndIndex", blockHash, hexutil.Uint64(index))
-	if err == nil {
-		if json == nil {
-			return nil, simplechain.NotFound
-		} else if _, r, _ := json.tx.RawSignatureValues(); r == nil {
-			return nil, fmt.Errorf("server returned transaction without signature")
-		}
+	if err := ec.c.CallContext(ctx, &json, method, args...); err != nil {
+		return nil, err
+	}
+	if json == nil {
+		return nil, simplechain.NotFound
+	} else if _, r, _ := json.tx.RawSignatureValues(); r == nil {
+		return nil, fmt.Errorf("server returned transaction without signature")
 	}
 	if json.From != nil && json.BlockHash != nil {
 		setSenderFromServer(json.tx, *json.From, *json.BlockHash)
 	}
-	return json.tx, err
+	return json.tx, nil
+}
+
+// RawTransactionByHash returns the RLP encoding of the transaction with the
+// given hash, pending or included in the chain.
+func (ec *Client) RawTransactionByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	var raw hexutil.Bytes
+	if err := ec.c.CallContext(ctx, &raw, "eth_getRawTransactionByHash", hash); err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, simplechain.NotFound
+	}
+	return raw, nil
+}
+
+// RawTransactionInBlock returns the RLP encoding of the transaction at index in
+// the given block.
+func (ec *Client) RawTransactionInBlock(ctx context.Context, blockHash common.Hash, index uint) ([]byte, error) {
+	var raw hexutil.Bytes
+	if err := ec.c.CallContext(ctx, &raw, "eth_getRawTransactionByBlockHashAndIndex", blockHash, hexutil.Uint64(index)); err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, simplechain.NotFound
+	}
+	return raw, nil
+}
+
+// BlockReceipts returns the receipts of all transactions in the given block,
+// retrieved in a single batch request.
+func (ec *Client) BlockReceipts(ctx context.Context, blockHash common.Hash) ([]*types.Receipt, error) {
+	var block *struct {
+		Transactions []common.Hash `json:"transactions"`
+	}
+	if err := ec.c.CallContext(ctx, &block, "eth_getBlockByHash", blockHash, false); err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, simplechain.NotFound
+	}
+	receipts := make([]*types.Receipt, len(block.Transactions))
+	reqs := make([]rpc.BatchElem, len(block.Transactions))
+	for i, hash := range block.Transactions {
+		reqs[i] = rpc.BatchElem{
+			Method: "eth_getTransactionReceipt",
+			Args:   []interface{}{hash},
+			Result: &receipts[i],
+		}
+	}
+	if err := ec.batchCall(ctx, reqs); err != nil {
+		return nil, err
+	}
+	for i := range reqs {
+		if reqs[i].Error != nil {
+			return nil, reqs[i].Error
+		}
+		if receipts[i] == nil {
+			return nil, fmt.Errorf("got null receipt for transaction %x", block.Transactions[i])
+		}
+	}
+	return receipts, nil
 }
 
 // TransactionReceipt returns the receipt of a transaction by transaction hash.
@@ -271,6 +353,18 @@ func toBlockNumArg(number *big.Int) string {
 	if number == nil {
 		return "latest"
 	}
+	if number.Sign() < 0 && number.IsInt64() {
+		switch rpc.BlockNumber(number.Int64()) {
+		case rpc.LatestBlockNumber:
+			return "latest"
+		case rpc.PendingBlockNumber:
+			return "pending"
+		case rpc.FinalizedBlockNumber:
+			return "finalized"
+		case rpc.SafeBlockNumber:
+			return "safe"
+		}
+	}
 	return hexutil.EncodeBig(number)
 }
 
@@ -313,8 +407,29 @@ func (ec *Client) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header)
 	return ec.c.EthSubscribe(ctx, ch, "newHeads")
 }
 
+// SubscribeFinalizedHead subscribes to notifications about newly finalized headers
+// on the given channel. Every finalized header is delivered in order.
+func (ec *Client) SubscribeFinalizedHead(ctx context.Context, ch chan<- *types.Header) (simplechain.Subscription, error) {
+	return ec.c.EthSubscribe(ctx, ch, "finalizedHeads")
+}
+
+// SubscribePendingTransactions subscribes to notifications about the hashes of
+// the transactions entering the transaction pool of the node.
+func (ec *Client) SubscribePendingTransactions(ctx context.Context, ch chan<- common.Hash) (simplechain.Subscription, error) {
+	return ec.c.EthSubscribe(ctx, ch, "newPendingTransactions")
+}
+
 // State Access
 
+// ChainID retrieves the chain ID used for transaction replay protection.
+func (ec *Client) ChainID(ctx context.Context) (*big.Int, error) {
+	var result hexutil.Big
+	if err := ec.c.CallContext(ctx, &result, "eth_chainId"); err != nil {
+		return nil, err
+	}
+	return (*big.Int)(&result), nil
+}
+
 // NetworkID returns the network ID (also known as the chain ID) for this chain.
 func (ec *Client) NetworkID(ctx context.Context) (*big.Int, error) {
 	version := new(big.Int)
@@ -444,6 +559,42 @@ func (ec *Client) CallContract(ctx context.Context, msg simplechain.CallMsg, blo
 	return hex, nil
 }
 
+// OverrideAccount specifies the fields of an account to override during the
+// execution of a call. Nil fields are left untouched. Storage can either be
+// replaced entirely via State or patched via StateDiff, but not both.
+type OverrideAccount struct {
+	Nonce     *uint64
+	Code      []byte
+	Balance   *big.Int
+	State     map[common.Hash]common.Hash
+	StateDiff map[common.Hash]common.Hash
+}
+
+// StateOverride is the collection of accounts to override during a call.
+type StateOverride map[common.Address]OverrideAccount
+
+// BlockOverrides specifies the fields of the block context to override during
+// the execution of a call. Nil fields are left untouched.
+type BlockOverrides struct {
+	Number     *big.Int
+	Time       *big.Int
+	Coinbase   *common.Address
+	Difficulty *big.Int
+	GasLimit   *uint64
+}
+
+// CallContractWithOverrides executes a message call transaction like CallContract,
+// with the given account states and block context fields overridden for the
+// duration of the call. Both override sets may be nil.
+func (ec *Client) CallContractWithOverrides(ctx context.Context, msg simplechain.CallMsg, blockNumber *big.Int, overrides StateOverride, blockOverrides *BlockOverrides) ([]byte, error) {
+	var hex hexutil.Bytes
+	err := ec.c.CallContext(ctx, &hex, "eth_call", toCallArg(msg), toBlockNumArg(blockNumber), toOverrideArg(overrides), toBlockOverrideArg(blockOverrides))
+	if err != nil {
+		return nil, err
+	}
+	return hex, nil
+}
+
 // PendingCallContract executes a message call transaction using the EVM.
 // The state seen by the contract call is the pending state.
 func (ec *Client) PendingCallContract(ctx context.Context, msg simplechain.CallMsg) ([]byte, error) {
@@ -478,6 +629,18 @@ func (ec *Client) EstimateGas(ctx context.Context, msg simplechain.CallMsg) (uin
 	return uint64(hex), nil
 }
 
+// EstimateGasWithOverrides estimates the gas needed to execute a transaction like
+// EstimateGas, with the given account states and block context fields overridden
+// during the estimation. Both override sets may be nil.
+func (ec *Client) EstimateGasWithOverrides(ctx context.Context, msg simplechain.CallMsg, overrides StateOverride, blockOverrides *BlockOverrides) (uint64, error) {
+	var hex hexutil.Uint64
+	err := ec.c.CallContext(ctx, &hex, "eth_estimateGas", toCallArg(msg), toOverrideArg(overrides), toBlockOverrideArg(blockOverrides))
+	if err != nil {
+		return 0, err
+	}
+	return uint64(hex), nil
+}
+
 // SendTransaction injects a signed transaction into the pending pool for execution.
 //
 // If the transaction was a contract creation use the TransactionReceipt method to get the
@@ -509,3 +672,53 @@ func toCallArg(msg simplechain.CallMsg) interface{} {
 	}
 	return arg
 }
+
+func toOverrideArg(overrides StateOverride) interface{} {
+	if overrides == nil {
+		return nil
+	}
+	result := make(map[common.Address]interface{}, len(overrides))
+	for addr, account := range overrides {
+		arg := make(map[string]interface{})
+		if account.Nonce != nil {
+			arg["nonce"] = hexutil.Uint64(*account.Nonce)
+		}
+		if account.Code != nil {
+			arg["code"] = hexutil.Bytes(account.Code)
+		}
+		if account.Balance != nil {
+			arg["balance"] = (*hexutil.Big)(account.Balance)
+		}
+		if account.State != nil {
+			arg["state"] = account.State
+		}
+		if account.StateDiff != nil {
+			arg["stateDiff"] = account.StateDiff
+		}
+		result[addr] = arg
+	}
+	return result
+}
+
+func toBlockOverrideArg(overrides *BlockOverrides) interface{} {
+	if overrides == nil {
+		return nil
+	}
+	arg := make(map[string]interface{})
+	if overrides.Number != nil {
+		arg["number"] = (*hexutil.Big)(overrides.Number)
+	}
+	if overrides.Time != nil {
+		arg["time"] = (*hexutil.Big)(overrides.Time)
+	}
+	if overrides.Coinbase != nil {
+		arg["coinbase"] = overrides.Coinbase
+	}
+	if overrides.Difficulty != nil {
+		arg["difficulty"] = (*hexutil.Big)(overrides.Difficulty)
+	}
+	if overrides.GasLimit != nil {
+		arg["gasLimit"] = hexutil.Uint64(*overrides.GasLimit)
+	}
+	return arg
+}
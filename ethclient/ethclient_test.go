@@ -16,7 +16,25 @@
 
 package ethclient
 
-import "github.com/simplechain-org/go-simplechain"
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/simplechain-org/go-simplechain"
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/core"
+	"github.com/simplechain-org/go-simplechain/core/state"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/core/vm"
+	"github.com/simplechain-org/go-simplechain/ethdb"
+	"github.com/simplechain-org/go-simplechain/internal/ethapi"
+	"github.com/simplechain-org/go-simplechain/params"
+	"github.com/simplechain-org/go-simplechain/rpc"
+)
 
 // Verify that Client implements the simplechain interfaces.
 var (
@@ -32,3 +50,379 @@ var (
 	// _ = simplechain.PendingStateEventer(&Client{})
 	_ = simplechain.PendingContractCaller(&Client{})
 )
+
+// stateBackend is an API backend serving a single state, sufficient to answer
+// proof requests and execute calls. All other backend methods are left
+// unimplemented.
+type stateBackend struct {
+	ethapi.Backend
+	state *state.StateDB
+}
+
+func (b *stateBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header := &types.Header{
+		Root:       b.state.IntermediateRoot(false),
+		Number:     big.NewInt(10),
+		Time:       big.NewInt(1000),
+		Difficulty: big.NewInt(1),
+		GasLimit:   8000000,
+	}
+	return b.state.Copy(), header, nil
+}
+
+func (b *stateBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	context := core.NewEVMContext(msg, header, nil, &header.Coinbase)
+	return vm.NewEVM(context, state, params.TestChainConfig, vmCfg), func() error { return nil }, nil
+}
+
+func (b *stateBackend) ChainConfig() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+// newStateClient creates an RPC client served by a backend wrapping the given
+// state.
+func newStateClient(t *testing.T, statedb *state.StateDB) (*Client, func()) {
+	server := rpc.NewServer()
+	backend := &stateBackend{state: statedb}
+	if err := server.RegisterName("eth", ethapi.NewPublicBlockChainAPI(backend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	if err := server.RegisterName("eth", ethapi.NewPublicTransactionPoolAPI(backend, new(ethapi.AddrLocker))); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := NewClient(rpc.DialInProc(server))
+	return client, func() {
+		client.Close()
+		server.Stop()
+	}
+}
+
+// Tests that account and storage proofs retrieved via eth_getProof verify
+// against the state root they were generated from, and that tampered proofs
+// are rejected.
+func TestGetProof(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		user     = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		missing  = common.HexToAddress("0x3000000000000000000000000000000000000003")
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	statedb.SetBalance(user, big.NewInt(1000000))
+	statedb.SetNonce(user, 7)
+	statedb.SetCode(contract, []byte{0x60, 0x00})
+	statedb.SetState(contract, common.Hash{}, common.BigToHash(big.NewInt(42)))
+	statedb.SetState(contract, common.Hash{1}, common.BigToHash(big.NewInt(1024)))
+
+	root, _ := statedb.Commit(false)
+	statedb, _ = state.New(root, statedb.Database())
+
+	client, closer := newStateClient(t, statedb)
+	defer closer()
+
+	tests := []struct {
+		account common.Address
+		keys    []common.Hash
+		balance int64
+		values  []int64
+	}{
+		{contract, []common.Hash{{}, {1}, {2}}, 0, []int64{42, 1024, 0}},
+		{user, nil, 1000000, nil},
+		{missing, []common.Hash{{}}, 0, []int64{0}},
+	}
+	for i, tt := range tests {
+		proof, err := client.GetProof(context.Background(), tt.account, tt.keys, nil)
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve proof: %v", i, err)
+		}
+		if err := proof.Verify(root); err != nil {
+			t.Errorf("test %d: failed to verify proof: %v", i, err)
+		}
+		if proof.Balance.Int64() != tt.balance {
+			t.Errorf("test %d: balance mismatch: have %v, want %d", i, proof.Balance, tt.balance)
+		}
+		if len(proof.StorageProof) != len(tt.values) {
+			t.Fatalf("test %d: storage proof count mismatch: have %d, want %d", i, len(proof.StorageProof), len(tt.values))
+		}
+		for j, slot := range proof.StorageProof {
+			if slot.Key != tt.keys[j] || slot.Value.Int64() != tt.values[j] {
+				t.Errorf("test %d, slot %d: value mismatch: have %x=%v, want %x=%d", i, j, slot.Key, slot.Value, tt.keys[j], tt.values[j])
+			}
+		}
+	}
+	// Tamper with reported values and ensure verification fails
+	proof, err := client.GetProof(context.Background(), contract, []common.Hash{{}}, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve proof: %v", err)
+	}
+	proof.StorageProof[0].Value = big.NewInt(43)
+	if err := proof.Verify(root); err == nil {
+		t.Errorf("tampered storage value verified")
+	}
+	proof.StorageProof[0].Value = big.NewInt(42)
+	proof.Balance = big.NewInt(1)
+	if err := proof.Verify(root); err == nil {
+		t.Errorf("tampered balance verified")
+	}
+	proof.Balance = new(big.Int)
+	if err := proof.Verify(common.Hash{1}); err == nil {
+		t.Errorf("proof verified against wrong root")
+	}
+}
+
+// Tests that balances, nonces and codes of many accounts are retrieved in
+// batches, and that the chain ID is reported.
+func TestBatchAccountQueries(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+
+	// Query more accounts than fit in a single batch
+	accounts := make([]common.Address, maxBatchSize+10)
+	for i := range accounts {
+		accounts[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.SetBalance(accounts[i], big.NewInt(int64(1000*i)))
+		statedb.SetNonce(accounts[i], uint64(i))
+		if i%2 == 0 {
+			statedb.SetCode(accounts[i], []byte{byte(i)})
+		}
+	}
+	client, closer := newStateClient(t, statedb)
+	defer closer()
+
+	ctx := context.Background()
+	balances, err := client.BalancesAt(ctx, accounts, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve balances: %v", err)
+	}
+	nonces, err := client.NoncesAt(ctx, accounts, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve nonces: %v", err)
+	}
+	codes, err := client.CodesAt(ctx, accounts, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve codes: %v", err)
+	}
+	if len(balances) != len(accounts) || len(nonces) != len(accounts) || len(codes) != len(accounts) {
+		t.Fatalf("result count mismatch: have %d/%d/%d, want %d", len(balances), len(nonces), len(codes), len(accounts))
+	}
+	for i := range accounts {
+		if balances[i].Int64() != int64(1000*i) {
+			t.Errorf("account %d: balance mismatch: have %v, want %d", i, balances[i], 1000*i)
+		}
+		if nonces[i] != uint64(i) {
+			t.Errorf("account %d: nonce mismatch: have %d, want %d", i, nonces[i], i)
+		}
+		if want := statedb.GetCode(accounts[i]); !bytes.Equal(codes[i], want) {
+			t.Errorf("account %d: code mismatch: have %x, want %x", i, codes[i], want)
+		}
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		t.Fatalf("failed to retrieve chain ID: %v", err)
+	}
+	if chainID.Cmp(params.TestChainConfig.ChainID) != 0 {
+		t.Errorf("chain ID mismatch: have %v, want %v", chainID, params.TestChainConfig.ChainID)
+	}
+}
+
+// Tests that calls and gas estimations can override account states and block
+// context fields without affecting the underlying state.
+func TestCallOverrides(t *testing.T) {
+	var (
+		caller   = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		contract = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		coinbase = common.HexToAddress("0x3000000000000000000000000000000000000003")
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	statedb.SetBalance(caller, big.NewInt(1000000000000))
+	statedb.SetCode(contract, []byte{0x60, 0x00, 0x54, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}) // return sload(0)
+	statedb.SetState(contract, common.Hash{}, common.BigToHash(big.NewInt(1)))
+	statedb.SetState(contract, common.Hash{1}, common.BigToHash(big.NewInt(2)))
+
+	client, closer := newStateClient(t, statedb)
+	defer closer()
+
+	// returning creates code returning the single byte opcode result as a word
+	returning := func(op byte) []byte {
+		return []byte{op, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}
+	}
+	var (
+		number     = big.NewInt(1234)
+		difficulty = big.NewInt(77)
+		gasLimit   = uint64(123456)
+	)
+	tests := []struct {
+		code   []byte
+		state  StateOverride
+		block  *BlockOverrides
+		result *big.Int
+	}{
+		// No overrides, original storage
+		{nil, nil, nil, big.NewInt(1)},
+		// Storage diff patching slot 0
+		{nil, StateOverride{contract: {StateDiff: map[common.Hash]common.Hash{{}: common.BigToHash(big.NewInt(42))}}}, nil, big.NewInt(42)},
+		// Storage replacement dropping slot 0
+		{nil, StateOverride{contract: {State: map[common.Hash]common.Hash{{1}: common.BigToHash(big.NewInt(3))}}}, nil, big.NewInt(0)},
+		// Balance override seen via the BALANCE of CALLER, minus the purchased gas
+		{[]byte{0x33, 0x31, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}, StateOverride{caller: {Balance: big.NewInt(31337000)}}, nil, big.NewInt(31337000 - 100000)},
+		// Block context overrides
+		{returning(0x43), nil, &BlockOverrides{Number: number}, number},
+		{returning(0x42), nil, nil, big.NewInt(1000)},
+		{returning(0x42), nil, &BlockOverrides{Time: big.NewInt(2000)}, big.NewInt(2000)},
+		{returning(0x41), nil, &BlockOverrides{Coinbase: &coinbase}, new(big.Int).SetBytes(coinbase.Bytes())},
+		{returning(0x44), nil, &BlockOverrides{Difficulty: difficulty}, difficulty},
+		{returning(0x45), nil, &BlockOverrides{GasLimit: &gasLimit}, new(big.Int).SetUint64(gasLimit)},
+	}
+	for i, tt := range tests {
+		overrides := tt.state
+		if tt.code != nil {
+			if overrides == nil {
+				overrides = make(StateOverride)
+			}
+			account := overrides[contract]
+			account.Code = tt.code
+			overrides[contract] = account
+		}
+		msg := simplechain.CallMsg{From: caller, To: &contract, Gas: 100000, GasPrice: big.NewInt(1)}
+		res, err := client.CallContractWithOverrides(context.Background(), msg, nil, overrides, tt.block)
+		if err != nil {
+			t.Fatalf("test %d: call failed: %v", i, err)
+		}
+		if have := new(big.Int).SetBytes(res); have.Cmp(tt.result) != 0 {
+			t.Errorf("test %d: result mismatch: have %v, want %v", i, have, tt.result)
+		}
+	}
+	// Ensure the overrides did not leak into the served state
+	if res, err := client.CallContract(context.Background(), simplechain.CallMsg{From: caller, To: &contract, Gas: 100000, GasPrice: big.NewInt(1)}, nil); err != nil {
+		t.Fatalf("call failed: %v", err)
+	} else if have := new(big.Int).SetBytes(res); have.Int64() != 1 {
+		t.Errorf("overrides leaked into state: have %v, want 1", have)
+	}
+	// Conflicting storage overrides must be rejected
+	conflict := StateOverride{contract: {State: map[common.Hash]common.Hash{}, StateDiff: map[common.Hash]common.Hash{}}}
+	if _, err := client.CallContractWithOverrides(context.Background(), simplechain.CallMsg{From: caller, To: &contract, GasPrice: big.NewInt(1)}, nil, conflict, nil); err == nil {
+		t.Errorf("conflicting storage overrides accepted")
+	}
+	// Gas estimation should execute against the overridden code
+	msg := simplechain.CallMsg{From: caller, To: &contract, Gas: 100000, GasPrice: big.NewInt(1)}
+	plain, err := client.EstimateGasWithOverrides(context.Background(), msg, StateOverride{contract: {Code: []byte{}}}, nil)
+	if err != nil {
+		t.Fatalf("failed to estimate gas: %v", err)
+	}
+	if plain != params.TxGas {
+		t.Errorf("plain transfer estimate mismatch: have %d, want %d", plain, params.TxGas)
+	}
+	gas, err := client.EstimateGasWithOverrides(context.Background(), msg, nil, &BlockOverrides{Number: number})
+	if err != nil {
+		t.Fatalf("failed to estimate gas: %v", err)
+	}
+	if gas <= params.TxGas {
+		t.Errorf("contract call estimate too low: have %d, want > %d", gas, params.TxGas)
+	}
+}
+
+func TestCallRevert(t *testing.T) {
+	var (
+		caller   = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		contract = common.HexToAddress("0x2000000000000000000000000000000000000002")
+
+		// abi-encoded Error("revert reason")
+		revert = common.FromHex("08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000")
+	)
+	// Code copying the trailing revert data into memory and reverting with it
+	code := append([]byte{0x60, byte(len(revert)), 0x60, 0x0d, 0x60, 0x00, 0x39, 0x60, byte(len(revert)), 0x60, 0x00, 0xfd}, 0x00)
+	code = append(code, revert...)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	statedb.SetBalance(caller, big.NewInt(1000000000000))
+	statedb.SetCode(contract, code)
+
+	client, closer := newStateClient(t, statedb)
+	defer closer()
+
+	check := func(method string, err error) {
+		if err == nil {
+			t.Fatalf("%s: reverting call succeeded", method)
+		}
+		if want := "execution reverted: revert reason"; err.Error() != want {
+			t.Errorf("%s: error message mismatch: have %q, want %q", method, err.Error(), want)
+		}
+		if rpcErr, ok := err.(rpc.Error); !ok || rpcErr.ErrorCode() != 3 {
+			t.Errorf("%s: error code mismatch: have %v", method, err)
+		}
+		if dataErr, ok := err.(rpc.DataError); !ok {
+			t.Errorf("%s: error data missing", method)
+		} else if data := dataErr.ErrorData(); data != hexutil.Encode(revert) {
+			t.Errorf("%s: error data mismatch: have %v, want %x", method, data, revert)
+		}
+	}
+	msg := simplechain.CallMsg{From: caller, To: &contract, Gas: 100000, GasPrice: big.NewInt(1)}
+
+	_, err := client.CallContract(context.Background(), msg, nil)
+	check("call", err)
+
+	_, err = client.EstimateGas(context.Background(), msg)
+	check("estimate", err)
+}
+
+// tagBackend is an API backend recording the block numbers requested from it and
+// serving an empty block for each of them.
+type tagBackend struct {
+	ethapi.Backend
+	requested []rpc.BlockNumber
+}
+
+func (b *tagBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
+	b.requested = append(b.requested, blockNr)
+	return types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Time: big.NewInt(0), Difficulty: big.NewInt(1)}), nil
+}
+
+func (b *tagBackend) GetTd(hash common.Hash) *big.Int {
+	return big.NewInt(1)
+}
+
+// Tests that the block number tags are sent to the node as their named form.
+func TestBlockNumberTags(t *testing.T) {
+	backend := new(tagBackend)
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", ethapi.NewPublicBlockChainAPI(backend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := NewClient(rpc.DialInProc(server))
+	defer server.Stop()
+	defer client.Close()
+
+	numbers := []*big.Int{nil, SafeBlockNumber, FinalizedBlockNumber, big.NewInt(7)}
+	for _, number := range numbers {
+		if _, err := client.HeaderByNumber(context.Background(), number); err != nil {
+			t.Fatalf("failed to retrieve header %v: %v", number, err)
+		}
+	}
+	want := []rpc.BlockNumber{rpc.LatestBlockNumber, rpc.SafeBlockNumber, rpc.FinalizedBlockNumber, 7}
+	if !reflect.DeepEqual(backend.requested, want) {
+		t.Errorf("requested block mismatch: have %v, want %v", backend.requested, want)
+	}
+}
+
+// Tests that the binary transport is selected through the rlp:// URL scheme.
+func TestDialBinary(t *testing.T) {
+	apis := []rpc.API{{Namespace: "eth", Service: ethapi.NewPublicBlockChainAPI(new(tagBackend)), Public: true}}
+	listener, server, err := rpc.StartBinaryEndpoint("127.0.0.1:0", apis, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to start binary endpoint: %v", err)
+	}
+	defer listener.Close()
+	defer server.Stop()
+
+	client, err := Dial("rlp://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	header, err := client.HeaderByNumber(context.Background(), big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to retrieve header: %v", err)
+	}
+	if header.Number.Uint64() != 1 {
+		t.Errorf("header number mismatch: have %v, want 1", header.Number)
+	}
+}
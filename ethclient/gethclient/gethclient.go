@@ -0,0 +1,210 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package gethclient provides typed access to the node specific txpool and
+// debug RPC APIs, complementing the standard methods of package ethclient.
+package gethclient
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/rpc"
+)
+
+// Client is a wrapper around rpc.Client implementing the node specific APIs.
+type Client struct {
+	c *rpc.Client
+}
+
+// New creates a client that uses the given RPC client.
+func New(c *rpc.Client) *Client {
+	return &Client{c}
+}
+
+// TxPoolStatus is the number of transactions in the transaction pool.
+type TxPoolStatus struct {
+	Pending uint // Transactions executable on the current state
+	Queued  uint // Transactions waiting for a nonce gap to be filled
+}
+
+// TxPoolStatus returns the number of pending and queued transactions in the
+// transaction pool.
+func (gc *Client) TxPoolStatus(ctx context.Context) (*TxPoolStatus, error) {
+	var result struct {
+		Pending hexutil.Uint `json:"pending"`
+		Queued  hexutil.Uint `json:"queued"`
+	}
+	if err := gc.c.CallContext(ctx, &result, "txpool_status"); err != nil {
+		return nil, err
+	}
+	return &TxPoolStatus{Pending: uint(result.Pending), Queued: uint(result.Queued)}, nil
+}
+
+// TxPoolContent returns the pending and queued transactions of the transaction
+// pool, grouped by sender and sorted by nonce.
+func (gc *Client) TxPoolContent(ctx context.Context) (pending, queued map[common.Address][]*types.Transaction, err error) {
+	var result map[string]map[common.Address]map[string]*types.Transaction
+	if err := gc.c.CallContext(ctx, &result, "txpool_content"); err != nil {
+		return nil, nil, err
+	}
+	flatten := func(content map[common.Address]map[string]*types.Transaction) map[common.Address][]*types.Transaction {
+		txs := make(map[common.Address][]*types.Transaction, len(content))
+		for account, dump := range content {
+			for _, tx := range dump {
+				txs[account] = append(txs[account], tx)
+			}
+			sort.Sort(types.TxByNonce(txs[account]))
+		}
+		return txs
+	}
+	return flatten(result["pending"]), flatten(result["queued"]), nil
+}
+
+// TxPoolInspect returns a textual summary of the pending and queued transactions
+// of the transaction pool, grouped by sender and indexed by nonce.
+func (gc *Client) TxPoolInspect(ctx context.Context) (pending, queued map[common.Address]map[uint64]string, err error) {
+	var result map[string]map[common.Address]map[string]string
+	if err := gc.c.CallContext(ctx, &result, "txpool_inspect"); err != nil {
+		return nil, nil, err
+	}
+	index := func(content map[common.Address]map[string]string) (map[common.Address]map[uint64]string, error) {
+		txs := make(map[common.Address]map[uint64]string, len(content))
+		for account, dump := range content {
+			txs[account] = make(map[uint64]string, len(dump))
+			for nonce, summary := range dump {
+				n, err := strconv.ParseUint(nonce, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid nonce %q of %x", nonce, account)
+				}
+				txs[account][n] = summary
+			}
+		}
+		return txs, nil
+	}
+	if pending, err = index(result["pending"]); err != nil {
+		return nil, nil, err
+	}
+	if queued, err = index(result["queued"]); err != nil {
+		return nil, nil, err
+	}
+	return pending, queued, nil
+}
+
+// TraceConfig holds the options of a transaction or block trace.
+type TraceConfig struct {
+	DisableMemory  bool            `json:"disableMemory,omitempty"`
+	DisableStack   bool            `json:"disableStack,omitempty"`
+	DisableStorage bool            `json:"disableStorage,omitempty"`
+	Limit          int             `json:"limit,omitempty"`        // Maximum number of struct logs, zero for unlimited
+	Tracer         *string         `json:"tracer,omitempty"`       // Name or source of the tracer, nil for struct logs
+	TracerConfig   json.RawMessage `json:"tracerConfig,omitempty"` // Configuration of native tracers
+	Timeout        *string         `json:"timeout,omitempty"`      // Duration allowed to the tracer, e.g. "10s"
+	Reexec         *uint64         `json:"reexec,omitempty"`       // Number of blocks re-executed to rebuild a missing state
+	RevertReason   bool            `json:"revertReason,omitempty"`
+}
+
+// TraceResult is the trace of a single transaction of a block.
+type TraceResult struct {
+	Result json.RawMessage `json:"result,omitempty"` // Output of the tracer
+	Error  string          `json:"error,omitempty"`  // Failure of the trace
+}
+
+// TraceTransaction replays the transaction with the given hash and returns the
+// output of the tracer, the struct logs of the execution unless configured
+// otherwise. The config can be nil.
+func (gc *Client) TraceTransaction(ctx context.Context, hash common.Hash, config *TraceConfig) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := gc.c.CallContext(ctx, &result, "debug_traceTransaction", hash, config)
+	return result, err
+}
+
+// TraceBlockByHash replays all transactions of the given block and returns the
+// output of the tracer for each of them. The config can be nil.
+func (gc *Client) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceConfig) ([]*TraceResult, error) {
+	var result []*TraceResult
+	err := gc.c.CallContext(ctx, &result, "debug_traceBlockByHash", hash, config)
+	return result, err
+}
+
+// BlockRLP returns the RLP encoding of the block with the given number.
+func (gc *Client) BlockRLP(ctx context.Context, number uint64) ([]byte, error) {
+	var result string
+	if err := gc.c.CallContext(ctx, &result, "debug_getBlockRlp", number); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(result)
+}
+
+// Preimage returns the preimage of the given hash, if known to the node.
+func (gc *Client) Preimage(ctx context.Context, hash common.Hash) ([]byte, error) {
+	var result hexutil.Bytes
+	err := gc.c.CallContext(ctx, &result, "debug_preimage", hash)
+	return result, err
+}
+
+// ModifiedAccountsByNumber returns the accounts modified between the two given
+// blocks. The end block can be nil, in which case the accounts modified by the
+// start block are returned.
+func (gc *Client) ModifiedAccountsByNumber(ctx context.Context, start uint64, end *uint64) ([]common.Address, error) {
+	var result []common.Address
+	err := gc.c.CallContext(ctx, &result, "debug_getModifiedAccountsByNumber", start, end)
+	return result, err
+}
+
+// StorageEntry is a storage slot of a contract.
+type StorageEntry struct {
+	Key   *common.Hash `json:"key"` // Preimage of the slot hash, nil if unknown
+	Value common.Hash  `json:"value"`
+}
+
+// StorageRange is a range of the storage of a contract, indexed by slot hash.
+type StorageRange struct {
+	Storage map[common.Hash]StorageEntry `json:"storage"`
+	NextKey *common.Hash                 `json:"nextKey"` // Nil if the range reaches the end of the storage
+}
+
+// StorageRangeAt returns up to maxResult storage slots of the contract starting
+// at the given slot hash, in the state after the transaction at txIndex of the
+// given block.
+func (gc *Client) StorageRangeAt(ctx context.Context, blockHash common.Hash, txIndex int, contract common.Address, keyStart []byte, maxResult int) (*StorageRange, error) {
+	var result StorageRange
+	err := gc.c.CallContext(ctx, &result, "debug_storageRangeAt", blockHash, txIndex, contract, hexutil.Bytes(keyStart), maxResult)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ChaindbProperty returns the given property of the chain database, its leveldb
+// statistics if empty.
+func (gc *Client) ChaindbProperty(ctx context.Context, property string) (string, error) {
+	var result string
+	err := gc.c.CallContext(ctx, &result, "debug_chaindbProperty", property)
+	return result, err
+}
+
+// SetHead rewinds the head of the chain of the node to the given block.
+func (gc *Client) SetHead(ctx context.Context, number uint64) error {
+	return gc.c.CallContext(ctx, nil, "debug_setHead", hexutil.Uint64(number))
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gethclient
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/crypto"
+	"github.com/simplechain-org/go-simplechain/internal/ethapi"
+	"github.com/simplechain-org/go-simplechain/rlp"
+	"github.com/simplechain-org/go-simplechain/rpc"
+)
+
+// poolBackend is an API backend serving a fixed transaction pool and block.
+// All other backend methods are left unimplemented.
+type poolBackend struct {
+	ethapi.Backend
+	pending, queued map[common.Address]types.Transactions
+	block           *types.Block
+}
+
+func (b *poolBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	return b.pending, b.queued
+}
+
+func (b *poolBackend) Stats() (int, int) {
+	count := func(txs map[common.Address]types.Transactions) (n int) {
+		for _, list := range txs {
+			n += len(list)
+		}
+		return n
+	}
+	return count(b.pending), count(b.queued)
+}
+
+func (b *poolBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number.Int64() == b.block.Number().Int64() {
+		return b.block, nil
+	}
+	return nil, nil
+}
+
+// Tests that the transaction pool and debug APIs are decoded into their typed
+// form.
+func TestTxPoolAndDebug(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.NewEIP155Signer(big.NewInt(1))
+
+	var txs types.Transactions
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{1}, big.NewInt(1), 21000, big.NewInt(1), nil), signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+	backend := &poolBackend{
+		pending: map[common.Address]types.Transactions{sender: txs[:2]},
+		queued:  map[common.Address]types.Transactions{sender: txs[2:]},
+		block:   types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5), Time: big.NewInt(0), Difficulty: big.NewInt(1)}),
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("txpool", ethapi.NewPublicTxPoolAPI(backend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	if err := server.RegisterName("debug", ethapi.NewPublicDebugAPI(backend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	defer server.Stop()
+	client := New(rpc.DialInProc(server))
+	defer client.c.Close()
+
+	ctx := context.Background()
+	status, err := client.TxPoolStatus(ctx)
+	if err != nil {
+		t.Fatalf("failed to retrieve pool status: %v", err)
+	}
+	if status.Pending != 2 || status.Queued != 1 {
+		t.Errorf("pool status mismatch: have %+v, want 2 pending, 1 queued", status)
+	}
+	pending, queued, err := client.TxPoolContent(ctx)
+	if err != nil {
+		t.Fatalf("failed to retrieve pool content: %v", err)
+	}
+	if len(pending[sender]) != 2 || len(queued[sender]) != 1 {
+		t.Fatalf("pool content mismatch: have %d pending, %d queued", len(pending[sender]), len(queued[sender]))
+	}
+	for i, tx := range append(pending[sender], queued[sender]...) {
+		if tx.Hash() != txs[i].Hash() {
+			t.Errorf("transaction %d mismatch: have %x, want %x", i, tx.Hash(), txs[i].Hash())
+		}
+	}
+	pendingSummary, queuedSummary, err := client.TxPoolInspect(ctx)
+	if err != nil {
+		t.Fatalf("failed to inspect pool: %v", err)
+	}
+	if len(pendingSummary[sender]) != 2 || !strings.Contains(queuedSummary[sender][2], "21000 gas") {
+		t.Errorf("pool summary mismatch: have %v, %v", pendingSummary, queuedSummary)
+	}
+	blob, err := client.BlockRLP(ctx, 5)
+	if err != nil {
+		t.Fatalf("failed to retrieve block: %v", err)
+	}
+	if want, _ := rlp.EncodeToBytes(backend.block); !bytes.Equal(blob, want) {
+		t.Errorf("block encoding mismatch: have %x, want %x", blob, want)
+	}
+	if _, err := client.BlockRLP(ctx, 6); err == nil {
+		t.Error("missing block retrieved")
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/crypto"
+	"github.com/simplechain-org/go-simplechain/ethdb"
+	"github.com/simplechain-org/go-simplechain/rlp"
+	"github.com/simplechain-org/go-simplechain/trie"
+)
+
+// AccountProof is the EIP-1186 Merkle proof of an account and some slots of its
+// storage, as returned by eth_getProof.
+type AccountProof struct {
+	Address      common.Address
+	AccountProof [][]byte
+	Balance      *big.Int
+	CodeHash     common.Hash
+	Nonce        uint64
+	StorageHash  common.Hash
+	StorageProof []StorageProof
+}
+
+// StorageProof is the EIP-1186 Merkle proof of a single storage slot.
+type StorageProof struct {
+	Key   common.Hash
+	Value *big.Int
+	Proof [][]byte
+}
+
+// GetProof returns the Merkle proof of the given account and storage slots.
+// The block number can be nil, in which case the proof is taken from the latest
+// known block.
+func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []common.Hash, blockNumber *big.Int) (*AccountProof, error) {
+	type storageResult struct {
+		Key   string          `json:"key"`
+		Value *hexutil.Big    `json:"value"`
+		Proof []hexutil.Bytes `json:"proof"`
+	}
+	type accountResult struct {
+		Address      common.Address  `json:"address"`
+		AccountProof []hexutil.Bytes `json:"accountProof"`
+		Balance      *hexutil.Big    `json:"balance"`
+		CodeHash     common.Hash     `json:"codeHash"`
+		Nonce        hexutil.Uint64  `json:"nonce"`
+		StorageHash  common.Hash     `json:"storageHash"`
+		StorageProof []storageResult `json:"storageProof"`
+	}
+	if keys == nil {
+		keys = []common.Hash{}
+	}
+	var res accountResult
+	if err := ec.c.CallContext(ctx, &res, "eth_getProof", account, keys, toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	if res.Balance == nil {
+		return nil, fmt.Errorf("missing balance in proof of %x", account)
+	}
+	proof := &AccountProof{
+		Address:      res.Address,
+		AccountProof: fromHexSlice(res.AccountProof),
+		Balance:      res.Balance.ToInt(),
+		CodeHash:     res.CodeHash,
+		Nonce:        uint64(res.Nonce),
+		StorageHash:  res.StorageHash,
+		StorageProof: make([]StorageProof, len(res.StorageProof)),
+	}
+	for i, slot := range res.StorageProof {
+		if slot.Value == nil {
+			return nil, fmt.Errorf("missing value in storage proof of %s", slot.Key)
+		}
+		proof.StorageProof[i] = StorageProof{
+			Key:   common.HexToHash(slot.Key),
+			Value: slot.Value.ToInt(),
+			Proof: fromHexSlice(slot.Proof),
+		}
+	}
+	return proof, nil
+}
+
+// Verify checks the account proof against the given state root and every storage
+// proof against the proven storage root, ensuring all proven values match the
+// ones reported.
+func (p *AccountProof) Verify(root common.Hash) error {
+	val, err := verifyProof(root, crypto.Keccak256(p.Address.Bytes()), p.AccountProof)
+	if err != nil {
+		return fmt.Errorf("account %x: %v", p.Address, err)
+	}
+	// Decode the proven account, a missing one is equivalent to an empty account
+	account := struct {
+		Nonce    uint64
+		Balance  *big.Int
+		Root     common.Hash
+		CodeHash []byte
+	}{0, new(big.Int), types.EmptyRootHash, crypto.Keccak256(nil)}
+
+	if val != nil {
+		if err := rlp.DecodeBytes(val, &account); err != nil {
+			return fmt.Errorf("account %x: invalid proven account: %v", p.Address, err)
+		}
+	}
+	switch {
+	case account.Nonce != p.Nonce:
+		return fmt.Errorf("account %x: nonce mismatch: proven %d, reported %d", p.Address, account.Nonce, p.Nonce)
+	case account.Balance.Cmp(p.Balance) != 0:
+		return fmt.Errorf("account %x: balance mismatch: proven %v, reported %v", p.Address, account.Balance, p.Balance)
+	case account.Root != p.StorageHash:
+		return fmt.Errorf("account %x: storage root mismatch: proven %x, reported %x", p.Address, account.Root, p.StorageHash)
+	case !bytes.Equal(account.CodeHash, p.CodeHash.Bytes()):
+		return fmt.Errorf("account %x: code hash mismatch: proven %x, reported %x", p.Address, account.CodeHash, p.CodeHash)
+	}
+	for _, slot := range p.StorageProof {
+		if err := slot.Verify(p.StorageHash); err != nil {
+			return fmt.Errorf("account %x: %v", p.Address, err)
+		}
+	}
+	return nil
+}
+
+// Verify checks the storage proof against the given storage root, ensuring the
+// proven value matches the reported one.
+func (p *StorageProof) Verify(root common.Hash) error {
+	val, err := verifyProof(root, crypto.Keccak256(p.Key.Bytes()), p.Proof)
+	if err != nil {
+		return fmt.Errorf("slot %x: %v", p.Key, err)
+	}
+	var content []byte
+	if val != nil {
+		if err := rlp.DecodeBytes(val, &content); err != nil {
+			return fmt.Errorf("slot %x: invalid proven value: %v", p.Key, err)
+		}
+	}
+	if value := new(big.Int).SetBytes(content); value.Cmp(p.Value) != 0 {
+		return fmt.Errorf("slot %x: value mismatch: proven %v, reported %v", p.Key, value, p.Value)
+	}
+	return nil
+}
+
+// verifyProof checks a Merkle proof of the given key against the root, returning
+// the proven value or nil if the key is proven to be absent.
+func verifyProof(root common.Hash, key []byte, proof [][]byte) ([]byte, error) {
+	// An empty trie contains nothing, it does not need any nodes as proof
+	if root == types.EmptyRootHash {
+		return nil, nil
+	}
+	db := ethdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	val, _, err := trie.VerifyProof(root, key, db)
+	return val, err
+}
+
+// fromHexSlice converts a list of hex encoded blobs into raw byte slices.
+func fromHexSlice(b []hexutil.Bytes) [][]byte {
+	r := make([][]byte, len(b))
+	for i := range b {
+		r[i] = b[i]
+	}
+	return r
+}
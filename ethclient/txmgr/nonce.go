@@ -0,0 +1,183 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package txmgr
+
+import (
+	"context"
+	"encoding/binary"
+	"sort"
+	"sync"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/ethdb"
+)
+
+var (
+	// nonceKeyPrefix prefixes the keys of the next nonces of the accounts.
+	nonceKeyPrefix = []byte("txmgr-nonce-")
+
+	// releasedKeyPrefix prefixes the keys of the nonces released below the next
+	// ones of the accounts.
+	releasedKeyPrefix = []byte("txmgr-released-")
+)
+
+// NonceBackend is the chain access needed by a nonce manager.
+type NonceBackend interface {
+	// PendingNonceAt retrieves the nonce of an account in the pending state.
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// NonceManager hands out the nonces of the transactions sent by local accounts.
+// The next nonce of each account is persisted, so that transactions not yet
+// known to the node, or dropped by it, don't have their nonce reused across
+// restarts. Nonces released below the next one, e.g. when sending failed while
+// later ones were already reserved, are handed out again first so they don't
+// leave a gap holding back the later transactions. The pending nonce of the node
+// takes over whenever it is ahead, e.g. when the account is also used elsewhere.
+type NonceManager struct {
+	db      ethdb.Database
+	backend NonceBackend
+	lock    sync.Mutex
+}
+
+// NewNonceManager creates a nonce manager persisting the nonces in the given
+// database.
+func NewNonceManager(db ethdb.Database, backend NonceBackend) *NonceManager {
+	return &NonceManager{db: db, backend: backend}
+}
+
+// Next reserves the next nonce of the given account, the lowest released one if
+// any is still unused.
+func (nm *NonceManager) Next(ctx context.Context, account common.Address) (uint64, error) {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+
+	nonce, err := nm.backend.PendingNonceAt(ctx, account)
+	if err != nil {
+		return 0, err
+	}
+	// Skip the released nonces used elsewhere meanwhile
+	released := nm.readReleased(account)
+	stale := 0
+	for stale < len(released) && released[stale] < nonce {
+		stale++
+	}
+	if stale < len(released) {
+		return released[stale], nm.writeReleased(account, released[stale+1:])
+	}
+	if stale > 0 {
+		if err := nm.writeReleased(account, nil); err != nil {
+			return 0, err
+		}
+	}
+	if local, ok := nm.read(account); ok && local > nonce {
+		nonce = local
+	}
+	if err := nm.write(account, nonce+1); err != nil {
+		return 0, err
+	}
+	return nonce, nil
+}
+
+// Release returns a nonce reserved for a transaction that was not sent, to be
+// handed out again by the next reservation.
+func (nm *NonceManager) Release(account common.Address, nonce uint64) error {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+
+	next, ok := nm.read(account)
+	if !ok || nonce >= next {
+		return nil
+	}
+	released := nm.readReleased(account)
+	idx := sort.Search(len(released), func(i int) bool { return released[i] >= nonce })
+	if idx < len(released) && released[idx] == nonce {
+		return nil
+	}
+	released = append(released[:idx], append([]uint64{nonce}, released[idx:]...)...)
+
+	// Released nonces right below the next one are simply not reserved anymore
+	for len(released) > 0 && released[len(released)-1] == next-1 {
+		released, next = released[:len(released)-1], next-1
+	}
+	if err := nm.write(account, next); err != nil {
+		return err
+	}
+	return nm.writeReleased(account, released)
+}
+
+// Reset forgets the nonces reserved for the given account, the pending nonce of
+// the node being used from then on.
+func (nm *NonceManager) Reset(account common.Address) error {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+
+	if err := nm.db.Delete(releasedKey(account)); err != nil {
+		return err
+	}
+	return nm.db.Delete(nonceKey(account))
+}
+
+// read retrieves the persisted next nonce of an account.
+func (nm *NonceManager) read(account common.Address) (uint64, bool) {
+	blob, err := nm.db.Get(nonceKey(account))
+	if err != nil || len(blob) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(blob), true
+}
+
+// write persists the next nonce of an account.
+func (nm *NonceManager) write(account common.Address, nonce uint64) error {
+	blob := make([]byte, 8)
+	binary.BigEndian.PutUint64(blob, nonce)
+	return nm.db.Put(nonceKey(account), blob)
+}
+
+// readReleased retrieves the persisted released nonces of an account, in
+// ascending order.
+func (nm *NonceManager) readReleased(account common.Address) []uint64 {
+	blob, _ := nm.db.Get(releasedKey(account))
+
+	released := make([]uint64, 0, len(blob)/8)
+	for i := 0; i+8 <= len(blob); i += 8 {
+		released = append(released, binary.BigEndian.Uint64(blob[i:]))
+	}
+	return released
+}
+
+// writeReleased persists the released nonces of an account.
+func (nm *NonceManager) writeReleased(account common.Address, released []uint64) error {
+	if len(released) == 0 {
+		return nm.db.Delete(releasedKey(account))
+	}
+	blob := make([]byte, 8*len(released))
+	for i, nonce := range released {
+		binary.BigEndian.PutUint64(blob[8*i:], nonce)
+	}
+	return nm.db.Put(releasedKey(account), blob)
+}
+
+// nonceKey returns the database key of the next nonce of an account.
+func nonceKey(account common.Address) []byte {
+	return append(append([]byte{}, nonceKeyPrefix...), account.Bytes()...)
+}
+
+// releasedKey returns the database key of the released nonces of an account.
+func releasedKey(account common.Address) []byte {
+	return append(append([]byte{}, releasedKeyPrefix...), account.Bytes()...)
+}
@@ -0,0 +1,377 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package txmgr sends transactions on behalf of local accounts, managing their
+// nonces, resubmitting the ones stuck in the transaction pool with a higher gas
+// price and tracking them until they are buried under enough blocks, across
+// chain reorganisations.
+package txmgr
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain"
+	"github.com/simplechain-org/go-simplechain/accounts/abi/bind"
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/core"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/log"
+)
+
+// ErrReplaced is returned when waiting for a transaction whose nonce was used by
+// a transaction not sent by the manager.
+var ErrReplaced = errors.New("nonce used by another transaction")
+
+// Backend is the chain access needed by a manager, provided by ethclient.Client
+// and backends.SimulatedBackend.
+type Backend interface {
+	bind.ContractTransactor
+
+	// NonceAt retrieves the nonce of an account at the given block, the head if nil.
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	// TransactionReceipt retrieves the receipt of an included transaction.
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+	// HeaderByNumber retrieves the header of a canonical block, the head if nil.
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// Config are the configuration parameters of a transaction manager.
+type Config struct {
+	Signer          types.Signer  // Signer of the transactions, homestead if nil
+	ResubmitTimeout time.Duration // Time after which a transaction not yet included is resubmitted
+	PriceBump       uint64        // Gas price increase percentage of resubmissions
+	MaxGasPrice     *big.Int      // Maximum gas price of resubmissions, nil for none
+	Confirmations   uint64        // Number of blocks including and following a transaction to wait for
+	PollInterval    time.Duration // Interval between checks of the chain
+}
+
+// DefaultConfig contains the default configurations for the transaction manager.
+var DefaultConfig = Config{
+	ResubmitTimeout: time.Minute,
+	PriceBump:       core.DefaultTxPoolConfig.PriceBump,
+	Confirmations:   12,
+	PollInterval:    time.Second,
+}
+
+// sanitize checks the provided user configurations and changes anything that's
+// unreasonable or unworkable.
+func (config *Config) sanitize() Config {
+	conf := *config
+	if conf.Signer == nil {
+		conf.Signer = types.HomesteadSigner{}
+	}
+	if conf.ResubmitTimeout <= 0 {
+		log.Warn("Sanitizing invalid txmgr resubmit timeout", "provided", conf.ResubmitTimeout, "updated", DefaultConfig.ResubmitTimeout)
+		conf.ResubmitTimeout = DefaultConfig.ResubmitTimeout
+	}
+	if conf.PriceBump < DefaultConfig.PriceBump {
+		log.Warn("Sanitizing invalid txmgr price bump", "provided", conf.PriceBump, "updated", DefaultConfig.PriceBump)
+		conf.PriceBump = DefaultConfig.PriceBump
+	}
+	if conf.Confirmations < 1 {
+		log.Warn("Sanitizing invalid txmgr confirmations", "provided", conf.Confirmations, "updated", 1)
+		conf.Confirmations = 1
+	}
+	if conf.PollInterval <= 0 {
+		log.Warn("Sanitizing invalid txmgr poll interval", "provided", conf.PollInterval, "updated", DefaultConfig.PollInterval)
+		conf.PollInterval = DefaultConfig.PollInterval
+	}
+	return conf
+}
+
+// Manager sends transactions and sees them through to their confirmation.
+type Manager struct {
+	backend Backend
+	nonces  *NonceManager
+	config  Config
+}
+
+// New creates a transaction manager sending through the given backend, with the
+// nonces of the accounts handed out by the given nonce manager.
+func New(backend Backend, nonces *NonceManager, config Config) *Manager {
+	return &Manager{
+		backend: backend,
+		nonces:  nonces,
+		config:  (&config).sanitize(),
+	}
+}
+
+// Transaction is a transaction sent by a manager, along with the replacements
+// sent for it.
+type Transaction struct {
+	From  common.Address
+	Nonce uint64
+
+	signer bind.SignerFn
+
+	lock     sync.Mutex
+	sent     []*types.Transaction // Transaction and its replacements, the latest last
+	lastSent time.Time            // Time of the last submission
+	included *types.Receipt       // Receipt of the canonical inclusion, nil if none seen
+}
+
+// Current returns the latest version of the transaction sent.
+func (tx *Transaction) Current() *types.Transaction {
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+
+	return tx.sent[len(tx.sent)-1]
+}
+
+// Hashes returns the hashes of all versions of the transaction sent, the latest
+// last.
+func (tx *Transaction) Hashes() []common.Hash {
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+
+	hashes := make([]common.Hash, len(tx.sent))
+	for i, sent := range tx.sent {
+		hashes[i] = sent.Hash()
+	}
+	return hashes
+}
+
+// Send creates, signs and sends a transaction calling the given contract, or
+// creating one if to is nil. The nonce, gas price and gas limit left unset in
+// the options are filled by the manager.
+func (m *Manager) Send(opts *bind.TransactOpts, to *common.Address, data []byte) (*Transaction, error) {
+	if opts.Signer == nil {
+		return nil, errors.New("no signer to authorize the transaction with")
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	value := opts.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	gasPrice := opts.GasPrice
+	if gasPrice == nil {
+		var err error
+		if gasPrice, err = m.backend.SuggestGasPrice(ctx); err != nil {
+			return nil, err
+		}
+	}
+	gasLimit := opts.GasLimit
+	if gasLimit == 0 {
+		var err error
+		msg := simplechain.CallMsg{From: opts.From, To: to, GasPrice: gasPrice, Value: value, Data: data}
+		if gasLimit, err = m.backend.EstimateGas(ctx, msg); err != nil {
+			return nil, err
+		}
+	}
+	// Reserve a nonce unless given one, returning it if the transaction can't be sent
+	var nonce uint64
+	if opts.Nonce != nil {
+		nonce = opts.Nonce.Uint64()
+	} else {
+		var err error
+		if nonce, err = m.nonces.Next(ctx, opts.From); err != nil {
+			return nil, err
+		}
+	}
+	var raw *types.Transaction
+	if to == nil {
+		raw = types.NewContractCreation(nonce, value, gasLimit, gasPrice, data)
+	} else {
+		raw = types.NewTransaction(nonce, *to, value, gasLimit, gasPrice, data)
+	}
+	signed, err := opts.Signer(m.config.Signer, opts.From, raw)
+	if err == nil {
+		err = m.backend.SendTransaction(ctx, signed)
+	}
+	if err != nil {
+		if opts.Nonce == nil {
+			if strings.Contains(err.Error(), core.ErrNonceTooLow.Error()) {
+				m.nonces.Reset(opts.From)
+			} else {
+				m.nonces.Release(opts.From, nonce)
+			}
+		}
+		return nil, err
+	}
+	log.Debug("Sent managed transaction", "from", opts.From, "nonce", nonce, "hash", signed.Hash(), "gasprice", gasPrice)
+	return &Transaction{
+		From:     opts.From,
+		Nonce:    nonce,
+		signer:   opts.Signer,
+		sent:     []*types.Transaction{signed},
+		lastSent: time.Now(),
+	}, nil
+}
+
+// Wait blocks until the given transaction, or one of its replacements, is
+// included in the chain and buried under enough blocks, resubmitting it with a
+// bumped gas price while it's not included and after it is reorganised out of
+// the chain. It stops waiting when the context is canceled.
+func (m *Manager) Wait(ctx context.Context, tx *Transaction) (*types.Receipt, error) {
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := m.check(ctx, tx)
+		if receipt != nil || err != nil {
+			return receipt, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// check looks up the inclusion of a transaction, returning its receipt once it
+// has enough confirmations and resubmitting it if needed. Failures to reach
+// the node are logged and left for the next check to retry.
+func (m *Manager) check(ctx context.Context, tx *Transaction) (*types.Receipt, error) {
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+
+	logger := log.New("from", tx.From, "nonce", tx.Nonce)
+
+	head, err := m.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		logger.Debug("Failed to retrieve head block", "err", err)
+		return nil, nil
+	}
+	receipt, err := m.receipt(ctx, tx)
+	if err != nil {
+		logger.Debug("Failed to retrieve transaction receipt", "err", err)
+		return nil, nil
+	}
+	if receipt != nil {
+		// Included in the chain, wait until buried deep enough
+		if tx.included == nil || tx.included.BlockHash != receipt.BlockHash {
+			logger.Debug("Managed transaction included", "hash", receipt.TxHash, "number", receipt.BlockNumber)
+		}
+		tx.included = receipt
+		if receipt.BlockNumber == nil || head.Number.Uint64()+1 >= receipt.BlockNumber.Uint64()+m.config.Confirmations {
+			return receipt, nil
+		}
+		return nil, nil
+	}
+	if tx.included != nil {
+		// Included in a block no longer canonical, make sure the node knows about it
+		logger.Warn("Managed transaction reorganised out", "hash", tx.included.TxHash, "number", tx.included.BlockNumber)
+		tx.included = nil
+		m.resend(ctx, tx, logger)
+		return nil, nil
+	}
+	// Not included, detect nonces used by foreign transactions
+	nonce, err := m.backend.NonceAt(ctx, tx.From, nil)
+	if err != nil {
+		logger.Debug("Failed to retrieve account nonce", "err", err)
+		return nil, nil
+	}
+	if nonce > tx.Nonce {
+		// Inclusion may have raced with the receipt lookup, check again
+		if receipt, err = m.receipt(ctx, tx); err != nil || receipt != nil {
+			return nil, nil
+		}
+		return nil, ErrReplaced
+	}
+	if time.Since(tx.lastSent) >= m.config.ResubmitTimeout {
+		m.bump(ctx, tx, logger)
+	}
+	return nil, nil
+}
+
+// receipt retrieves the receipt of any version of the transaction included in
+// the canonical chain.
+func (m *Manager) receipt(ctx context.Context, tx *Transaction) (*types.Receipt, error) {
+	for i := len(tx.sent) - 1; i >= 0; i-- {
+		receipt, err := m.backend.TransactionReceipt(ctx, tx.sent[i].Hash())
+		if err == simplechain.NotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if receipt == nil {
+			continue
+		}
+		// Ignore receipts of blocks reorganised out but not yet forgotten
+		if receipt.BlockNumber != nil {
+			header, err := m.backend.HeaderByNumber(ctx, receipt.BlockNumber)
+			if err == simplechain.NotFound {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if header.Hash() != receipt.BlockHash {
+				continue
+			}
+		}
+		return receipt, nil
+	}
+	return nil, nil
+}
+
+// bump resubmits a transaction with its gas price increased by the configured
+// percentage, up to the configured cap. Transactions at the cap are sent again
+// as they are.
+func (m *Manager) bump(ctx context.Context, tx *Transaction, logger log.Logger) {
+	current := tx.sent[len(tx.sent)-1]
+
+	price := new(big.Int).Mul(current.GasPrice(), new(big.Int).SetUint64(100+m.config.PriceBump))
+	price.Div(price, big.NewInt(100))
+	if price.Cmp(current.GasPrice()) <= 0 {
+		price.Add(current.GasPrice(), common.Big1)
+	}
+	if m.config.MaxGasPrice != nil && price.Cmp(m.config.MaxGasPrice) > 0 {
+		price.Set(m.config.MaxGasPrice)
+	}
+	if price.Cmp(current.GasPrice()) <= 0 {
+		logger.Warn("Managed transaction stuck at maximum gas price", "hash", current.Hash(), "gasprice", current.GasPrice())
+		m.resend(ctx, tx, logger)
+		return
+	}
+	var raw *types.Transaction
+	if to := current.To(); to == nil {
+		raw = types.NewContractCreation(current.Nonce(), current.Value(), current.Gas(), price, current.Data())
+	} else {
+		raw = types.NewTransaction(current.Nonce(), *to, current.Value(), current.Gas(), price, current.Data())
+	}
+	signed, err := tx.signer(m.config.Signer, tx.From, raw)
+	if err == nil {
+		err = m.backend.SendTransaction(ctx, signed)
+	}
+	tx.lastSent = time.Now()
+	if err != nil {
+		logger.Warn("Failed to resubmit managed transaction", "hash", current.Hash(), "gasprice", price, "err", err)
+		return
+	}
+	logger.Info("Resubmitted managed transaction", "hash", signed.Hash(), "replaced", current.Hash(), "gasprice", price)
+	tx.sent = append(tx.sent, signed)
+}
+
+// resend sends the latest version of a transaction again, in case the node
+// dropped it.
+func (m *Manager) resend(ctx context.Context, tx *Transaction, logger log.Logger) {
+	current := tx.sent[len(tx.sent)-1]
+	if err := m.backend.SendTransaction(ctx, current); err != nil && !strings.Contains(err.Error(), "known transaction") {
+		logger.Warn("Failed to resend managed transaction", "hash", current.Hash(), "err", err)
+	}
+	tx.lastSent = time.Now()
+}
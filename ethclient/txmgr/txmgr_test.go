@@ -0,0 +1,270 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package txmgr
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/accounts/abi/bind"
+	"github.com/simplechain-org/go-simplechain/accounts/abi/bind/backends"
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/core"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/crypto"
+	"github.com/simplechain-org/go-simplechain/ethdb"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testGenesis = core.GenesisAlloc{testAddr: {Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))}}
+	testTo      = common.HexToAddress("0x0100000000000000000000000000000000000001")
+)
+
+// newTestManager creates a transaction manager sending through a simulated
+// backend, checking the chain every few milliseconds.
+func newTestManager(config Config) (*Manager, *backends.SimulatedBackend) {
+	backend := backends.NewSimulatedBackend(testGenesis)
+
+	config.PollInterval = 5 * time.Millisecond
+	return New(backend, NewNonceManager(ethdb.NewMemDatabase(), backend), config), backend
+}
+
+// waitAsync waits for a transaction in the background.
+func waitAsync(m *Manager, tx *Transaction) (chan *types.Receipt, chan error) {
+	var (
+		receipts = make(chan *types.Receipt, 1)
+		errs     = make(chan error, 1)
+	)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		receipt, err := m.Wait(ctx, tx)
+		if err != nil {
+			errs <- err
+			return
+		}
+		receipts <- receipt
+	}()
+	return receipts, errs
+}
+
+// Tests that nonces are handed out in sequence, persisted across managers and
+// overridden by the node if it's ahead.
+func TestNonceManager(t *testing.T) {
+	backend := backends.NewSimulatedBackend(testGenesis)
+	db := ethdb.NewMemDatabase()
+
+	nonces := NewNonceManager(db, backend)
+	for want := uint64(0); want < 3; want++ {
+		if nonce, err := nonces.Next(context.Background(), testAddr); err != nil || nonce != want {
+			t.Fatalf("nonce mismatch: have %d (%v), want %d", nonce, err, want)
+		}
+	}
+	// Released nonces are reused first, and the last ones are simply unreserved
+	nonces.Release(testAddr, 1)
+	for _, want := range []uint64{1, 3} {
+		if nonce, _ := nonces.Next(context.Background(), testAddr); nonce != want {
+			t.Errorf("released nonce mismatch: have %d, want %d", nonce, want)
+		}
+	}
+	nonces.Release(testAddr, 3)
+	nonces.Release(testAddr, 2)
+	for _, want := range []uint64{2, 3} {
+		if nonce, _ := nonces.Next(context.Background(), testAddr); nonce != want {
+			t.Errorf("unreserved nonce mismatch: have %d, want %d", nonce, want)
+		}
+	}
+	// Nonces are persisted, along with the released ones
+	nonces.Release(testAddr, 2)
+	nonces = NewNonceManager(db, backend)
+	for _, want := range []uint64{2, 4} {
+		if nonce, _ := nonces.Next(context.Background(), testAddr); nonce != want {
+			t.Errorf("persisted nonce mismatch: have %d, want %d", nonce, want)
+		}
+	}
+	// The node takes over once reset or ahead
+	nonces.Reset(testAddr)
+	if nonce, _ := nonces.Next(context.Background(), testAddr); nonce != 0 {
+		t.Errorf("reset nonce mismatch: have %d, want 0", nonce)
+	}
+	other := bind.NewKeyedTransactor(testKey)
+	for i := uint64(0); i < 5; i++ {
+		tx, _ := other.Signer(types.HomesteadSigner{}, testAddr, types.NewTransaction(i, testTo, big.NewInt(1), 21000, big.NewInt(1), nil))
+		backend.SendTransaction(context.Background(), tx)
+	}
+	if nonce, _ := nonces.Next(context.Background(), testAddr); nonce != 5 {
+		t.Errorf("node nonce mismatch: have %d, want 5", nonce)
+	}
+}
+
+// Tests that transactions not included in time are resubmitted with a bumped
+// gas price, and that the replacement is tracked to its inclusion.
+func TestGasPriceBump(t *testing.T) {
+	m, backend := newTestManager(Config{ResubmitTimeout: 50 * time.Millisecond, PriceBump: 10, Confirmations: 1})
+
+	opts := bind.NewKeyedTransactor(testKey)
+	opts.GasPrice = big.NewInt(1000000000)
+	opts.Value = big.NewInt(1)
+
+	tx, err := m.Send(opts, &testTo, nil)
+	if err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	receipts, errs := waitAsync(m, tx)
+
+	// Hold the transaction back until it's resubmitted twice
+	for len(tx.Hashes()) < 3 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	backend.Commit()
+
+	select {
+	case receipt := <-receipts:
+		if hashes := tx.Hashes(); receipt.TxHash != hashes[len(hashes)-1] {
+			t.Errorf("included transaction mismatch: have %x, want %x", receipt.TxHash, hashes[len(hashes)-1])
+		}
+	case err := <-errs:
+		t.Fatalf("failed to wait for transaction: %v", err)
+	}
+	if price := tx.Current().GasPrice(); price.Cmp(big.NewInt(1210000000)) < 0 {
+		t.Errorf("gas price mismatch: have %v, want at least %v", price, 1210000000)
+	}
+	if nonce, _ := backend.NonceAt(context.Background(), testAddr, nil); nonce != 1 {
+		t.Errorf("account nonce mismatch: have %d, want 1", nonce)
+	}
+}
+
+// Tests that the gas price of resubmissions is capped.
+func TestGasPriceCap(t *testing.T) {
+	m, backend := newTestManager(Config{ResubmitTimeout: 10 * time.Millisecond, PriceBump: 10, MaxGasPrice: big.NewInt(1050000000), Confirmations: 1})
+
+	opts := bind.NewKeyedTransactor(testKey)
+	opts.GasPrice = big.NewInt(1000000000)
+
+	tx, err := m.Send(opts, &testTo, nil)
+	if err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	receipts, errs := waitAsync(m, tx)
+
+	time.Sleep(100 * time.Millisecond)
+	backend.Commit()
+
+	select {
+	case <-receipts:
+	case err := <-errs:
+		t.Fatalf("failed to wait for transaction: %v", err)
+	}
+	if hashes := tx.Hashes(); len(hashes) != 2 {
+		t.Errorf("submission count mismatch: have %d, want 2", len(hashes))
+	}
+	if price := tx.Current().GasPrice(); price.Cmp(big.NewInt(1050000000)) != 0 {
+		t.Errorf("gas price mismatch: have %v, want %v", price, 1050000000)
+	}
+}
+
+// Tests that transactions are only reported once confirmed, and that the ones
+// reorganised out of the chain are resubmitted and tracked to their inclusion
+// in the new chain.
+func TestConfirmationsAndReorg(t *testing.T) {
+	m, backend := newTestManager(Config{ResubmitTimeout: time.Hour, Confirmations: 3})
+	genesis, _ := backend.HeaderByNumber(context.Background(), big.NewInt(0))
+
+	tx, err := m.Send(bind.NewKeyedTransactor(testKey), &testTo, nil)
+	if err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	receipts, errs := waitAsync(m, tx)
+
+	backend.Commit()
+	backend.Commit()
+	original, _ := backend.HeaderByNumber(context.Background(), big.NewInt(1))
+
+	// Wait for the inclusion to be seen, and make sure it's not reported early
+	for {
+		tx.lock.Lock()
+		included := tx.included != nil
+		tx.lock.Unlock()
+		if included {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case receipt := <-receipts:
+		t.Fatalf("transaction reported with 2 confirmations: %v", receipt)
+	case err := <-errs:
+		t.Fatalf("failed to wait for transaction: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// Replace the chain by a longer one without the transaction, which must be
+	// included again in the new chain
+	if err := backend.Fork(context.Background(), genesis.Hash()); err != nil {
+		t.Fatalf("failed to fork chain: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		backend.Commit()
+	}
+	for {
+		select {
+		case receipt := <-receipts:
+			if receipt.BlockHash == original.Hash() {
+				t.Fatalf("receipt of reorganised block reported")
+			}
+			header, _ := backend.HeaderByNumber(context.Background(), receipt.BlockNumber)
+			if header.Hash() != receipt.BlockHash {
+				t.Fatalf("receipt of non-canonical block %x reported", receipt.BlockHash)
+			}
+			head, _ := backend.HeaderByNumber(context.Background(), nil)
+			if confirmations := head.Number.Uint64() - receipt.BlockNumber.Uint64() + 1; confirmations < 3 {
+				t.Errorf("confirmations mismatch: have %d, want at least 3", confirmations)
+			}
+			return
+		case err := <-errs:
+			t.Fatalf("failed to wait for transaction: %v", err)
+		case <-time.After(20 * time.Millisecond):
+			backend.Commit()
+		}
+	}
+}
+
+// Tests that waiting fails if the nonce of a transaction is used by another one.
+func TestReplacedTransaction(t *testing.T) {
+	m, backend := newTestManager(Config{ResubmitTimeout: time.Hour, Confirmations: 1})
+
+	opts := bind.NewKeyedTransactor(testKey)
+	opts.GasPrice = big.NewInt(1)
+	tx, err := m.Send(opts, &testTo, nil)
+	if err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	// Replace the transaction behind the manager's back
+	foreign, _ := opts.Signer(types.HomesteadSigner{}, testAddr, types.NewTransaction(0, testTo, big.NewInt(5), 21000, big.NewInt(2), nil))
+	if err := backend.SendTransaction(context.Background(), foreign); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	backend.Commit()
+
+	if _, err := m.Wait(context.Background(), tx); err != ErrReplaced {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrReplaced)
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/consensus/ethash"
+	"github.com/simplechain-org/go-simplechain/core"
+	"github.com/simplechain-org/go-simplechain/core/bloombits"
+	"github.com/simplechain-org/go-simplechain/core/state"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/core/vm"
+	"github.com/simplechain-org/go-simplechain/crypto"
+	"github.com/simplechain-org/go-simplechain/eth/filters"
+	"github.com/simplechain-org/go-simplechain/ethdb"
+	"github.com/simplechain-org/go-simplechain/internal/ethapi"
+	"github.com/simplechain-org/go-simplechain/params"
+	"github.com/simplechain-org/go-simplechain/rpc"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testEmitter = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testTopic   = common.HexToHash("0x07")
+)
+
+// testBackend is an API backend serving the chain data of a local blockchain.
+// Methods not needed by the GraphQL resolvers are left unimplemented.
+type testBackend struct {
+	ethapi.Backend
+	db    ethdb.Database
+	chain *core.BlockChain
+}
+
+func (b *testBackend) ChainDb() ethdb.Database { return b.db }
+
+func (b *testBackend) ChainConfig() *params.ChainConfig { return b.chain.Config() }
+
+func (b *testBackend) CurrentBlock() *types.Block { return b.chain.CurrentBlock() }
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return b.chain.CurrentHeader(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return b.chain.GetHeaderByHash(hash), nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return b.chain.CurrentBlock(), nil
+	}
+	return b.chain.GetBlockByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return b.chain.GetBlockByHash(hash), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header, _ := b.HeaderByNumber(ctx, number)
+	if header == nil {
+		return nil, nil, nil
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *testBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	context := core.NewEVMContext(msg, header, b.chain, nil)
+	return vm.NewEVM(context, state, b.chain.Config(), vmCfg), state.Error, nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.chain.GetReceiptsByHash(hash), nil
+}
+
+func (b *testBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	receipts := b.chain.GetReceiptsByHash(hash)
+	logs := make([][]*types.Log, len(receipts))
+	for i, receipt := range receipts {
+		logs[i] = receipt.Logs
+	}
+	return logs, nil
+}
+
+func (b *testBackend) GetTd(hash common.Hash) *big.Int {
+	return b.chain.GetTdByHash(hash)
+}
+
+func (b *testBackend) ProtocolVersion() int { return 1 }
+
+func (b *testBackend) GetPoolTransaction(hash common.Hash) *types.Transaction { return nil }
+
+func (b *testBackend) BloomStatus() (uint64, uint64)    { return params.BloomBitsBlocks, 0 }
+func (b *testBackend) LogIndexStatus() (uint64, uint64) { return 0, 0 }
+
+func (b *testBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {}
+
+// newTestBackend creates a chain of three blocks, the second of which contains
+// a transaction calling a contract which emits a single log.
+func newTestBackend(t *testing.T) (*testBackend, *types.Transaction) {
+	// log1(0, 1, topic); stop
+	code := []byte{0x60, byte(testTopic[31]), 0x60, 0x01, 0x60, 0x00, 0xa1, 0x00}
+
+	var (
+		engine = ethash.NewFaker()
+		db     = ethdb.NewMemDatabase()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testAddr:    {Balance: big.NewInt(1000000)},
+				testEmitter: {Balance: big.NewInt(0), Code: code},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	tx, _ := types.SignTx(types.NewTransaction(0, testEmitter, big.NewInt(10), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, testKey)
+
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 3, func(i int, block *core.BlockGen) {
+		if i == 1 {
+			block.AddTx(tx)
+		}
+	})
+	chain, _ := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	return &testBackend{db: db, chain: chain}, tx
+}
+
+// query executes a GraphQL query over HTTP, decoding the data of the response.
+func query(t *testing.T, handler http.Handler, body string, data interface{}) {
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("query failed with status %d: %s", rec.Code, rec.Body.String())
+	}
+	var res struct {
+		Data   json.RawMessage
+		Errors []interface{}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(res.Errors) > 0 {
+		t.Fatalf("query returned errors: %v", res.Errors)
+	}
+	if err := json.Unmarshal(res.Data, data); err != nil {
+		t.Fatalf("failed to decode data: %v", err)
+	}
+}
+
+// Tests that nested block, transaction, receipt, log and account data can be
+// fetched with a single query.
+func TestBlockQuery(t *testing.T) {
+	backend, tx := newTestBackend(t)
+	handler, err := newHandler(backend, filters.Config{})
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	var data struct {
+		Block struct {
+			Number       string
+			Hash         common.Hash
+			Parent       struct{ Number string }
+			Transactions []struct {
+				Hash    common.Hash
+				Index   int
+				Status  string
+				GasUsed string
+				From    struct{ Address common.Address }
+				To      struct {
+					Address common.Address
+					Balance string
+				}
+				Logs []struct {
+					Index   int
+					Topics  []common.Hash
+					Data    string
+					Account struct{ Address common.Address }
+				}
+			}
+		}
+	}
+	query(t, handler, `{"query": "{ block(number: 2) { number hash parent { number } transactions { hash index status gasUsed from { address } to { address balance } logs { index topics data account { address } } } } }"}`, &data)
+
+	block := backend.chain.GetBlockByNumber(2)
+	if data.Block.Number != "0x2" || data.Block.Hash != block.Hash() || data.Block.Parent.Number != "0x1" {
+		t.Errorf("block mismatch: %+v", data.Block)
+	}
+	if len(data.Block.Transactions) != 1 {
+		t.Fatalf("transaction count mismatch: have %d, want 1", len(data.Block.Transactions))
+	}
+	have := data.Block.Transactions[0]
+	if have.Hash != tx.Hash() || have.Index != 0 || have.Status != "0x1" || have.GasUsed == "" {
+		t.Errorf("transaction mismatch: %+v", have)
+	}
+	if have.From.Address != testAddr || have.To.Address != testEmitter || have.To.Balance != "0xa" {
+		t.Errorf("transaction accounts mismatch: %+v, %+v", have.From, have.To)
+	}
+	if len(have.Logs) != 1 {
+		t.Fatalf("log count mismatch: have %d, want 1", len(have.Logs))
+	}
+	if log := have.Logs[0]; len(log.Topics) != 1 || log.Topics[0] != testTopic || log.Data != "0x00" || log.Account.Address != testEmitter {
+		t.Errorf("log mismatch: %+v", log)
+	}
+	// Unknown blocks resolve to null
+	var missing struct{ Block *struct{ Number string } }
+	query(t, handler, `{"query": "{ block(number: 100) { number } }"}`, &missing)
+	if missing.Block != nil {
+		t.Errorf("unknown block returned: %+v", missing.Block)
+	}
+}
+
+// Tests that transactions are resolved by hash and logs can be filtered over
+// a range of blocks.
+func TestTransactionAndLogsQuery(t *testing.T) {
+	backend, tx := newTestBackend(t)
+	handler, err := newHandler(backend, filters.Config{})
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	var data struct {
+		Transaction struct {
+			Nonce string
+			Value string
+			Block struct{ Number string }
+		}
+		Logs []struct {
+			Transaction struct{ Hash common.Hash }
+		}
+		Blocks []struct{ Number string }
+	}
+	body := `{"query": "query($hash: Bytes32!) { transaction(hash: $hash) { nonce value block { number } } logs(filter: {fromBlock: 0, addresses: [\"` + testEmitter.Hex() + `\"]}) { transaction { hash } } blocks(from: 1) { number } }", "variables": {"hash": "` + tx.Hash().Hex() + `"}}`
+	query(t, handler, body, &data)
+
+	if data.Transaction.Nonce != "0x0" || data.Transaction.Value != "0xa" || data.Transaction.Block.Number != "0x2" {
+		t.Errorf("transaction mismatch: %+v", data.Transaction)
+	}
+	if len(data.Logs) != 1 || data.Logs[0].Transaction.Hash != tx.Hash() {
+		t.Errorf("logs mismatch: %+v", data.Logs)
+	}
+	if len(data.Blocks) != 3 || data.Blocks[0].Number != "0x1" || data.Blocks[2].Number != "0x3" {
+		t.Errorf("blocks mismatch: %+v", data.Blocks)
+	}
+}
+
+// Tests that log queries over block ranges are subject to the log query limits
+// of the server.
+func TestLogsQueryLimits(t *testing.T) {
+	backend, tx := newTestBackend(t)
+	handler, err := newHandler(backend, filters.Config{LogRangeLimit: 2})
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	// Ranges within the limit are served
+	var data struct {
+		Logs []struct {
+			Transaction struct{ Hash common.Hash }
+		}
+	}
+	query(t, handler, `{"query": "{ logs(filter: {fromBlock: 1, toBlock: 2}) { transaction { hash } } }"}`, &data)
+	if len(data.Logs) != 1 || data.Logs[0].Transaction.Hash != tx.Hash() {
+		t.Errorf("logs mismatch: %+v", data.Logs)
+	}
+	// Wider ranges are rejected with the limit error
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "{ logs(filter: {fromBlock: 0}) { index } }"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var res struct {
+		Errors []struct{ Message string }
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(res.Errors) != 1 || !strings.Contains(res.Errors[0].Message, "exceeding the limit of 2 blocks") {
+		t.Errorf("range limit error mismatch: %+v", res.Errors)
+	}
+}
+
+// Tests that the service enforces the configured virtual hosts.
+func TestServiceVirtualHosts(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	service, err := New(backend, filters.Config{}, "127.0.0.1:0", nil, []string{"localhost"}, rpc.DefaultHTTPTimeouts)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(nil); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	url := "http://" + service.listener.Addr().String() + "/graphql"
+	for host, code := range map[string]int{"localhost": http.StatusOK, "evil.com": http.StatusForbidden} {
+		req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"query": "{ protocolVersion }"}`))
+		req.Host = host
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != code {
+			t.Errorf("host %s: status mismatch: have %d, want %d", host, res.StatusCode, code)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/core"
+	"github.com/simplechain-org/go-simplechain/core/state"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/core/vm"
+	"github.com/simplechain-org/go-simplechain/log"
+	"github.com/simplechain-org/go-simplechain/rpc"
+)
+
+// errExecutionReverted is reported for calls which were aborted by the REVERT
+// opcode, with the revert data available as the return value.
+var errExecutionReverted = errors.New("execution reverted")
+
+// CallResult is the outcome of a single call executed as part of a bundle.
+type CallResult struct {
+	ReturnValue hexutil.Bytes  `json:"returnValue"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+	Logs        []*types.Log   `json:"logs"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// BundleHooks allow callers to customise the execution of the individual calls
+// of a bundle, e.g. to attach a tracer to each of them.
+type BundleHooks struct {
+	// Config, if set, returns the VM configuration to execute the i'th call with.
+	Config func(i int) (vm.Config, error)
+
+	// Done, if set, is invoked after the i'th call was executed with its result.
+	Done func(i int, result *CallResult) error
+}
+
+// ApplyBundle executes a sequence of calls on top of the given state, with the
+// state changes of each call visible to the following ones. Calls failing with
+// an error are reported in their results and don't modify the state.
+//
+// The state is modified in place, callers are expected to pass a copy.
+func ApplyBundle(ctx context.Context, b Backend, bundle []CallArgs, statedb *state.StateDB, header *types.Header, blockOverrides *BlockOverrides, hooks *BundleHooks) ([]*CallResult, error) {
+	if hooks == nil {
+		hooks = new(BundleHooks)
+	}
+	var (
+		results = make([]*CallResult, len(bundle))
+		logs    = 0
+	)
+	for i, args := range bundle {
+		// Abort the whole bundle if the deadline was reached
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vmCfg := vm.Config{}
+		if hooks.Config != nil {
+			cfg, err := hooks.Config(i)
+			if err != nil {
+				return nil, err
+			}
+			vmCfg = cfg
+		}
+		msg := args.toMessage(b)
+
+		evm, vmError, err := b.GetEVM(ctx, msg, statedb, header, vmCfg)
+		if err != nil {
+			return nil, err
+		}
+		blockOverrides.Apply(&evm.Context)
+
+		// Interrupt the call if the context is cancelled during execution
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				evm.Cancel()
+			case <-done:
+			}
+		}()
+		// Logs are collected under a single hash, split them up per call
+		statedb.Prepare(common.Hash{}, header.Hash(), i)
+
+		ret, gas, failed, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(math.MaxUint64))
+		close(done)
+		if err := vmError(); err != nil {
+			return nil, err
+		}
+		result := &CallResult{
+			ReturnValue: ret,
+			GasUsed:     hexutil.Uint64(gas),
+			Logs:        []*types.Log{},
+		}
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case failed && len(ret) > 0:
+			result.Error = newRevertError(ret).Error()
+		case failed:
+			result.Error = "execution failed"
+		default:
+			all := statedb.GetLogs(common.Hash{})
+			result.Logs = append(result.Logs, all[logs:]...)
+			logs = len(all)
+		}
+		statedb.Finalise(b.ChainConfig().IsEIP158(evm.BlockNumber))
+
+		if hooks.Done != nil {
+			if err := hooks.Done(i, result); err != nil {
+				return nil, err
+			}
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// CallMany executes a bundle of calls sequentially on the state of the given
+// block number, with the state changes of each call carried over to the next
+// ones. It doesn't make any changes in the state/blockchain.
+//
+// Optionally, the state of some accounts and fields of the block context can be
+// overridden for the duration of the bundle.
+func (s *PublicBlockChainAPI) CallMany(ctx context.Context, bundle []CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride, blockOverrides *BlockOverrides) ([]*CallResult, error) {
+	defer func(start time.Time) {
+		log.Debug("Executing EVM call bundle finished", "calls", len(bundle), "runtime", time.Since(start))
+	}(time.Now())
+
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	if err := overrides.Apply(state); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return ApplyBundle(ctx, s.b, bundle, state, header, blockOverrides, nil)
+}
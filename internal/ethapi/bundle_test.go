@@ -0,0 +1,121 @@
+// Copyright 2018 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/core"
+	"github.com/simplechain-org/go-simplechain/core/state"
+	"github.com/simplechain-org/go-simplechain/core/types"
+	"github.com/simplechain-org/go-simplechain/core/vm"
+	"github.com/simplechain-org/go-simplechain/ethdb"
+	"github.com/simplechain-org/go-simplechain/params"
+)
+
+// bundleBackend is an API backend able to execute calls, all other methods are
+// left unimplemented.
+type bundleBackend struct {
+	Backend
+}
+
+func (b *bundleBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+
+func (b *bundleBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	context := core.NewEVMContext(msg, header, nil, &header.Coinbase)
+	return vm.NewEVM(context, state, params.TestChainConfig, vmCfg), func() error { return nil }, nil
+}
+
+// Tests that the calls of a bundle are executed sequentially with state changes
+// carried over, and that results, logs and errors are reported per call.
+func TestApplyBundle(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		counter  = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		reverter = common.HexToAddress("0x3000000000000000000000000000000000000003")
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	statedb.SetBalance(sender, big.NewInt(1000000000))
+
+	// counter increments slot 0, logs and returns the new value
+	statedb.SetCode(counter, common.FromHex("6000546001018060005560005260206000a060206000f3"))
+	// reverter reverts with a single word of data
+	statedb.SetCode(reverter, common.FromHex("60ff60005260206000fd"))
+
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(1), Difficulty: big.NewInt(1), GasLimit: 8000000}
+	call := func(to common.Address, value int64) CallArgs {
+		return CallArgs{From: sender, To: &to, Gas: 100000, GasPrice: hexutil.Big(*big.NewInt(1)), Value: hexutil.Big(*big.NewInt(value))}
+	}
+	bundle := []CallArgs{
+		call(counter, 0),
+		call(counter, 0),
+		call(reverter, 0),
+		call(counter, 0),
+		call(counter, 1000000000000),
+	}
+	var configs, dones int
+	hooks := &BundleHooks{
+		Config: func(i int) (vm.Config, error) {
+			configs++
+			return vm.Config{}, nil
+		},
+		Done: func(i int, result *CallResult) error {
+			dones++
+			return nil
+		},
+	}
+	results, err := ApplyBundle(context.Background(), &bundleBackend{}, bundle, statedb, header, nil, hooks)
+	if err != nil {
+		t.Fatalf("failed to apply bundle: %v", err)
+	}
+	if configs != len(bundle) || dones != len(bundle) {
+		t.Errorf("hook invocation mismatch: config %d, done %d, want %d", configs, dones, len(bundle))
+	}
+	tests := []struct {
+		value int64
+		logs  int
+		err   string
+	}{
+		{1, 1, ""},
+		{2, 1, ""},
+		{0xff, 0, errExecutionReverted.Error()},
+		{3, 1, ""},
+		{0, 0, vm.ErrInsufficientBalance.Error()},
+	}
+	for i, tt := range tests {
+		result := results[i]
+		if result.Error != tt.err {
+			t.Errorf("call %d: error mismatch: have %q, want %q", i, result.Error, tt.err)
+		}
+		if have := new(big.Int).SetBytes(result.ReturnValue); have.Int64() != tt.value {
+			t.Errorf("call %d: return value mismatch: have %v, want %d", i, have, tt.value)
+		}
+		if len(result.Logs) != tt.logs {
+			t.Errorf("call %d: log count mismatch: have %d, want %d", i, len(result.Logs), tt.logs)
+		}
+		if tt.err == "" && result.GasUsed <= hexutil.Uint64(params.TxGas) {
+			t.Errorf("call %d: gas used too low: %d", i, result.GasUsed)
+		}
+	}
+	if have := statedb.GetState(counter, common.Hash{}).Big(); have.Int64() != 3 {
+		t.Errorf("final counter mismatch: have %v, want 3", have)
+	}
+}
@@ -18,6 +18,7 @@ package les
 
 import (
 	"context"
+	"errors"
 	"math/big"
 
 	"github.com/simplechain-org/go-simplechain/accounts"
@@ -38,6 +39,10 @@ import (
 	"github.com/simplechain-org/go-simplechain/rpc"
 )
 
+// errPrivateTxUnsupported is returned if a private transaction is submitted to a
+// light client, which has no local miner to hand it to.
+var errPrivateTxUnsupported = errors.New("private transactions not supported by light clients")
+
 type LesApiBackend struct {
 	eth *LightSimplechain
 	gpo *gasprice.Oracle
@@ -60,7 +65,27 @@ func (b *LesApiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNum
 	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
 		return b.eth.blockchain.CurrentHeader(), nil
 	}
-	return b.eth.blockchain.GetHeaderByNumberOdr(ctx, uint64(blockNr))
+	return b.eth.blockchain.GetHeaderByNumberOdr(ctx, uint64(b.confirmedNumber(blockNr)))
+}
+
+// confirmedNumber resolves the safe and finalized block tags to the number of
+// the header the configured confirmation depth below the chain head. Other block
+// numbers are returned unchanged.
+func (b *LesApiBackend) confirmedNumber(blockNr rpc.BlockNumber) rpc.BlockNumber {
+	var depth uint64
+	switch blockNr {
+	case rpc.SafeBlockNumber:
+		depth = b.eth.config.SafeDepth
+	case rpc.FinalizedBlockNumber:
+		depth = b.eth.config.FinalizedDepth
+	default:
+		return blockNr
+	}
+	head := b.eth.blockchain.CurrentHeader().Number.Uint64()
+	if head < depth {
+		return rpc.EarliestBlockNumber
+	}
+	return rpc.BlockNumber(head - depth)
 }
 
 func (b *LesApiBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
@@ -115,6 +140,10 @@ func (b *LesApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction)
 	return b.eth.txPool.Add(ctx, signedTx)
 }
 
+func (b *LesApiBackend) SendPrivateTx(ctx context.Context, signedTx *types.Transaction) error {
+	return errPrivateTxUnsupported
+}
+
 func (b *LesApiBackend) RemoveTx(txHash common.Hash) {
 	b.eth.txPool.RemoveTx(txHash)
 }
@@ -143,6 +172,10 @@ func (b *LesApiBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.S
 	return b.eth.txPool.SubscribeNewTxsEvent(ch)
 }
 
+func (b *LesApiBackend) SubscribeTxPoolEvent(ch chan<- core.TxPoolEvent) event.Subscription {
+	return b.eth.txPool.SubscribeTxPoolEvent(ch)
+}
+
 func (b *LesApiBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return b.eth.blockchain.SubscribeChainEvent(ch)
 }
@@ -195,6 +228,10 @@ func (b *LesApiBackend) BloomStatus() (uint64, uint64) {
 	return light.BloomTrieFrequency, sections
 }
 
+func (b *LesApiBackend) LogIndexStatus() (uint64, uint64) {
+	return 0, 0
+}
+
 func (b *LesApiBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	for i := 0; i < bloomFilterThreads; i++ {
 		go session.Multiplex(bloomRetrievalBatch, bloomRetrievalWait, b.eth.bloomRequests)
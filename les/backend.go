@@ -191,7 +191,7 @@ func (s *LightSimplechain) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.ApiBackend, true),
+			Service:   filters.NewPublicFilterAPI(s.ApiBackend, true, filters.Config{LogRangeLimit: s.config.LogQueryRangeLimit, LogResultLimit: s.config.LogQueryResultLimit}),
 			Public:    true,
 		}, {
 			Namespace: "net",
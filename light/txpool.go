@@ -53,6 +53,7 @@ type TxPool struct {
 	signer       types.Signer
 	quit         chan bool
 	txFeed       event.Feed
+	changeFeed   event.Feed
 	scope        event.SubscriptionScope
 	chainHeadCh  chan core.ChainHeadEvent
 	chainHeadSub event.Subscription
@@ -187,11 +188,14 @@ func (pool *TxPool) checkMinedTxs(ctx context.Context, hash common.Hash, number
 		rawdb.WriteTxLookupEntries(pool.chainDb, block)
 
 		// Update the transaction pool's state
+		changes := make([]core.TxChange, 0, len(list))
 		for _, tx := range list {
 			delete(pool.pending, tx.Hash())
 			txc.setState(tx.Hash(), true)
+			changes = append(changes, core.TxChange{Tx: tx, Reason: core.TxChangeIncluded})
 		}
 		pool.mined[hash] = list
+		go pool.changeFeed.Send(core.TxPoolEvent{Changes: changes})
 	}
 	return nil
 }
@@ -201,13 +205,16 @@ func (pool *TxPool) checkMinedTxs(ctx context.Context, hash common.Hash, number
 func (pool *TxPool) rollbackTxs(hash common.Hash, txc txStateChanges) {
 	batch := pool.chainDb.NewBatch()
 	if list, ok := pool.mined[hash]; ok {
+		changes := make([]core.TxChange, 0, len(list))
 		for _, tx := range list {
 			txHash := tx.Hash()
 			rawdb.DeleteTxLookupEntry(batch, txHash)
 			pool.pending[txHash] = tx
 			txc.setState(txHash, false)
+			changes = append(changes, core.TxChange{Tx: tx, Reason: core.TxChangeAdded})
 		}
 		delete(pool.mined, hash)
+		go pool.changeFeed.Send(core.TxPoolEvent{Changes: changes})
 	}
 	batch.Write()
 }
@@ -329,6 +336,12 @@ func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subsc
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeTxPoolEvent registers a subscription of core.TxPoolEvent and
+// starts sending event to the given channel.
+func (pool *TxPool) SubscribeTxPoolEvent(ch chan<- core.TxPoolEvent) event.Subscription {
+	return pool.scope.Track(pool.changeFeed.Subscribe(ch))
+}
+
 // Stats returns the number of currently pending (locally created) transactions
 func (pool *TxPool) Stats() (pending int) {
 	pool.mu.RLock()
@@ -415,6 +428,7 @@ func (self *TxPool) add(ctx context.Context, tx *types.Transaction) error {
 		// because it's possible that somewhere during the post "Remove transaction"
 		// gets called which will then wait for the global tx pool lock and deadlock.
 		go self.txFeed.Send(core.NewTxsEvent{Txs: types.Transactions{tx}})
+		go self.changeFeed.Send(core.TxPoolEvent{Changes: []core.TxChange{{Tx: tx, Reason: core.TxChangeAdded}}})
 	}
 
 	// Print a log message if low enough level is set
@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/simplechain-org/go-simplechain/accounts"
 	"github.com/simplechain-org/go-simplechain/accounts/keystore"
@@ -124,8 +125,28 @@ type Config struct {
 	// interface.
 	HTTPTimeouts rpc.HTTPTimeouts
 
+	// GraphQLHost is the host interface on which to start the GraphQL server. If this
+	// field is empty, no GraphQL endpoint will be started.
+	GraphQLHost string `toml:",omitempty"`
+
+	// GraphQLPort is the TCP port number on which to start the GraphQL server. The
+	// default zero value is/ valid and will pick a port number randomly (useful
+	// for ephemeral nodes).
+	GraphQLPort int `toml:",omitempty"`
+
+	// GraphQLCors is the Cross-Origin Resource Sharing header to send to requesting
+	// clients. Please be aware that CORS is a browser enforced security, it's fully
+	// useless for custom HTTP clients.
+	GraphQLCors []string `toml:",omitempty"`
+
+	// GraphQLVirtualHosts is the list of virtual hostnames which are allowed on incoming requests.
+	// This is by default {'localhost'}.
+	GraphQLVirtualHosts []string `toml:",omitempty"`
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
-	// this field is empty, no websocket API endpoint will be started.
+	// this field is empty, no websocket API endpoint will be started. If the host
+	// and port are the same as the HTTP ones, websocket upgrades are served by the
+	// HTTP RPC server on its port.
 	WSHost string `toml:",omitempty"`
 
 	// WSPort is the TCP port number on which to start the websocket RPC server. The
@@ -150,10 +171,79 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// BinaryHost is the host interface on which to start the binary RPC server,
+	// exchanging JSON-RPC messages in length prefixed RLP frames over TCP. If this
+	// field is empty, no binary API endpoint will be started.
+	BinaryHost string `toml:",omitempty"`
+
+	// BinaryPort is the TCP port number on which to start the binary RPC server.
+	// The default zero value is valid and will pick a port number randomly.
+	BinaryPort int `toml:",omitempty"`
+
+	// BinaryModules is a list of API modules to expose via the binary RPC
+	// interface. If the module list is empty, all RPC API endpoints designated
+	// public will be exposed.
+	BinaryModules []string `toml:",omitempty"`
+
+	// RPCJWTSecret is the file holding the hex encoded HS256 secret used to verify
+	// the JWT bearer tokens of HTTP, websocket and binary requests. An empty path
+	// disables JWT authentication.
+	RPCJWTSecret string `toml:",omitempty"`
+
+	// RPCJWTAccess is the list of namespaces and methods callable with a valid JWT.
+	RPCJWTAccess []string `toml:",omitempty"`
+
+	// RPCAPIKeys maps static API keys accepted as bearer tokens to the namespaces
+	// and methods callable with each of them.
+	RPCAPIKeys map[string][]string `toml:",omitempty"`
+
+	// RPCAuthIPC requires connections to the IPC endpoint to authenticate too.
+	RPCAuthIPC bool `toml:",omitempty"`
+
+	// RPCLimits is the request budget enforced on the HTTP, websocket and binary
+	// RPC endpoints. Requests are unlimited if nil.
+	RPCLimits *rpc.LimitConfig `toml:",omitempty"`
+
+	// RPCBatchItemLimit is the maximum number of requests in a batch, zero for no
+	// limit. Larger batches are rejected without being executed.
+	RPCBatchItemLimit int `toml:",omitempty"`
+
+	// RPCResponseSizeLimit is the maximum size in bytes of a response (or of all
+	// the responses of a batch) over HTTP and WebSocket, zero for no limit. The
+	// local IPC endpoint is never limited.
+	RPCResponseSizeLimit int `toml:",omitempty"`
+
+	// RPCSlowRequestThreshold is the execution time above which RPC requests are
+	// logged along with their parameters, zero to disable the logging.
+	RPCSlowRequestThreshold time.Duration `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 }
 
+// RPCAuth resolves the credentials required by the RPC endpoints, nil if no
+// authentication is configured.
+func (c *Config) RPCAuth() (*rpc.AuthConfig, error) {
+	if c.RPCJWTSecret == "" && len(c.RPCAPIKeys) == 0 {
+		return nil, nil
+	}
+	auth := &rpc.AuthConfig{JWTAccess: c.RPCJWTAccess}
+	if c.RPCJWTSecret != "" {
+		secret, err := rpc.ReadJWTSecret(c.RPCJWTSecret)
+		if err != nil {
+			return nil, err
+		}
+		auth.JWTSecret = secret
+	}
+	if len(c.RPCAPIKeys) > 0 {
+		auth.APIKeys = make(map[string]rpc.ACL)
+		for key, acl := range c.RPCAPIKeys {
+			auth.APIKeys[key] = acl
+		}
+	}
+	return auth, nil
+}
+
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
 // account the set data folders as well as the designated platform we're currently
 // running on.
@@ -214,6 +304,24 @@ func DefaultHTTPEndpoint() string {
 	return config.HTTPEndpoint()
 }
 
+// GraphQLEndpoint resolves a GraphQL endpoint based on the configured host interface
+// and port parameters.
+func (c *Config) GraphQLEndpoint() string {
+	if c.GraphQLHost == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.GraphQLHost, c.GraphQLPort)
+}
+
+// BinaryEndpoint resolves a binary RPC endpoint based on the configured host
+// interface and port parameters.
+func (c *Config) BinaryEndpoint() string {
+	if c.BinaryHost == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.BinaryHost, c.BinaryPort)
+}
+
 // WSEndpoint resolves a websocket endpoint based on the configured host interface
 // and port parameters.
 func (c *Config) WSEndpoint() string {
@@ -18,6 +18,7 @@ package node
 
 import (
 	"bytes"
+	"encoding/hex"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -148,3 +149,44 @@ func TestNodeKeyPersistency(t *testing.T) {
 		t.Fatalf("ephemeral node key persisted to disk")
 	}
 }
+
+// Tests that the RPC credentials are resolved from the configuration, loading the
+// JWT secret from its file.
+func TestRPCAuthResolution(t *testing.T) {
+	if auth, err := (&Config{}).RPCAuth(); auth != nil || err != nil {
+		t.Fatalf("authentication resolved without credentials: %v, %v", auth, err)
+	}
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := bytes.Repeat([]byte{0x42}, 32)
+	path := filepath.Join(dir, "jwtsecret")
+	if err := ioutil.WriteFile(path, []byte("0x"+hex.EncodeToString(secret)+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write JWT secret: %v", err)
+	}
+	config := &Config{
+		RPCJWTSecret: path,
+		RPCJWTAccess: []string{"eth"},
+		RPCAPIKeys:   map[string][]string{"key": {"admin_peers"}},
+	}
+	auth, err := config.RPCAuth()
+	if err != nil {
+		t.Fatalf("failed to resolve authentication: %v", err)
+	}
+	if !bytes.Equal(auth.JWTSecret, secret) {
+		t.Errorf("JWT secret mismatch: have %x, want %x", auth.JWTSecret, secret)
+	}
+	if !auth.JWTAccess.Allows("eth_call") || !auth.APIKeys["key"].Allows("admin_peers") {
+		t.Errorf("access lists mismatch: %v, %v", auth.JWTAccess, auth.APIKeys)
+	}
+	// Short secrets must be rejected
+	if err := ioutil.WriteFile(path, []byte("0x42"), 0600); err != nil {
+		t.Fatalf("failed to write JWT secret: %v", err)
+	}
+	if _, err := config.RPCAuth(); err == nil {
+		t.Error("short JWT secret accepted")
+	}
+}
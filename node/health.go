@@ -0,0 +1,161 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+)
+
+// healthCheckTimeout is the time allowed to gather the status of the node when
+// answering a health check.
+const healthCheckTimeout = 5 * time.Second
+
+// healthStatus is the response of the health check endpoints.
+type healthStatus struct {
+	Healthy bool     `json:"healthy"`
+	Peers   int      `json:"peers"`
+	Syncing *bool    `json:"syncing,omitempty"` // Nil if the node doesn't sync a chain
+	Head    *uint64  `json:"head,omitempty"`    // Nil if the node doesn't sync a chain
+	HeadAge *float64 `json:"headAge,omitempty"` // Seconds since the head block was produced
+	Errors  []string `json:"errors,omitempty"`  // Failed checks
+}
+
+// healthChecks are the conditions a node must meet to be considered healthy,
+// set through the query parameters of a health check.
+type healthChecks struct {
+	minPeers   int           // Minimum number of connected peers ("min_peers")
+	maxHeadAge time.Duration // Maximum age of the head block, zero for any ("max_head_age")
+	synced     bool          // Whether the node must not be syncing ("synced")
+}
+
+// parseHealthChecks reads the conditions of a health check from its query
+// parameters. The head age is either a number of seconds or a duration.
+func parseHealthChecks(r *http.Request, synced bool) (*healthChecks, error) {
+	var (
+		query  = r.URL.Query()
+		checks = &healthChecks{synced: synced}
+		err    error
+	)
+	if value := query.Get("min_peers"); value != "" {
+		if checks.minPeers, err = strconv.Atoi(value); err != nil || checks.minPeers < 0 {
+			return nil, fmt.Errorf("invalid min_peers: %q", value)
+		}
+	}
+	if value := query.Get("max_head_age"); value != "" {
+		if seconds, err := strconv.ParseUint(value, 10, 64); err == nil {
+			checks.maxHeadAge = time.Duration(seconds) * time.Second
+		} else if checks.maxHeadAge, err = time.ParseDuration(value); err != nil || checks.maxHeadAge < 0 {
+			return nil, fmt.Errorf("invalid max_head_age: %q", value)
+		}
+	}
+	if value := query.Get("synced"); value != "" {
+		if checks.synced, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("invalid synced: %q", value)
+		}
+	}
+	return checks, nil
+}
+
+// healthHandler returns the handler of a health check endpoint, answering with
+// the status of the node and whether it meets the conditions requested in the
+// query. Readiness checks additionally require the node to be synced unless
+// told otherwise.
+func (n *Node) healthHandler(readiness bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks, err := parseHealthChecks(r, readiness)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+
+		status := n.checkHealth(ctx, checks)
+
+		w.Header().Set("Content-Type", "application/json")
+		if !status.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+}
+
+// checkHealth gathers the status of the node and checks it against the given
+// conditions.
+func (n *Node) checkHealth(ctx context.Context, checks *healthChecks) *healthStatus {
+	status := new(healthStatus)
+	fail := func(format string, args ...interface{}) {
+		status.Errors = append(status.Errors, fmt.Sprintf(format, args...))
+	}
+	server := n.Server()
+	if server == nil {
+		fail("node not running")
+		return status
+	}
+	status.Peers = server.PeerCount()
+	if status.Peers < checks.minPeers {
+		fail("too few peers: have %d, want at least %d", status.Peers, checks.minPeers)
+	}
+	// Query the chain status through the in-process endpoint, nodes without a
+	// chain only failing the checks needing it
+	client, err := n.Attach()
+	if err != nil {
+		fail("node not running")
+		return status
+	}
+	defer client.Close()
+
+	var syncing json.RawMessage
+	if err := client.CallContext(ctx, &syncing, "eth_syncing"); err == nil {
+		status.Syncing = new(bool)
+		*status.Syncing = string(syncing) != "false"
+	} else if checks.synced {
+		fail("sync status unavailable: %v", err)
+	}
+	if status.Syncing != nil && *status.Syncing && checks.synced {
+		fail("node is syncing")
+	}
+	var head struct {
+		Number *hexutil.Big `json:"number"`
+		Time   *hexutil.Big `json:"timestamp"`
+	}
+	err = client.CallContext(ctx, &head, "eth_getBlockByNumber", "latest", false)
+	if err == nil && (head.Number == nil || head.Time == nil) {
+		err = errors.New("no head block")
+	}
+	if err == nil {
+		number := head.Number.ToInt().Uint64()
+		age := time.Since(time.Unix(head.Time.ToInt().Int64(), 0)).Seconds()
+		status.Head, status.HeadAge = &number, &age
+
+		if checks.maxHeadAge > 0 && age > checks.maxHeadAge.Seconds() {
+			fail("head block too old: %ds, want at most %ds", int64(age), int64(checks.maxHeadAge.Seconds()))
+		}
+	} else if checks.maxHeadAge > 0 {
+		fail("head block unavailable: %v", err)
+	}
+	status.Healthy = len(status.Errors) == 0
+	return status
+}
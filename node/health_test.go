@@ -0,0 +1,153 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/rpc"
+)
+
+// ChainService is a service exposing a fake chain status to health checks.
+type ChainService struct {
+	NoopService
+
+	syncing bool
+	head    time.Time
+}
+
+func (s *ChainService) APIs() []rpc.API {
+	return []rpc.API{{Namespace: "eth", Version: "1.0", Service: &ChainAPI{s}, Public: true}}
+}
+
+type ChainAPI struct {
+	service *ChainService
+}
+
+func (api *ChainAPI) Syncing() interface{} {
+	if api.service.syncing {
+		return map[string]interface{}{"currentBlock": hexutil.Uint64(10), "highestBlock": hexutil.Uint64(20)}
+	}
+	return false
+}
+
+func (api *ChainAPI) GetBlockByNumber(number string, fullTx bool) map[string]interface{} {
+	return map[string]interface{}{
+		"number":    hexutil.Uint64(10),
+		"timestamp": hexutil.Uint64(api.service.head.Unix()),
+	}
+}
+
+// Tests that the health check endpoints report the status of the node and fail
+// if it doesn't meet the conditions of the query.
+func TestHealthCheck(t *testing.T) {
+	config := testNodeConfig()
+	config.HTTPHost = "127.0.0.1"
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	service := &ChainService{head: time.Now().Add(-2 * time.Minute)}
+	if err := stack.Register(func(*ServiceContext) (Service, error) { return service, nil }); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	url := "http://" + stack.httpListener.Addr().String()
+	tests := []struct {
+		path    string
+		syncing bool
+		code    int
+	}{
+		{path: "/health", code: http.StatusOK},
+		{path: "/health", syncing: true, code: http.StatusOK},
+		{path: "/health?min_peers=0", code: http.StatusOK},
+		{path: "/health?min_peers=1", code: http.StatusServiceUnavailable},
+		{path: "/health?max_head_age=60", code: http.StatusServiceUnavailable},
+		{path: "/health?max_head_age=5m", code: http.StatusOK},
+		{path: "/health?synced=true", syncing: true, code: http.StatusServiceUnavailable},
+		{path: "/health?min_peers=many", code: http.StatusBadRequest},
+		{path: "/health?max_head_age=-1s", code: http.StatusBadRequest},
+		{path: "/ready", code: http.StatusOK},
+		{path: "/ready", syncing: true, code: http.StatusServiceUnavailable},
+		{path: "/ready?synced=false", syncing: true, code: http.StatusOK},
+	}
+	for i, tt := range tests {
+		service.syncing = tt.syncing
+
+		resp, err := http.Get(url + tt.path)
+		if err != nil {
+			t.Fatalf("test %d: health check failed: %v", i, err)
+		}
+		if resp.StatusCode != tt.code {
+			t.Errorf("test %d: %s status mismatch: have %d, want %d", i, tt.path, resp.StatusCode, tt.code)
+		}
+		if tt.code != http.StatusBadRequest {
+			var status healthStatus
+			if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+				t.Errorf("test %d: invalid health status: %v", i, err)
+			} else if status.Healthy != (tt.code == http.StatusOK) || status.Head == nil || *status.Head != 10 ||
+				status.Syncing == nil || *status.Syncing != tt.syncing || (status.Healthy != (len(status.Errors) == 0)) {
+				t.Errorf("test %d: health status mismatch: %+v", i, status)
+			}
+		}
+		resp.Body.Close()
+	}
+}
+
+// Tests that HTTP and websocket RPC requests are served on the same port if the
+// endpoints are configured identically.
+func TestSharedHTTPWebsocketEndpoint(t *testing.T) {
+	config := testNodeConfig()
+	config.HTTPHost, config.WSHost = "127.0.0.1", "127.0.0.1"
+	config.HTTPVirtualHosts = []string{"*"}
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if stack.wsListener != nil || stack.wsHandler == nil {
+		t.Fatal("websocket endpoint not served by the HTTP listener")
+	}
+	addr := stack.httpListener.Addr().String()
+	for _, url := range []string{"http://" + addr, "ws://" + addr} {
+		client, err := rpc.Dial(url)
+		if err != nil {
+			t.Fatalf("failed to dial %s: %v", url, err)
+		}
+		var version string
+		if err := client.Call(&version, "web3_clientVersion"); err != nil {
+			t.Errorf("%s: request failed: %v", url, err)
+		}
+		client.Close()
+	}
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	if stack.wsHandler != nil || stack.wsShared {
+		t.Error("shared websocket endpoint not stopped along with the HTTP one")
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -51,8 +52,9 @@ type Node struct {
 	serviceFuncs []ServiceConstructor     // Service constructors (in dependency order)
 	services     map[reflect.Type]Service // Currently running services
 
-	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
-	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
+	rpcAPIs       []rpc.API           // List of APIs currently provided by the node
+	rpcConfig     *rpc.EndpointConfig // Request policy enforced by the network endpoints
+	inprocHandler *rpc.Server         // In-process RPC request handler to process the API requests
 
 	ipcEndpoint string       // IPC endpoint to listen at (empty = IPC disabled)
 	ipcListener net.Listener // IPC RPC listener socket to serve API requests
@@ -66,6 +68,11 @@ type Node struct {
 	wsEndpoint string       // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
+	wsShared   bool         // Whether websocket upgrades are served by the HTTP listener
+
+	binaryEndpoint string       // Binary RPC endpoint (interface + port) to listen at (empty = binary disabled)
+	binaryListener net.Listener // Binary RPC listener socket to serve API requests
+	binaryHandler  *rpc.Server  // Binary RPC request handler to process the API requests
 
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
@@ -116,6 +123,7 @@ func New(conf *Config) (*Node, error) {
 		ipcEndpoint:       conf.IPCEndpoint(),
 		httpEndpoint:      conf.HTTPEndpoint(),
 		wsEndpoint:        conf.WSEndpoint(),
+		binaryEndpoint:    conf.BinaryEndpoint(),
 		eventmux:          new(event.TypeMux),
 		log:               conf.Logger,
 	}, nil
@@ -255,6 +263,18 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	for _, service := range services {
 		apis = append(apis, service.APIs()...)
 	}
+	auth, err := n.config.RPCAuth()
+	if err != nil {
+		return err
+	}
+	n.rpcConfig = &rpc.EndpointConfig{
+		Auth:                 auth,
+		Limits:               n.config.RPCLimits,
+		BatchItemLimit:       n.config.RPCBatchItemLimit,
+		ResponseSizeLimit:    n.config.RPCResponseSizeLimit,
+		SlowRequestThreshold: n.config.RPCSlowRequestThreshold,
+	}
+
 	// Start the various API endpoints, terminating all in case of errors
 	if err := n.startInProc(apis); err != nil {
 		return err
@@ -263,12 +283,23 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 		n.stopInProc()
 		return err
 	}
-	if err := n.startHTTP(n.httpEndpoint, apis, n.config.HTTPModules, n.config.HTTPCors, n.config.HTTPVirtualHosts, n.config.HTTPTimeouts); err != nil {
+	// Serve websocket upgrades on the HTTP port if both endpoints are the same
+	shared := n.httpEndpoint != "" && n.httpEndpoint == n.wsEndpoint
+	if err := n.startHTTP(n.httpEndpoint, apis, n.config.HTTPModules, n.config.HTTPCors, n.config.HTTPVirtualHosts, n.config.HTTPTimeouts, shared); err != nil {
 		n.stopIPC()
 		n.stopInProc()
 		return err
 	}
-	if err := n.startWS(n.wsEndpoint, apis, n.config.WSModules, n.config.WSOrigins, n.config.WSExposeAll); err != nil {
+	if !shared {
+		if err := n.startWS(n.wsEndpoint, apis, n.config.WSModules, n.config.WSOrigins, n.config.WSExposeAll); err != nil {
+			n.stopHTTP()
+			n.stopIPC()
+			n.stopInProc()
+			return err
+		}
+	}
+	if err := n.startBinary(n.binaryEndpoint, apis, n.config.BinaryModules); err != nil {
+		n.stopWS()
 		n.stopHTTP()
 		n.stopIPC()
 		n.stopInProc()
@@ -306,7 +337,14 @@ func (n *Node) startIPC(apis []rpc.API) error {
 	if n.ipcEndpoint == "" {
 		return nil // IPC disabled.
 	}
-	listener, handler, err := rpc.StartIPCEndpoint(n.ipcEndpoint, apis)
+	config := &rpc.EndpointConfig{
+		BatchItemLimit:       n.rpcConfig.BatchItemLimit,
+		SlowRequestThreshold: n.rpcConfig.SlowRequestThreshold,
+	}
+	if n.config.RPCAuthIPC {
+		config.Auth = n.rpcConfig.Auth
+	}
+	listener, handler, err := rpc.StartIPCEndpoint(n.ipcEndpoint, apis, config)
 	if err != nil {
 		return err
 	}
@@ -330,22 +368,58 @@ func (n *Node) stopIPC() {
 	}
 }
 
-// startHTTP initializes and starts the HTTP RPC endpoint.
-func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors []string, vhosts []string, timeouts rpc.HTTPTimeouts) error {
+// startHTTP initializes and starts the HTTP RPC endpoint, along with the health
+// check endpoints and, if requested, the websocket endpoint on the same port.
+func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors []string, vhosts []string, timeouts rpc.HTTPTimeouts, withWS bool) error {
 	// Short circuit if the HTTP endpoint isn't being exposed
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, timeouts)
+	handler, err := rpc.NewEndpointServer(apis, modules, false, n.rpcConfig)
 	if err != nil {
 		return err
 	}
+	server := rpc.NewHTTPServer(cors, vhosts, timeouts, handler)
+
+	var wsHandler *rpc.Server
+	if withWS {
+		if wsHandler, err = rpc.NewEndpointServer(apis, n.config.WSModules, n.config.WSExposeAll, n.rpcConfig); err != nil {
+			handler.Stop()
+			return err
+		}
+		server.Handler = rpc.NewHTTPWSHandler(server.Handler, wsHandler.WebsocketHandler(n.config.WSOrigins))
+	}
+	// Health checks are answered regardless of the virtual host, for probes
+	// addressing the node directly
+	mux := http.NewServeMux()
+	mux.Handle("/health", n.healthHandler(false))
+	mux.Handle("/ready", n.healthHandler(true))
+	mux.Handle("/", server.Handler)
+	server.Handler = mux
+
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		handler.Stop()
+		if wsHandler != nil {
+			wsHandler.Stop()
+		}
+		return err
+	}
+	go server.Serve(listener)
+
 	n.log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", endpoint), "cors", strings.Join(cors, ","), "vhosts", strings.Join(vhosts, ","))
+	if withWS {
+		n.log.Info("WebSocket endpoint opened", "url", fmt.Sprintf("ws://%s", listener.Addr()))
+	}
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
 	n.httpListener = listener
 	n.httpHandler = handler
-
+	if withWS {
+		n.wsEndpoint = endpoint
+		n.wsHandler = wsHandler
+		n.wsShared = true
+	}
 	return nil
 }
 
@@ -361,6 +435,10 @@ func (n *Node) stopHTTP() {
 		n.httpHandler.Stop()
 		n.httpHandler = nil
 	}
+	// A websocket endpoint sharing the listener is gone along with it
+	if n.wsShared {
+		n.stopWS()
+	}
 }
 
 // startWS initializes and starts the websocket RPC endpoint.
@@ -369,7 +447,7 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll)
+	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll, n.rpcConfig)
 	if err != nil {
 		return err
 	}
@@ -394,6 +472,40 @@ func (n *Node) stopWS() {
 		n.wsHandler.Stop()
 		n.wsHandler = nil
 	}
+	n.wsShared = false
+}
+
+// startBinary initializes and starts the binary RPC endpoint.
+func (n *Node) startBinary(endpoint string, apis []rpc.API, modules []string) error {
+	// Short circuit if the binary endpoint isn't being exposed
+	if endpoint == "" {
+		return nil
+	}
+	listener, handler, err := rpc.StartBinaryEndpoint(endpoint, apis, modules, n.rpcConfig)
+	if err != nil {
+		return err
+	}
+	n.log.Info("Binary RPC endpoint opened", "url", fmt.Sprintf("rlp://%s", listener.Addr()))
+	// All listeners booted successfully
+	n.binaryEndpoint = endpoint
+	n.binaryListener = listener
+	n.binaryHandler = handler
+
+	return nil
+}
+
+// stopBinary terminates the binary RPC endpoint.
+func (n *Node) stopBinary() {
+	if n.binaryListener != nil {
+		n.binaryListener.Close()
+		n.binaryListener = nil
+
+		n.log.Info("Binary RPC endpoint closed", "url", fmt.Sprintf("rlp://%s", n.binaryEndpoint))
+	}
+	if n.binaryHandler != nil {
+		n.binaryHandler.Stop()
+		n.binaryHandler = nil
+	}
 }
 
 // Stop terminates a running node along with all it's services. In the node was
@@ -408,6 +520,7 @@ func (n *Node) Stop() error {
 	}
 
 	// Terminate the API, services and the p2p server.
+	n.stopBinary()
 	n.stopWS()
 	n.stopHTTP()
 	n.stopIPC()
@@ -557,6 +670,11 @@ func (n *Node) WSEndpoint() string {
 	return n.wsEndpoint
 }
 
+// BinaryEndpoint retrieves the current binary RPC endpoint used by the protocol stack.
+func (n *Node) BinaryEndpoint() string {
+	return n.binaryEndpoint
+}
+
 // EventMux retrieves the event multiplexer used by all the network services in
 // the current protocol stack.
 func (n *Node) EventMux() *event.TypeMux {
@@ -0,0 +1,319 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// authenticateMethod is the method authenticating a connection in-band, for
+	// transports unable to carry credentials along with the requests (IPC).
+	authenticateMethod = MetadataApi + serviceMethodSeparator + "authenticate"
+
+	// jwtIssuedAtDrift is the maximum difference allowed between the issuance
+	// time of a token and the local clock.
+	jwtIssuedAtDrift = 60 * time.Second
+
+	// jwtMinSecretLength is the minimum length of an HS256 secret.
+	jwtMinSecretLength = 32
+)
+
+var (
+	errMissingCredentials = errors.New("missing credentials")
+	errInvalidCredentials = errors.New("invalid credentials")
+	errExpiredCredentials = errors.New("credentials expired")
+)
+
+// ACL lists the namespaces and methods a credential is allowed to call. Entries
+// are either a namespace like "eth", a method like "admin_peers" or "*" to allow
+// everything.
+type ACL []string
+
+// Allows returns whether the given fully qualified method may be called.
+func (acl ACL) Allows(method string) bool {
+	return matchMethod(acl, method)
+}
+
+// matchMethod returns whether any of the entries, being namespaces, methods or
+// the "*" wildcard, matches the given fully qualified method.
+func matchMethod(entries []string, method string) bool {
+	namespace := methodNamespace(method)
+	for _, entry := range entries {
+		if entry == "*" || entry == method || entry == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// methodNamespace returns the namespace of a fully qualified method.
+func methodNamespace(method string) string {
+	if i := strings.Index(method, serviceMethodSeparator); i >= 0 {
+		return method[:i]
+	}
+	return method
+}
+
+// AuthConfig is the authentication required by a server. Requests carry their
+// credential as a bearer token, which is either one of the static API keys or
+// a JWT signed by the shared HS256 secret.
+type AuthConfig struct {
+	JWTSecret []byte         // HS256 secret of the accepted tokens, nil disables JWT
+	JWTAccess ACL            // Methods callable by the bearers of a valid token
+	APIKeys   map[string]ACL // Static API keys and the methods callable by each
+}
+
+// ReadJWTSecret loads a hex encoded HS256 secret from the given file.
+func ReadJWTSecret(path string) ([]byte, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(blob)), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT secret: %v", err)
+	}
+	if len(secret) < jwtMinSecretLength {
+		return nil, fmt.Errorf("JWT secret too short: have %d bytes, want at least %d", len(secret), jwtMinSecretLength)
+	}
+	return secret, nil
+}
+
+// EnableAuth requires all requests served from now on to be authenticated with
+// one of the credentials of the given configuration. It must be called before
+// the server starts serving.
+func (s *Server) EnableAuth(config AuthConfig) error {
+	if config.JWTSecret == nil && len(config.APIKeys) == 0 {
+		return errors.New("no credentials configured")
+	}
+	if config.JWTSecret != nil && len(config.JWTSecret) < jwtMinSecretLength {
+		return fmt.Errorf("JWT secret too short: have %d bytes, want at least %d", len(config.JWTSecret), jwtMinSecretLength)
+	}
+	s.auth = &config
+	return nil
+}
+
+// authState is the authentication of a request or a connection, replaced when
+// a connection authenticates in-band.
+type authState struct {
+	lock   sync.RWMutex
+	key    string // API key authenticating the requests, if any
+	acl    ACL
+	expiry time.Time // Zero if the credential does not expire
+	err    error     // Reason the credential was rejected
+}
+
+// authStateKey is the context key of the authentication state.
+type authStateKey struct{}
+
+// withAuth attaches to the context the authentication of the given bearer
+// token, if the server requires authentication.
+func (s *Server) withAuth(ctx context.Context, token string) context.Context {
+	if s.auth == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, authStateKey{}, s.authenticate(token))
+}
+
+// authenticate checks a bearer token against the configured credentials.
+func (s *Server) authenticate(token string) *authState {
+	if token == "" {
+		return &authState{err: errMissingCredentials}
+	}
+	// Compare every API key in constant time to not leak the valid ones
+	var (
+		apiKey string
+		acl    ACL
+	)
+	for key, keyACL := range s.auth.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1 {
+			apiKey, acl = key, keyACL
+		}
+	}
+	if acl != nil {
+		return &authState{key: apiKey, acl: acl}
+	}
+	if s.auth.JWTSecret == nil {
+		return &authState{err: errInvalidCredentials}
+	}
+	expiry, err := verifyJWT(token, s.auth.JWTSecret)
+	if err != nil {
+		return &authState{err: err}
+	}
+	return &authState{acl: s.auth.JWTAccess, expiry: expiry}
+}
+
+// authorize checks whether the authentication attached to the context allows
+// calling the given method.
+func (s *Server) authorize(ctx context.Context, method string) Error {
+	if s.auth == nil || method == authenticateMethod {
+		return nil
+	}
+	state, ok := ctx.Value(authStateKey{}).(*authState)
+	if !ok {
+		return &unauthorizedError{errMissingCredentials.Error()}
+	}
+	state.lock.RLock()
+	defer state.lock.RUnlock()
+
+	if state.err != nil {
+		return &unauthorizedError{state.err.Error()}
+	}
+	if !state.expiry.IsZero() && time.Now().After(state.expiry) {
+		return &unauthorizedError{errExpiredCredentials.Error()}
+	}
+	if !state.acl.Allows(method) {
+		return &forbiddenError{method}
+	}
+	return nil
+}
+
+// Authenticate replaces the credentials of the connection by the given bearer
+// token, for transports unable to carry them along with the requests.
+func (s *RPCService) Authenticate(ctx context.Context, token string) (bool, error) {
+	if s.server.auth == nil {
+		return true, nil
+	}
+	state, ok := ctx.Value(authStateKey{}).(*authState)
+	if !ok {
+		return false, errors.New("authentication not supported on this transport")
+	}
+	auth := s.server.authenticate(token)
+
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	state.key, state.acl, state.expiry, state.err = auth.key, auth.acl, auth.expiry, auth.err
+	if auth.err != nil {
+		return false, &unauthorizedError{auth.err.Error()}
+	}
+	return true, nil
+}
+
+// verifyJWT checks the signature and issuance time of an HS256 token, returning
+// its expiry time if it has one.
+func verifyJWT(token string, secret []byte) (time.Time, error) {
+	var (
+		claims jwt.StandardClaims
+		parser = jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Alg()}, SkipClaimsValidation: true}
+	)
+	_, err := parser.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return secret, nil
+	})
+	if err != nil {
+		return time.Time{}, errInvalidCredentials
+	}
+	if claims.IssuedAt == 0 {
+		return time.Time{}, errors.New("missing token issuance time")
+	}
+	if drift := time.Since(time.Unix(claims.IssuedAt, 0)); drift > jwtIssuedAtDrift || drift < -jwtIssuedAtDrift {
+		return time.Time{}, errors.New("stale token issuance time")
+	}
+	if claims.ExpiresAt == 0 {
+		return time.Time{}, nil
+	}
+	expiry := time.Unix(claims.ExpiresAt, 0)
+	if time.Now().After(expiry) {
+		return time.Time{}, errExpiredCredentials
+	}
+	return expiry, nil
+}
+
+// bearerToken extracts the bearer token from the headers of an HTTP request.
+func bearerToken(header http.Header) string {
+	auth := header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// Credentials produces the bearer token attached by a client to its requests.
+type Credentials func() (string, error)
+
+// APIKeyCredentials authenticates with a static API key.
+func APIKeyCredentials(key string) Credentials {
+	return func() (string, error) { return key, nil }
+}
+
+// JWTCredentials authenticates with HS256 tokens signed by the given secret. A
+// fresh token is issued for every HTTP request and every new connection.
+func JWTCredentials(secret []byte) Credentials {
+	return func() (string, error) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{IssuedAt: time.Now().Unix()})
+		return token.SignedString(secret)
+	}
+}
+
+// authenticateConn authenticates a freshly dialed stream connection in-band,
+// before it is handed over to the client.
+func authenticateConn(ctx context.Context, conn net.Conn, credentials Credentials) error {
+	token, err := credentials()
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultDialTimeout)
+	}
+	conn.SetDeadline(deadline)
+	defer conn.SetDeadline(time.Time{})
+
+	req := &jsonrpcMessage{Version: "2.0", ID: json.RawMessage("0"), Method: authenticateMethod}
+	if req.Params, err = json.Marshal([]string{token}); err != nil {
+		return err
+	}
+	resp := new(jsonrpcMessage)
+	if _, binary := conn.(*binaryConn); binary {
+		if err := writeBinaryFrame(conn, req); err != nil {
+			return err
+		}
+		msgs, _, err := readBinaryFrame(conn, maxBinaryFrameSize)
+		if err != nil {
+			return err
+		}
+		if len(msgs) != 1 {
+			return fmt.Errorf("invalid authentication response holding %d messages", len(msgs))
+		}
+		resp = msgs[0]
+	} else {
+		if err := json.NewEncoder(conn).Encode(req); err != nil {
+			return err
+		}
+		if err := json.NewDecoder(conn).Decode(resp); err != nil {
+			return err
+		}
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return nil
+}
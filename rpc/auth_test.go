@@ -0,0 +1,228 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestACL(t *testing.T) {
+	tests := []struct {
+		acl    ACL
+		method string
+		want   bool
+	}{
+		{nil, "eth_call", false},
+		{ACL{"*"}, "admin_peers", true},
+		{ACL{"eth"}, "eth_call", true},
+		{ACL{"eth"}, "ethx_call", false},
+		{ACL{"eth"}, "admin_peers", false},
+		{ACL{"eth", "admin_peers"}, "admin_peers", true},
+		{ACL{"admin_peers"}, "admin_addPeer", false},
+		{ACL{"eth_subscribe"}, "eth_subscribe", true},
+	}
+	for i, tt := range tests {
+		if have := tt.acl.Allows(tt.method); have != tt.want {
+			t.Errorf("test %d: %v allows %s mismatch: have %v, want %v", i, tt.acl, tt.method, have, tt.want)
+		}
+	}
+}
+
+func TestVerifyJWT(t *testing.T) {
+	secret := make([]byte, 32)
+	sign := func(secret []byte, method jwt.SigningMethod, claims jwt.StandardClaims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(secret)
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return token
+	}
+	now := time.Now()
+	tests := []struct {
+		token  string
+		expiry int64
+		fail   bool
+	}{
+		{token: sign(secret, jwt.SigningMethodHS256, jwt.StandardClaims{IssuedAt: now.Unix()})},
+		{token: sign(secret, jwt.SigningMethodHS256, jwt.StandardClaims{IssuedAt: now.Unix() - 30})},
+		{token: sign(secret, jwt.SigningMethodHS256, jwt.StandardClaims{IssuedAt: now.Unix(), ExpiresAt: now.Unix() + 3600}), expiry: now.Unix() + 3600},
+		{token: sign(secret, jwt.SigningMethodHS256, jwt.StandardClaims{IssuedAt: now.Unix(), ExpiresAt: now.Unix() - 1}), fail: true},
+		{token: sign(secret, jwt.SigningMethodHS256, jwt.StandardClaims{}), fail: true},
+		{token: sign(secret, jwt.SigningMethodHS256, jwt.StandardClaims{IssuedAt: now.Unix() - 120}), fail: true},
+		{token: sign(secret, jwt.SigningMethodHS256, jwt.StandardClaims{IssuedAt: now.Unix() + 120}), fail: true},
+		{token: sign(secret, jwt.SigningMethodHS512, jwt.StandardClaims{IssuedAt: now.Unix()}), fail: true},
+		{token: sign([]byte("0123456789abcdef0123456789abcdef"), jwt.SigningMethodHS256, jwt.StandardClaims{IssuedAt: now.Unix()}), fail: true},
+		{token: "not a token", fail: true},
+	}
+	for i, tt := range tests {
+		expiry, err := verifyJWT(tt.token, secret)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: invalid token accepted", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: valid token rejected: %v", i, err)
+			continue
+		}
+		if (tt.expiry == 0) != expiry.IsZero() || (tt.expiry != 0 && expiry.Unix() != tt.expiry) {
+			t.Errorf("test %d: expiry mismatch: have %v, want %d", i, expiry, tt.expiry)
+		}
+	}
+}
+
+func TestAuthHTTP(t *testing.T)      { testAuth("http", t) }
+func TestAuthWebsocket(t *testing.T) { testAuth("ws", t) }
+func TestAuthIPC(t *testing.T)       { testAuth("ipc", t) }
+
+// Tests that requests are only served if their credentials allow calling the
+// method, over all transports.
+func testAuth(transport string, t *testing.T) {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+
+	server := newTestServer("service", new(Service))
+	if err := server.RegisterName("other", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	err := server.EnableAuth(AuthConfig{
+		JWTSecret: secret,
+		JWTAccess: ACL{"service"},
+		APIKeys: map[string]ACL{
+			"full": {"*"},
+			"echo": {"service_echo"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to enable authentication: %v", err)
+	}
+	defer server.Stop()
+
+	// Start the server on the requested transport
+	var url string
+	switch transport {
+	case "http":
+		hs := httptest.NewServer(server)
+		defer hs.Close()
+		url = hs.URL
+	case "ws":
+		hs := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+		defer hs.Close()
+		url = "ws://" + hs.Listener.Addr().String()
+	case "ipc":
+		url = fmt.Sprintf("go-simplechain-test-ipc-%d-%d", os.Getpid(), rand.Int63())
+		if runtime.GOOS == "windows" {
+			url = `\\.\pipe\` + url
+		} else {
+			url = os.TempDir() + "/" + url
+		}
+		l, err := ipcListen(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		go server.ServeListener(l)
+	}
+	// Call the methods with a variety of credentials
+	const (
+		ok           = 0
+		unauthorized = -32001
+		forbidden    = -32003
+	)
+	tests := []struct {
+		credentials Credentials
+		echo        int // Result of calling service_echo
+		rets        int // Result of calling service_rets
+		other       int // Result of calling other_echo
+	}{
+		{nil, unauthorized, unauthorized, unauthorized},
+		{APIKeyCredentials("unknown"), unauthorized, unauthorized, unauthorized},
+		{APIKeyCredentials("full"), ok, ok, ok},
+		{APIKeyCredentials("echo"), ok, forbidden, forbidden},
+		{JWTCredentials(secret), ok, ok, forbidden},
+		{JWTCredentials(make([]byte, 32)), unauthorized, unauthorized, unauthorized},
+	}
+	for i, tt := range tests {
+		var options []ClientOption
+		if tt.credentials != nil {
+			options = append(options, WithCredentials(tt.credentials))
+		}
+		client, err := DialOptions(context.Background(), url, options...)
+		if err != nil {
+			// Stream transports authenticate in-band while connecting
+			if transport == "ipc" && tt.echo == unauthorized {
+				continue
+			}
+			t.Fatalf("test %d: failed to dial: %v", i, err)
+		}
+		check := func(method string, want int, args ...interface{}) {
+			err := client.Call(nil, method, args...)
+			switch {
+			case want == ok && err != nil:
+				t.Errorf("test %d: %s failed: %v", i, method, err)
+			case want != ok && err == nil:
+				t.Errorf("test %d: %s succeeded, want error code %d", i, method, want)
+			case want != ok:
+				if rpcErr, isRPC := err.(Error); !isRPC || rpcErr.ErrorCode() != want {
+					t.Errorf("test %d: %s error mismatch: have %v, want code %d", i, method, err, want)
+				}
+			}
+		}
+		check("service_echo", tt.echo, "hello", 10, &Args{"world"})
+		check("service_rets", tt.rets)
+		check("other_echo", tt.other, "hello", 10, &Args{"world"})
+		client.Close()
+	}
+}
+
+// Tests that a connection may authenticate in-band, replacing its credentials.
+func TestAuthenticateInBand(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	if err := server.EnableAuth(AuthConfig{APIKeys: map[string]ACL{"key": {"service"}}}); err != nil {
+		t.Fatalf("failed to enable authentication: %v", err)
+	}
+	client, l := ipcTestClient(server, nil)
+	defer l.Close()
+	defer client.Close()
+
+	if err := client.Call(nil, "service_rets"); err == nil {
+		t.Fatal("unauthenticated call succeeded")
+	}
+	var authenticated bool
+	if err := client.Call(&authenticated, "rpc_authenticate", "key"); err != nil || !authenticated {
+		t.Fatalf("failed to authenticate: %v", err)
+	}
+	if err := client.Call(nil, "service_rets"); err != nil {
+		t.Fatalf("authenticated call failed: %v", err)
+	}
+	if err := client.Call(nil, "rpc_authenticate", "invalid"); err == nil {
+		t.Fatal("invalid credentials accepted")
+	}
+	if err := client.Call(nil, "service_rets"); err == nil {
+		t.Fatal("call succeeded after failed authentication")
+	}
+}
@@ -0,0 +1,420 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/simplechain-org/go-simplechain/rlp"
+)
+
+// The binary transport carries the messages of the JSON-RPC protocol in RLP
+// encoded frames prefixed by their length, sparing high-throughput clients the
+// JSON envelope and the overhead of HTTP. The ids, parameters, results and
+// errors of the messages remain JSON encoded, so that all services are served
+// over it unmodified, subscriptions included.
+
+const (
+	// binaryFrameHeaderSize is the size of the big endian length prefixing a frame.
+	binaryFrameHeaderSize = 4
+
+	// maxBinaryFrameSize is the maximum size of the frames read by clients. The
+	// frames read by servers are limited to maxRequestContentLength, which keeps
+	// their first byte zero and tells them apart from JSON messages.
+	maxBinaryFrameSize = 256 * 1024 * 1024
+)
+
+// binaryMessage is a request, notification or response of the binary framing.
+type binaryMessage struct {
+	ID     []byte // JSON encoded id, empty for notifications
+	Method string // Method called or notified, empty for responses
+	Params []byte // JSON encoded parameters
+	Result []byte // JSON encoded result
+	Error  []byte // JSON encoded error object, empty unless the request failed
+}
+
+// binaryFrame is a single message or a batch of them. Frames are encoded and
+// decoded by hand following this layout, sparing the reflection of the rlp
+// package on every call.
+type binaryFrame struct {
+	Batch    bool
+	Messages []*binaryMessage
+}
+
+// binaryConn is a client connection speaking the binary framing.
+type binaryConn struct {
+	net.Conn
+}
+
+// writeBinaryFrame encodes a message or a batch of messages, either built by a
+// client or by a server codec, and writes it as a single frame.
+func writeBinaryFrame(w io.Writer, msg interface{}) error {
+	var (
+		msgs  []binaryMessage
+		batch = true
+		err   error
+	)
+	switch msg := msg.(type) {
+	case []*jsonrpcMessage:
+		msgs = make([]binaryMessage, len(msg))
+		for i, m := range msg {
+			if err = toBinaryMessage(m, &msgs[i]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		msgs = make([]binaryMessage, len(msg))
+		for i, m := range msg {
+			if err = toBinaryMessage(m, &msgs[i]); err != nil {
+				return err
+			}
+		}
+	case []json.RawMessage:
+		msgs = make([]binaryMessage, len(msg))
+		for i, m := range msg {
+			if err = toBinaryMessage(m, &msgs[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		msgs, batch = make([]binaryMessage, 1), false
+		if err = toBinaryMessage(msg, &msgs[0]); err != nil {
+			return err
+		}
+	}
+	// Size the frame up front to encode it in a single buffer
+	var listSize uint64
+	for i := range msgs {
+		listSize += rlp.ListSize(msgs[i].contentSize())
+	}
+	contentSize := 1 + rlp.ListSize(listSize)
+	frameSize := rlp.ListSize(contentSize)
+	if frameSize > maxBinaryFrameSize {
+		return fmt.Errorf("frame too large: %d bytes, limit %d", frameSize, maxBinaryFrameSize)
+	}
+	buf := make([]byte, binaryFrameHeaderSize, binaryFrameHeaderSize+frameSize)
+	binary.BigEndian.PutUint32(buf, uint32(frameSize))
+
+	buf = appendRLPHeader(buf, 0xC0, contentSize)
+	if batch {
+		buf = append(buf, 0x01)
+	} else {
+		buf = append(buf, 0x80)
+	}
+	buf = appendRLPHeader(buf, 0xC0, listSize)
+	for i := range msgs {
+		m := &msgs[i]
+		buf = appendRLPHeader(buf, 0xC0, m.contentSize())
+		buf = appendRLPString(buf, m.ID)
+		buf = appendRLPString(buf, []byte(m.Method))
+		buf = appendRLPString(buf, m.Params)
+		buf = appendRLPString(buf, m.Result)
+		buf = appendRLPString(buf, m.Error)
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// toBinaryMessage converts a message to its binary form. Messages of unknown
+// types are converted through their JSON encoding.
+func toBinaryMessage(msg interface{}, bm *binaryMessage) error {
+	var err error
+	switch msg := msg.(type) {
+	case *jsonrpcMessage:
+		bm.ID, bm.Method, bm.Params, bm.Result = msg.ID, msg.Method, msg.Params, msg.Result
+		if msg.Error != nil {
+			bm.Error, err = json.Marshal(msg.Error)
+		}
+	case *jsonSuccessResponse:
+		if bm.ID, err = marshalBinaryID(msg.Id); err == nil {
+			bm.Result, err = json.Marshal(msg.Result)
+		}
+	case *jsonErrResponse:
+		if bm.ID, err = marshalBinaryID(msg.Id); err == nil {
+			bm.Error, err = json.Marshal(msg.Error)
+		}
+	case *jsonNotification:
+		bm.Method = msg.Method
+		bm.Params, err = json.Marshal(msg.Params)
+	default:
+		blob, ok := msg.(json.RawMessage)
+		if !ok {
+			if blob, err = json.Marshal(msg); err != nil {
+				return err
+			}
+		}
+		var m jsonrpcMessage
+		if err := json.Unmarshal(blob, &m); err != nil {
+			return err
+		}
+		return toBinaryMessage(&m, bm)
+	}
+	return err
+}
+
+// marshalBinaryID encodes the id of a response, reusing the raw id of the
+// request it answers.
+func marshalBinaryID(id interface{}) ([]byte, error) {
+	switch id := id.(type) {
+	case json.RawMessage:
+		return id, nil
+	case *json.RawMessage:
+		if id != nil && len(*id) > 0 {
+			return *id, nil
+		}
+	}
+	return json.Marshal(id)
+}
+
+// contentSize returns the size of the encoded fields of the message.
+func (bm *binaryMessage) contentSize() uint64 {
+	return rlpStringSize(bm.ID) + rlpStringSize([]byte(bm.Method)) + rlpStringSize(bm.Params) +
+		rlpStringSize(bm.Result) + rlpStringSize(bm.Error)
+}
+
+// rlpStringSize returns the encoded size of an RLP string.
+func rlpStringSize(b []byte) uint64 {
+	if len(b) == 1 && b[0] < 0x80 {
+		return 1
+	}
+	return rlp.ListSize(uint64(len(b))) // same header as lists
+}
+
+// appendRLPString appends an RLP string to buf.
+func appendRLPString(buf []byte, b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return append(buf, b[0])
+	}
+	return append(appendRLPHeader(buf, 0x80, uint64(len(b))), b...)
+}
+
+// appendRLPHeader appends the header of an RLP string (0x80) or list (0xC0) of
+// the given content size to buf.
+func appendRLPHeader(buf []byte, offset byte, size uint64) []byte {
+	if size < 56 {
+		return append(buf, offset+byte(size))
+	}
+	n := 0
+	for s := size; s > 0; s >>= 8 {
+		n++
+	}
+	buf = append(buf, offset+55+byte(n))
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(size>>(8*uint(i))))
+	}
+	return buf
+}
+
+// decodeBinaryFrame reads a frame of at most the given size and splits it into
+// its messages, which reference the frame read.
+func decodeBinaryFrame(r io.Reader, limit int) ([]binaryMessage, bool, error) {
+	var header [binaryFrameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, false, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if uint64(size) > uint64(limit) {
+		return nil, false, fmt.Errorf("frame too large: %d bytes, limit %d", size, limit)
+	}
+	blob := make([]byte, size)
+	if _, err := io.ReadFull(r, blob); err != nil {
+		return nil, false, err
+	}
+	content, rest, err := rlp.SplitList(blob)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(rest) > 0 {
+		return nil, false, rlp.ErrMoreThanOneValue
+	}
+	flag, content, err := rlp.SplitString(content)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(flag) > 1 || (len(flag) == 1 && flag[0] != 0x01) {
+		return nil, false, fmt.Errorf("invalid batch flag %x", flag)
+	}
+	list, content, err := rlp.SplitList(content)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(content) > 0 {
+		return nil, false, rlp.ErrMoreThanOneValue
+	}
+	count, err := rlp.CountValues(list)
+	if err != nil {
+		return nil, false, err
+	}
+	msgs := make([]binaryMessage, count)
+	for i := range msgs {
+		var fields []byte
+		if fields, list, err = rlp.SplitList(list); err != nil {
+			return nil, false, err
+		}
+		var method []byte
+		for _, field := range []*[]byte{&msgs[i].ID, &method, &msgs[i].Params, &msgs[i].Result, &msgs[i].Error} {
+			if *field, fields, err = rlp.SplitString(fields); err != nil {
+				return nil, false, err
+			}
+		}
+		if len(fields) > 0 {
+			return nil, false, fmt.Errorf("invalid message holding extra fields")
+		}
+		msgs[i].Method = string(method)
+	}
+	return msgs, len(flag) == 1, nil
+}
+
+// readBinaryFrame reads a frame of at most the given size and converts its
+// messages to their JSON-RPC form.
+func readBinaryFrame(r io.Reader, limit int) ([]*jsonrpcMessage, bool, error) {
+	frame, batch, err := decodeBinaryFrame(r, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	msgs := make([]*jsonrpcMessage, len(frame))
+	for i, bm := range frame {
+		msgs[i] = &jsonrpcMessage{Version: jsonrpcVersion, Method: bm.Method}
+		if len(bm.ID) > 0 {
+			msgs[i].ID = bm.ID
+		}
+		if len(bm.Params) > 0 {
+			msgs[i].Params = bm.Params
+		}
+		if len(bm.Result) > 0 {
+			msgs[i].Result = bm.Result
+		}
+		if len(bm.Error) > 0 {
+			msgs[i].Error = new(jsonError)
+			if err := json.Unmarshal(bm.Error, msgs[i].Error); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	return msgs, batch, nil
+}
+
+// readBinaryRequests reads a frame of requests, decoding their fields straight
+// into the requests served without going through a JSON envelope.
+func readBinaryRequests(r io.Reader) ([]jsonRequest, bool, error) {
+	frame, batch, err := decodeBinaryFrame(r, maxRequestContentLength)
+	if err != nil {
+		return nil, false, err
+	}
+	if !batch && len(frame) != 1 {
+		return nil, false, fmt.Errorf("invalid frame holding %d messages", len(frame))
+	}
+	reqs := make([]jsonRequest, len(frame))
+	for i, bm := range frame {
+		reqs[i] = jsonRequest{Method: bm.Method, Version: jsonrpcVersion}
+		if len(bm.ID) > 0 {
+			reqs[i].Id = bm.ID
+		}
+		if len(bm.Params) > 0 {
+			reqs[i].Payload = bm.Params
+		}
+	}
+	return reqs, batch, nil
+}
+
+// NewBinaryCodec creates a new RPC server codec speaking the binary framing
+// over the given connection.
+func NewBinaryCodec(rwc io.ReadWriteCloser) ServerCodec {
+	encode := func(v interface{}) error {
+		return writeBinaryFrame(rwc, v)
+	}
+	codec := NewCodec(rwc, encode, nil).(*jsonCodec)
+	codec.decodeRequests = func() ([]jsonRequest, bool, error) {
+		return readBinaryRequests(rwc)
+	}
+	return codec
+}
+
+// sniffedConn is a connection whose first bytes were peeked at to detect the
+// framing it speaks.
+type sniffedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *sniffedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// newSniffedCodec creates a server codec for a stream connection, speaking the
+// binary framing if the first byte received is zero and JSON otherwise.
+func newSniffedCodec(conn net.Conn) (ServerCodec, error) {
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	sniffed := &sniffedConn{Conn: conn, reader: reader}
+	if first[0] == 0 {
+		return NewBinaryCodec(sniffed), nil
+	}
+	return NewJSONCodec(sniffed), nil
+}
+
+// StartBinaryEndpoint starts a TCP endpoint serving the binary framing, and
+// JSON over the raw connection for clients not speaking it, enforcing the
+// given request policy.
+func StartBinaryEndpoint(endpoint string, apis []API, modules []string, config *EndpointConfig) (net.Listener, *Server, error) {
+	handler, err := NewEndpointServer(apis, modules, false, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	go handler.serveListener(listener, "tcp")
+	return listener, handler, nil
+}
+
+// dialBinary creates a client speaking the binary framing over a TCP or IPC
+// connection to the given endpoint.
+func dialBinary(ctx context.Context, network, endpoint string, credentials Credentials) (*Client, error) {
+	return newClient(ctx, func(ctx context.Context) (net.Conn, error) {
+		var (
+			conn net.Conn
+			err  error
+		)
+		if network == "ipc" {
+			conn, err = newIPCConnection(ctx, endpoint)
+		} else {
+			conn, err = dialContext(ctx, network, endpoint)
+		}
+		if err != nil {
+			return nil, err
+		}
+		bconn := &binaryConn{conn}
+		if credentials != nil {
+			if err := authenticateConn(ctx, bconn, credentials); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+		return bconn, nil
+	})
+}
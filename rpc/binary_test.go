@@ -0,0 +1,314 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+	"github.com/simplechain-org/go-simplechain/rlp"
+)
+
+func TestBinaryFrameRoundtrip(t *testing.T) {
+	msgs := []*jsonrpcMessage{
+		{Version: "2.0", ID: json.RawMessage("1"), Method: "eth_call", Params: json.RawMessage(`[{"to":"0x01"},"latest"]`)},
+		{Version: "2.0", ID: json.RawMessage(`"abc"`), Result: json.RawMessage(`{"a":[1,2]}`)},
+		{Version: "2.0", ID: json.RawMessage("2"), Error: &jsonError{Code: -32005, Message: "limit", Data: map[string]interface{}{"limit": 1.0}}},
+		{Version: "2.0", Method: "eth_subscription", Params: json.RawMessage(`{"subscription":"0x1","result":null}`)},
+		{Version: "2.0", ID: json.RawMessage("3"), Result: json.RawMessage(`"0x` + strings.Repeat("ab", 300) + `"`)},
+	}
+	for i, msg := range msgs {
+		var buf bytes.Buffer
+		if err := writeBinaryFrame(&buf, msg); err != nil {
+			t.Fatalf("test %d: failed to write frame: %v", i, err)
+		}
+		have, batch, err := readBinaryFrame(&buf, maxBinaryFrameSize)
+		if err != nil {
+			t.Fatalf("test %d: failed to read frame: %v", i, err)
+		}
+		if batch || len(have) != 1 || !reflect.DeepEqual(have[0], msg) {
+			t.Errorf("test %d: message mismatch: have %v, want %v", i, have, msg)
+		}
+	}
+	var buf bytes.Buffer
+	if err := writeBinaryFrame(&buf, msgs); err != nil {
+		t.Fatalf("failed to write batch frame: %v", err)
+	}
+	if buf.Bytes()[0] != 0 {
+		t.Errorf("frame starts with non-zero byte %#x", buf.Bytes()[0])
+	}
+	// The hand written encoding must match that of the rlp package
+	frame := &binaryFrame{Batch: true}
+	for _, msg := range msgs {
+		bm := new(binaryMessage)
+		if err := toBinaryMessage(msg, bm); err != nil {
+			t.Fatalf("failed to convert message: %v", err)
+		}
+		frame.Messages = append(frame.Messages, bm)
+	}
+	want, err := rlp.EncodeToBytes(frame)
+	if err != nil {
+		t.Fatalf("failed to encode frame: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes()[binaryFrameHeaderSize:], want) {
+		t.Errorf("frame encoding mismatch:\nhave %x\nwant %x", buf.Bytes()[binaryFrameHeaderSize:], want)
+	}
+	have, batch, err := readBinaryFrame(&buf, maxBinaryFrameSize)
+	if err != nil {
+		t.Fatalf("failed to read batch frame: %v", err)
+	}
+	if !batch || !reflect.DeepEqual(have, msgs) {
+		t.Errorf("batch mismatch: have %v, want %v", have, msgs)
+	}
+	// Frames above the limit must be rejected before being read
+	buf.Reset()
+	writeBinaryFrame(&buf, msgs)
+	if _, _, err := readBinaryFrame(&buf, 16); err == nil {
+		t.Error("oversized frame accepted")
+	}
+}
+
+func TestBinaryTCP(t *testing.T) { testBinary("tcp", t) }
+func TestBinaryIPC(t *testing.T) { testBinary("ipc", t) }
+
+// Tests that calls, batches and subscriptions are served in binary frames, with
+// plain JSON clients still served on the same endpoint.
+func testBinary(transport string, t *testing.T) {
+	apis := []API{
+		{Namespace: "service", Service: new(Service), Public: true},
+		{Namespace: "eth", Service: new(NotificationTestService), Public: true},
+	}
+	var (
+		binaryURL, jsonURL string
+		server             *Server
+	)
+	switch transport {
+	case "tcp":
+		listener, handler, err := StartBinaryEndpoint("127.0.0.1:0", apis, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to start endpoint: %v", err)
+		}
+		defer listener.Close()
+		binaryURL, server = "rlp://"+listener.Addr().String(), handler
+	case "ipc":
+		endpoint := fmt.Sprintf("go-simplechain-test-ipc-%d-%d", os.Getpid(), rand.Int63())
+		if runtime.GOOS == "windows" {
+			endpoint = `\\.\pipe\` + endpoint
+		} else {
+			endpoint = os.TempDir() + "/" + endpoint
+		}
+		listener, handler, err := StartIPCEndpoint(endpoint, apis, nil)
+		if err != nil {
+			t.Fatalf("failed to start endpoint: %v", err)
+		}
+		defer listener.Close()
+		binaryURL, jsonURL, server = "rlpipc://"+endpoint, endpoint, handler
+	}
+	defer server.Stop()
+
+	client, err := Dial(binaryURL)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+	if !client.isBinary {
+		t.Fatal("client not speaking the binary framing")
+	}
+	// Plain calls and errors
+	var result Result
+	if err := client.Call(&result, "service_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if want := (Result{"hello", 10, &Args{"world"}}); !reflect.DeepEqual(result, want) {
+		t.Errorf("result mismatch: have %v, want %v", result, want)
+	}
+	if err := client.Call(nil, "service_missing"); err == nil {
+		t.Error("call of a missing method succeeded")
+	} else if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != -32601 {
+		t.Errorf("error mismatch: have %v, want code -32601", err)
+	}
+	// Batches
+	batch := []BatchElem{
+		{Method: "service_echo", Args: []interface{}{"a", 1, &Args{"b"}}, Result: new(Result)},
+		{Method: "service_missing", Result: new(Result)},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatalf("batch failed: %v", err)
+	}
+	if batch[0].Error != nil || batch[0].Result.(*Result).String != "a" {
+		t.Errorf("batch element 0 mismatch: %v, %v", batch[0].Result, batch[0].Error)
+	}
+	if batch[1].Error == nil {
+		t.Error("batch element 1 succeeded")
+	}
+	// Subscriptions
+	nc := make(chan int)
+	sub, err := client.EthSubscribe(context.Background(), nc, "bufferedSubscription", 5, 0)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if val := <-nc; val != i {
+			t.Fatalf("notification mismatch: have %d, want %d", val, i)
+		}
+	}
+	sub.Unsubscribe()
+
+	// JSON clients are still served by the same endpoint
+	if jsonURL != "" {
+		jsonClient, err := Dial(jsonURL)
+		if err != nil {
+			t.Fatalf("failed to dial JSON client: %v", err)
+		}
+		defer jsonClient.Close()
+
+		if err := jsonClient.Call(&result, "service_echo", "hello", 10, &Args{"world"}); err != nil {
+			t.Errorf("JSON call failed: %v", err)
+		}
+	}
+}
+
+// Tests that binary connections authenticate in-band.
+func TestBinaryAuth(t *testing.T) {
+	apis := []API{{Namespace: "service", Service: new(Service), Public: true}}
+	config := &EndpointConfig{Auth: &AuthConfig{APIKeys: map[string]ACL{"key": {"service"}}}}
+
+	listener, server, err := StartBinaryEndpoint("127.0.0.1:0", apis, nil, config)
+	if err != nil {
+		t.Fatalf("failed to start endpoint: %v", err)
+	}
+	defer listener.Close()
+	defer server.Stop()
+
+	url := "rlp://" + listener.Addr().String()
+	if _, err := DialOptions(context.Background(), url, WithCredentials(APIKeyCredentials("invalid"))); err == nil {
+		t.Error("invalid credentials accepted")
+	}
+	client, err := DialOptions(context.Background(), url, WithCredentials(APIKeyCredentials("key")))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Call(nil, "service_rets"); err != nil {
+		t.Errorf("authenticated call failed: %v", err)
+	}
+}
+
+// BenchmarkEthService mimics the shape of the transaction submission and call
+// methods of the eth namespace, doing no work to only measure the transports.
+type BenchmarkEthService struct{}
+
+type BenchmarkCallArgs struct {
+	From *common.Address `json:"from"`
+	To   *common.Address `json:"to"`
+	Gas  *hexutil.Uint64 `json:"gas"`
+	Data *hexutil.Bytes  `json:"data"`
+}
+
+func (s *BenchmarkEthService) SendRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (common.Hash, error) {
+	return common.BytesToHash(encodedTx), nil
+}
+
+func (s *BenchmarkEthService) Call(ctx context.Context, args BenchmarkCallArgs, blockNr BlockNumber) (hexutil.Bytes, error) {
+	return common.LeftPadBytes(*args.Data, 32), nil
+}
+
+// BenchmarkBinary compares the binary framing over TCP and IPC against JSON over
+// IPC and HTTP for transaction submissions and calls.
+func BenchmarkBinary(b *testing.B) {
+	apis := []API{{Namespace: "eth", Service: new(BenchmarkEthService), Public: true}}
+
+	endpoint := fmt.Sprintf("go-simplechain-bench-ipc-%d-%d", os.Getpid(), rand.Int63())
+	if runtime.GOOS == "windows" {
+		endpoint = `\\.\pipe\` + endpoint
+	} else {
+		endpoint = os.TempDir() + "/" + endpoint
+	}
+	ipcListener, ipcServer, err := StartIPCEndpoint(endpoint, apis, nil)
+	if err != nil {
+		b.Fatalf("failed to start IPC endpoint: %v", err)
+	}
+	defer ipcListener.Close()
+	defer ipcServer.Stop()
+
+	tcpListener, tcpServer, err := StartBinaryEndpoint("127.0.0.1:0", apis, nil, nil)
+	if err != nil {
+		b.Fatalf("failed to start binary endpoint: %v", err)
+	}
+	defer tcpListener.Close()
+	defer tcpServer.Stop()
+
+	hs := httptest.NewServer(ipcServer)
+	defer hs.Close()
+
+	var (
+		tx   = make(hexutil.Bytes, 110)
+		data = make(hexutil.Bytes, 68)
+		to   = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		args = BenchmarkCallArgs{From: &common.Address{}, To: &to, Gas: new(hexutil.Uint64), Data: &data}
+	)
+	rand.Read(tx)
+	rand.Read(data)
+
+	dialers := []struct {
+		name string
+		dial func() (*Client, error)
+	}{
+		{"rlp", func() (*Client, error) { return Dial("rlp://" + tcpListener.Addr().String()) }},
+		{"rlpipc", func() (*Client, error) { return Dial("rlpipc://" + endpoint) }},
+		{"ipc", func() (*Client, error) { return DialIPC(context.Background(), endpoint) }},
+		{"http", func() (*Client, error) { return DialHTTP(hs.URL) }},
+	}
+	for _, dialer := range dialers {
+		client, err := dialer.dial()
+		if err != nil {
+			b.Fatalf("failed to dial %s: %v", dialer.name, err)
+		}
+		defer client.Close()
+
+		b.Run(dialer.name+"/sendRawTransaction", func(b *testing.B) {
+			var hash common.Hash
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := client.Call(&hash, "eth_sendRawTransaction", tx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(dialer.name+"/call", func(b *testing.B) {
+			var result hexutil.Bytes
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := client.Call(&result, "eth_call", args, "latest"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"reflect"
@@ -110,6 +111,7 @@ type Client struct {
 	idCounter   uint32
 	connectFunc func(ctx context.Context) (net.Conn, error)
 	isHTTP      bool
+	isBinary    bool // Whether messages are exchanged in binary frames
 
 	// writeConn is only safe to access outside dispatch, with the
 	// write lock held. The write lock is taken by sending on
@@ -119,6 +121,7 @@ type Client struct {
 	// for dispatch
 	close       chan struct{}
 	didQuit     chan struct{}                  // closed when client quits
+	connLost    chan struct{}                  // closed when the initial connection is lost
 	reconnected chan net.Conn                  // where write/reconnect sends the new connection
 	readErr     chan error                     // errors from read
 	readResp    chan []*jsonrpcMessage         // valid messages from read
@@ -151,6 +154,9 @@ func (op *requestOp) wait(ctx context.Context) (*jsonrpcMessage, error) {
 // domain sockets on supported platforms and named pipes on Windows. If you want to
 // configure transport options, use DialHTTP, DialWebsocket or DialIPC instead.
 //
+// Messages are exchanged in binary frames instead of JSON over TCP with the "rlp"
+// scheme (rlp://host:port) and over IPC with the "rlpipc" one (rlpipc://path).
+//
 // For websocket connections, the origin is set to the local host name.
 //
 // The client reconnects automatically if the connection is lost.
@@ -163,19 +169,52 @@ func Dial(rawurl string) (*Client, error) {
 // The context is used to cancel or time out the initial connection establishment. It does
 // not affect subsequent interactions with the client.
 func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+	return DialOptions(ctx, rawurl)
+}
+
+// ClientOption is a configuration option of a client created by DialOptions.
+type ClientOption func(*clientConfig)
+
+type clientConfig struct {
+	credentials Credentials
+}
+
+// WithCredentials authenticates all requests of the client with the bearer
+// tokens produced by the given credentials. They are sent in the Authorization
+// header over HTTP and WebSocket, and in-band when connecting over IPC.
+func WithCredentials(credentials Credentials) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.credentials = credentials
+	}
+}
+
+// DialOptions creates a new RPC client for the given URL, just like DialContext,
+// configured by the given options.
+func DialOptions(ctx context.Context, rawurl string, options ...ClientOption) (*Client, error) {
+	cfg := new(clientConfig)
+	for _, option := range options {
+		option(cfg)
+	}
 	u, err := url.Parse(rawurl)
 	if err != nil {
 		return nil, err
 	}
 	switch u.Scheme {
 	case "http", "https":
-		return DialHTTP(rawurl)
+		return dialHTTP(rawurl, new(http.Client), cfg.credentials)
 	case "ws", "wss":
-		return DialWebsocket(ctx, rawurl, "")
+		return dialWebsocket(ctx, rawurl, "", cfg.credentials)
+	case "rlp":
+		return dialBinary(ctx, "tcp", u.Host, cfg.credentials)
+	case "rlpipc":
+		return dialBinary(ctx, "ipc", strings.TrimPrefix(rawurl, u.Scheme+"://"), cfg.credentials)
 	case "stdio":
+		if cfg.credentials != nil {
+			return nil, errors.New("credentials not supported over stdio")
+		}
 		return DialStdIO(ctx)
 	case "":
-		return DialIPC(ctx, rawurl)
+		return dialIPC(ctx, rawurl, cfg.credentials)
 	default:
 		return nil, fmt.Errorf("no known transport for URL scheme %q", u.Scheme)
 	}
@@ -226,12 +265,15 @@ func newClient(initctx context.Context, connectFunc func(context.Context) (net.C
 		return nil, err
 	}
 	_, isHTTP := conn.(*httpConn)
+	_, isBinary := conn.(*binaryConn)
 	c := &Client{
 		writeConn:   conn,
 		isHTTP:      isHTTP,
+		isBinary:    isBinary,
 		connectFunc: connectFunc,
 		close:       make(chan struct{}),
 		didQuit:     make(chan struct{}),
+		connLost:    make(chan struct{}),
 		reconnected: make(chan net.Conn),
 		readErr:     make(chan error),
 		readResp:    make(chan []*jsonrpcMessage),
@@ -486,7 +528,12 @@ func (c *Client) write(ctx context.Context, msg interface{}) error {
 		}
 	}
 	c.writeConn.SetWriteDeadline(deadline)
-	err := json.NewEncoder(c.writeConn).Encode(msg)
+	var err error
+	if c.isBinary {
+		err = writeBinaryFrame(c.writeConn, msg)
+	} else {
+		err = json.NewEncoder(c.writeConn).Encode(msg)
+	}
 	if err != nil {
 		c.writeConn = nil
 	}
@@ -520,6 +567,7 @@ func (c *Client) dispatch(conn net.Conn) {
 		lastOp        *requestOp    // tracks last send operation
 		requestOpLock = c.requestOp // nil while the send lock is held
 		reading       = true        // if true, a read loop is running
+		lost          = false       // if true, connLost was closed
 	)
 	defer close(c.didQuit)
 	defer func() {
@@ -566,6 +614,10 @@ func (c *Client) dispatch(conn net.Conn) {
 
 		case err := <-c.readErr:
 			log.Debug("<-readErr", "err", err)
+			if !lost {
+				close(c.connLost)
+				lost = true
+			}
 			c.closeRequestOps(err)
 			conn.Close()
 			reading = false
@@ -678,6 +730,10 @@ func (c *Client) read(conn net.Conn) error {
 		dec = json.NewDecoder(conn)
 	)
 	readMessage := func() (rs []*jsonrpcMessage, err error) {
+		if c.isBinary {
+			rs, _, err = readBinaryFrame(conn, maxBinaryFrameSize)
+			return rs, err
+		}
 		buf = buf[:0]
 		if err = dec.Decode(&buf); err != nil {
 			return nil, err
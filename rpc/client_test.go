@@ -49,6 +49,43 @@ func TestClientRequest(t *testing.T) {
 	}
 }
 
+type DataErrorService struct{}
+
+type testDataError struct{}
+
+func (e *testDataError) Error() string          { return "testError" }
+func (e *testDataError) ErrorCode() int         { return 444 }
+func (e *testDataError) ErrorData() interface{} { return "testData" }
+
+func (s *DataErrorService) Fail() error {
+	return &testDataError{}
+}
+
+func TestClientErrorData(t *testing.T) {
+	server := newTestServer("service", new(DataErrorService))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var resp interface{}
+	err := client.Call(&resp, "service_fail")
+	if err == nil {
+		t.Fatal("no error")
+	}
+	// Check code.
+	if e, ok := err.(Error); !ok {
+		t.Fatalf("client did not return rpc.Error, got %#v", e)
+	} else if e.ErrorCode() != (&testDataError{}).ErrorCode() {
+		t.Fatalf("wrong error code %d", e.ErrorCode())
+	}
+	// Check data.
+	if e, ok := err.(DataError); !ok {
+		t.Fatalf("client did not return rpc.DataError, got %#v", e)
+	} else if e.ErrorData() != (&testDataError{}).ErrorData() {
+		t.Fatalf("wrong error data %#v", e.ErrorData())
+	}
+}
+
 func TestClientBatchRequest(t *testing.T) {
 	server := newTestServer("service", new(Service))
 	defer server.Stop()
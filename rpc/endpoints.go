@@ -18,42 +18,46 @@ package rpc
 
 import (
 	"net"
+	"time"
 
 	"github.com/simplechain-org/go-simplechain/log"
 )
 
-// StartHTTPEndpoint starts the HTTP RPC endpoint, configured with cors/vhosts/modules
-func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []string, vhosts []string, timeouts HTTPTimeouts) (net.Listener, *Server, error) {
-	// Generate the whitelist based on the allowed modules
-	whitelist := make(map[string]bool)
-	for _, module := range modules {
-		whitelist[module] = true
+// EndpointConfig is the request policy enforced by an RPC endpoint.
+type EndpointConfig struct {
+	Auth              *AuthConfig  // Credentials required by the endpoint, nil if open
+	Limits            *LimitConfig // Request budget of the endpoint, nil if unlimited
+	BatchItemLimit    int          // Maximum number of requests in a batch, zero if unlimited
+	ResponseSizeLimit int          // Maximum size of a response in bytes, zero if unlimited
+
+	SlowRequestThreshold time.Duration // Execution time above which requests are logged, zero if disabled
+}
+
+// Configure enforces the given request policy on the server. It must be called
+// before the server starts serving.
+func (s *Server) Configure(config *EndpointConfig) error {
+	if config == nil {
+		return nil
 	}
-	// Register all the APIs exposed by the services
-	handler := NewServer()
-	for _, api := range apis {
-		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
-			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
-				return nil, nil, err
-			}
-			log.Debug("HTTP registered", "namespace", api.Namespace)
+	if config.Auth != nil {
+		if err := s.EnableAuth(*config.Auth); err != nil {
+			return err
 		}
 	}
-	// All APIs registered, start the HTTP listener
-	var (
-		listener net.Listener
-		err      error
-	)
-	if listener, err = net.Listen("tcp", endpoint); err != nil {
-		return nil, nil, err
+	if config.Limits != nil {
+		if err := s.EnableLimits(*config.Limits); err != nil {
+			return err
+		}
 	}
-	go NewHTTPServer(cors, vhosts, timeouts, handler).Serve(listener)
-	return listener, handler, err
+	s.SetBatchLimits(config.BatchItemLimit, config.ResponseSizeLimit)
+	s.SetSlowRequestThreshold(config.SlowRequestThreshold)
+	return nil
 }
 
-// StartWSEndpoint starts a websocket endpoint
-func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []string, exposeAll bool) (net.Listener, *Server, error) {
-
+// NewEndpointServer creates a server exposing the APIs whose namespace is among
+// the modules (or the public ones if no modules are given, or all of them if
+// exposeAll is set) and enforcing the given request policy.
+func NewEndpointServer(apis []API, modules []string, exposeAll bool, config *EndpointConfig) (*Server, error) {
 	// Generate the whitelist based on the allowed modules
 	whitelist := make(map[string]bool)
 	for _, module := range modules {
@@ -64,26 +68,51 @@ func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []
 	for _, api := range apis {
 		if exposeAll || whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
-				return nil, nil, err
+				return nil, err
 			}
-			log.Debug("WebSocket registered", "service", api.Service, "namespace", api.Namespace)
+			log.Debug("RPC registered", "service", api.Service, "namespace", api.Namespace)
 		}
 	}
+	if err := handler.Configure(config); err != nil {
+		return nil, err
+	}
+	return handler, nil
+}
+
+// StartHTTPEndpoint starts the HTTP RPC endpoint, configured with cors/vhosts/modules
+// and enforcing the given request policy.
+func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []string, vhosts []string, timeouts HTTPTimeouts, config *EndpointConfig) (net.Listener, *Server, error) {
+	handler, err := NewEndpointServer(apis, modules, false, config)
+	if err != nil {
+		return nil, nil, err
+	}
 	// All APIs registered, start the HTTP listener
-	var (
-		listener net.Listener
-		err      error
-	)
-	if listener, err = net.Listen("tcp", endpoint); err != nil {
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
 		return nil, nil, err
 	}
-	go NewWSServer(wsOrigins, handler).Serve(listener)
-	return listener, handler, err
+	go NewHTTPServer(cors, vhosts, timeouts, handler).Serve(listener)
+	return listener, handler, nil
+}
 
+// StartWSEndpoint starts a websocket endpoint enforcing the given request policy.
+func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []string, exposeAll bool, config *EndpointConfig) (net.Listener, *Server, error) {
+	handler, err := NewEndpointServer(apis, modules, exposeAll, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	// All APIs registered, start the HTTP listener
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	go NewWSServer(wsOrigins, handler).Serve(listener)
+	return listener, handler, nil
 }
 
-// StartIPCEndpoint starts an IPC endpoint.
-func StartIPCEndpoint(ipcEndpoint string, apis []API) (net.Listener, *Server, error) {
+// StartIPCEndpoint starts an IPC endpoint enforcing the given request policy.
+// Connections authenticate in-band if credentials are required.
+func StartIPCEndpoint(ipcEndpoint string, apis []API, config *EndpointConfig) (net.Listener, *Server, error) {
 	// Register all the APIs exposed by the services.
 	handler := NewServer()
 	for _, api := range apis {
@@ -92,6 +121,9 @@ func StartIPCEndpoint(ipcEndpoint string, apis []API) (net.Listener, *Server, er
 		}
 		log.Debug("IPC registered", "namespace", api.Namespace)
 	}
+	if err := handler.Configure(config); err != nil {
+		return nil, nil, err
+	}
 	// All APIs registered, start the IPC listener.
 	listener, err := ipcListen(ipcEndpoint)
 	if err != nil {
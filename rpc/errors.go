@@ -16,7 +16,11 @@
 
 package rpc
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 // request is for an unknown service
 type methodNotFoundError struct {
@@ -64,3 +68,88 @@ type shutdownError struct{}
 func (e *shutdownError) ErrorCode() int { return -32000 }
 
 func (e *shutdownError) Error() string { return "server is shutting down" }
+
+// request credentials are missing or invalid
+type unauthorizedError struct{ message string }
+
+func (e *unauthorizedError) ErrorCode() int { return -32001 }
+
+func (e *unauthorizedError) Error() string { return "unauthorized: " + e.message }
+
+// request credentials don't allow calling the method
+type forbiddenError struct{ method string }
+
+func (e *forbiddenError) ErrorCode() int { return -32003 }
+
+func (e *forbiddenError) Error() string {
+	return fmt.Sprintf("method %s not allowed for the given credentials", e.method)
+}
+
+// request exceeds the rate limit of its client
+type rateLimitError struct{ retryAfter time.Duration }
+
+func (e *rateLimitError) ErrorCode() int { return -32005 }
+
+func (e *rateLimitError) Error() string { return "request rate limit exceeded" }
+
+func (e *rateLimitError) ErrorData() interface{} {
+	return map[string]interface{}{"retryAfter": int64(e.retryAfter / time.Millisecond)}
+}
+
+// too many heavy requests are executing at once
+type heavyLimitError struct {
+	method string
+	limit  int
+}
+
+func (e *heavyLimitError) ErrorCode() int { return -32005 }
+
+func (e *heavyLimitError) Error() string {
+	return fmt.Sprintf("too many concurrent heavy requests, %s rejected", e.method)
+}
+
+func (e *heavyLimitError) ErrorData() interface{} {
+	return map[string]interface{}{"limit": e.limit}
+}
+
+// request execution exceeded its timeout
+type timeoutError struct {
+	method  string
+	timeout time.Duration
+}
+
+func (e *timeoutError) ErrorCode() int { return -32002 }
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("request %s timed out after %v", e.method, e.timeout)
+}
+
+// errResponseTooLarge is returned when writing a message exceeding the response
+// size limit which can't be replaced by an error response.
+var errResponseTooLarge = errors.New("response too large")
+
+// response exceeds the response size limit
+type responseTooLargeError struct{ limit int }
+
+func (e *responseTooLargeError) ErrorCode() int { return -32005 }
+
+func (e *responseTooLargeError) Error() string {
+	return fmt.Sprintf("response exceeds the size limit of %d bytes", e.limit)
+}
+
+func (e *responseTooLargeError) ErrorData() interface{} {
+	return map[string]interface{}{"limit": e.limit}
+}
+
+// batch request holds too many requests
+type batchTooLargeError struct{ limit int }
+
+func (e *batchTooLargeError) ErrorCode() int { return -32005 }
+
+func (e *batchTooLargeError) Error() string {
+	return fmt.Sprintf("batch exceeds the limit of %d requests", e.limit)
+}
+
+func (e *batchTooLargeError) ErrorData() interface{} {
+	return map[string]interface{}{"limit": e.limit}
+}
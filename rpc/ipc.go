@@ -24,8 +24,14 @@ import (
 	"github.com/simplechain-org/go-simplechain/p2p/netutil"
 )
 
-// ServeListener accepts connections on l, serving JSON-RPC on them.
+// ServeListener accepts connections on l, serving JSON-RPC on them either as
+// plain JSON or in binary frames.
 func (srv *Server) ServeListener(l net.Listener) error {
+	return srv.serveListener(l, "ipc")
+}
+
+// serveListener accepts connections on l, serving them as the given transport.
+func (srv *Server) serveListener(l net.Listener, transport string) error {
 	for {
 		conn, err := l.Accept()
 		if netutil.IsTemporaryError(err) {
@@ -35,7 +41,19 @@ func (srv *Server) ServeListener(l net.Listener) error {
 			return err
 		}
 		log.Trace("Accepted connection", "addr", conn.RemoteAddr())
-		go srv.ServeCodec(NewJSONCodec(conn), OptionMethodInvocation|OptionSubscriptions)
+		go func(conn net.Conn) {
+			// Detect the framing from the first bytes sent by the client
+			codec, err := newSniffedCodec(conn)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			ctx := withTransport(srv.withAuth(context.Background(), ""), transport)
+			if addr := conn.RemoteAddr(); addr != nil && transport == "tcp" {
+				ctx = context.WithValue(ctx, "remote", addr.String())
+			}
+			srv.serveCodec(ctx, codec, OptionMethodInvocation|OptionSubscriptions)
+		}(conn)
 	}
 }
 
@@ -46,7 +64,19 @@ func (srv *Server) ServeListener(l net.Listener) error {
 // The context is used for the initial connection establishment. It does not
 // affect subsequent interactions with the client.
 func DialIPC(ctx context.Context, endpoint string) (*Client, error) {
+	return dialIPC(ctx, endpoint, nil)
+}
+
+func dialIPC(ctx context.Context, endpoint string, credentials Credentials) (*Client, error) {
 	return newClient(ctx, func(ctx context.Context) (net.Conn, error) {
-		return newIPCConnection(ctx, endpoint)
+		conn, err := newIPCConnection(ctx, endpoint)
+		if err != nil || credentials == nil {
+			return conn, err
+		}
+		if err := authenticateConn(ctx, conn, credentials); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
 	})
 }
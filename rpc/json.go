@@ -17,7 +17,9 @@
 package rpc
 
 import (
+	"bufio"
 	"bytes"
+	"encoding"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -82,7 +84,12 @@ type jsonCodec struct {
 	decode func(v interface{}) error // decoder to allow multiple transports
 	encMu  sync.Mutex                // guards the encoder
 	encode func(v interface{}) error // encoder to allow multiple transports
+	stream io.Writer                 // writer to stream large results to, nil if unsupported
+	limit  int                       // maximum size of a written message, zero if unlimited
 	rw     io.ReadWriteCloser        // connection
+
+	// decodeRequests reads requests not wrapped in JSON, nil if unsupported
+	decodeRequests func() ([]jsonRequest, bool, error)
 }
 
 func (err *jsonError) Error() string {
@@ -96,6 +103,10 @@ func (err *jsonError) ErrorCode() int {
 	return err.Code
 }
 
+func (err *jsonError) ErrorData() interface{} {
+	return err.Data
+}
+
 // NewCodec creates a new RPC server codec with support for JSON-RPC 2.0 based
 // on explicitly given encoding and decoding methods.
 func NewCodec(rwc io.ReadWriteCloser, encode, decode func(v interface{}) error) ServerCodec {
@@ -117,6 +128,7 @@ func NewJSONCodec(rwc io.ReadWriteCloser) ServerCodec {
 		closed: make(chan interface{}),
 		encode: enc.Encode,
 		decode: dec.Decode,
+		stream: rwc,
 		rw:     rwc,
 	}
 }
@@ -140,6 +152,16 @@ func (c *jsonCodec) ReadRequestHeaders() ([]rpcRequest, bool, Error) {
 	c.decMu.Lock()
 	defer c.decMu.Unlock()
 
+	if c.decodeRequests != nil {
+		in, batch, err := c.decodeRequests()
+		if err != nil {
+			return nil, false, &invalidRequestError{err.Error()}
+		}
+		if batch {
+			return toBatchRequests(in)
+		}
+		return toRequest(&in[0])
+	}
 	var incomingMsg json.RawMessage
 	if err := c.decode(&incomingMsg); err != nil {
 		return nil, false, &invalidRequestError{err.Error()}
@@ -170,11 +192,16 @@ func checkReqId(reqId json.RawMessage) error {
 // the parsed request, an indication if the request was a batch or an error when
 // the request could not be parsed.
 func parseRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error) {
-	var in jsonRequest
-	if err := json.Unmarshal(incomingMsg, &in); err != nil {
+	in := new(jsonRequest)
+	if err := json.Unmarshal(incomingMsg, in); err != nil {
 		return nil, false, &invalidMessageError{err.Error()}
 	}
+	return toRequest(in)
+}
 
+// toRequest converts a decoded request into the request to serve, returning an
+// error when it isn't valid.
+func toRequest(in *jsonRequest) ([]rpcRequest, bool, Error) {
 	if err := checkReqId(in.Id); err != nil {
 		return nil, false, &invalidMessageError{err.Error()}
 	}
@@ -222,7 +249,12 @@ func parseBatchRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error)
 	if err := json.Unmarshal(incomingMsg, &in); err != nil {
 		return nil, false, &invalidMessageError{err.Error()}
 	}
+	return toBatchRequests(in)
+}
 
+// toBatchRequests converts a decoded batch into the requests to serve, returning
+// an error when they can't be served.
+func toBatchRequests(in []jsonRequest) ([]rpcRequest, bool, Error) {
 	requests := make([]rpcRequest, len(in))
 	for i, r := range in {
 		if err := checkReqId(r.Id); err != nil {
@@ -341,12 +373,150 @@ func (c *jsonCodec) CreateNotification(subid, namespace string, event interface{
 		Params: jsonSubscription{Subscription: subid, Result: event}}
 }
 
-// Write message to client
+// Write message to client. Responses exceeding the size limit are marshalled in
+// memory and replaced by an error before anything is written. Without a limit,
+// ResultStream results are written to connections supporting it as they are
+// produced, the connection being closed if producing or encoding them fails.
 func (c *jsonCodec) Write(res interface{}) error {
 	c.encMu.Lock()
 	defer c.encMu.Unlock()
 
-	return c.encode(res)
+	if c.limit > 0 {
+		// Marshal the messages in memory, replacing them if exceeding the limit
+		budget := c.limit
+		if batch, ok := res.([]interface{}); ok {
+			msgs := make([]json.RawMessage, len(batch))
+			for i, msg := range batch {
+				blob, err := c.marshalLimited(msg, &budget)
+				if err != nil {
+					return err
+				}
+				msgs[i] = blob
+			}
+			return c.encode(msgs)
+		}
+		msg, err := c.marshalLimited(res, &budget)
+		if err != nil {
+			return err
+		}
+		return c.encode(msg)
+	}
+	if c.stream == nil || !isStreamed(res) {
+		return c.encode(res)
+	}
+	// Stream the message to the connection. Parts of it may already be sent
+	// if it fails, so close the connection rather than leave it corrupted
+	if err := writeStreamed(c.stream, res); err != nil {
+		c.Close()
+		return err
+	}
+	return nil
+}
+
+// marshalLimited encodes a message within the remaining size budget, replacing
+// responses exceeding it by an error. Other messages exceeding the budget can't
+// be replaced and fail.
+func (c *jsonCodec) marshalLimited(msg interface{}, budget *int) (json.RawMessage, error) {
+	blob, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) > *budget {
+		resp, ok := msg.(*jsonSuccessResponse)
+		if !ok {
+			return nil, errResponseTooLarge
+		}
+		rpcErr := &responseTooLargeError{c.limit}
+		if blob, err = json.Marshal(c.CreateErrorResponseWithInfo(resp.Id, rpcErr, rpcErr.ErrorData())); err != nil {
+			return nil, err
+		}
+	}
+	*budget -= len(blob)
+	return blob, nil
+}
+
+// writeStreamed writes a message or a batch of messages, encoding the elements
+// of ResultStream results as they are produced within an envelope laid out like
+// that of jsonSuccessResponse.
+func writeStreamed(stream io.Writer, res interface{}) error {
+	w := bufio.NewWriter(stream)
+	if batch, ok := res.([]interface{}); ok {
+		w.WriteByte('[')
+		for i, msg := range batch {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := writeStreamedMessage(w, msg); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	} else if err := writeStreamedMessage(w, res); err != nil {
+		return err
+	}
+	w.WriteByte('\n')
+	return w.Flush()
+}
+
+// writeStreamedMessage writes a single message, streaming its result if it is
+// a ResultStream.
+func writeStreamedMessage(w io.Writer, msg interface{}) error {
+	resp, ok := msg.(*jsonSuccessResponse)
+	if !ok || !isStreamed(resp) {
+		blob, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(blob)
+		return err
+	}
+	version, err := json.Marshal(resp.Version)
+	if err != nil {
+		return err
+	}
+	buf := bytes.NewBufferString(`{"jsonrpc":`)
+	buf.Write(version)
+	if raw, ok := resp.Id.(json.RawMessage); resp.Id != nil && !(ok && len(raw) == 0) {
+		id, err := json.Marshal(resp.Id)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(`,"id":`)
+		buf.Write(id)
+	}
+	buf.WriteString(`,"result":`)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := resp.Result.(ResultStream).writeJSON(w); err != nil {
+		return err
+	}
+	_, err = w.Write([]byte("}"))
+	return err
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// isStreamed returns whether a message, or any message of a batch, is a
+// response whose result is a ResultStream.
+func isStreamed(msg interface{}) bool {
+	if batch, ok := msg.([]interface{}); ok {
+		for _, msg := range batch {
+			if isStreamed(msg) {
+				return true
+			}
+		}
+		return false
+	}
+	resp, ok := msg.(*jsonSuccessResponse)
+	if !ok {
+		return false
+	}
+	stream, ok := resp.Result.(ResultStream)
+	return ok && stream != nil
 }
 
 // Close the underlying connection
@@ -0,0 +1,202 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// limiterCacheSize is the number of client addresses and API keys whose rate
+// limiters are tracked at once.
+const limiterCacheSize = 4096
+
+// LimitConfig is the request budget enforced by a server. Every request costs a
+// number of units, charged against the rate limit of its API key if it has one,
+// and otherwise of its connection (or client address over HTTP).
+type LimitConfig struct {
+	Rate             float64        // Cost units replenished per second, zero disables rate limiting
+	Burst            int            // Cost units spendable at once, defaults to the rate
+	MethodCosts      map[string]int // Cost of the methods or namespaces, one unit if unlisted
+	HeavyMethods     []string       // Methods or namespaces subject to the concurrency limit
+	HeavyConcurrency int            // Heavy requests executed at once, zero for no limit
+	Timeout          time.Duration  // Execution timeout of requests, zero for no timeout
+}
+
+// cost returns the number of units charged for calling the given method.
+func (c *LimitConfig) cost(method string) int {
+	if cost, ok := c.MethodCosts[method]; ok {
+		return cost
+	}
+	if cost, ok := c.MethodCosts[methodNamespace(method)]; ok {
+		return cost
+	}
+	return 1
+}
+
+// EnableLimits enforces the given request budget on all requests served from
+// now on. It must be called before the server starts serving.
+func (s *Server) EnableLimits(config LimitConfig) error {
+	if config.Rate < 0 || config.Burst < 0 || config.HeavyConcurrency < 0 || config.Timeout < 0 {
+		return fmt.Errorf("negative request limits")
+	}
+	if config.Burst == 0 {
+		config.Burst = int(math.Ceil(config.Rate))
+	}
+	for method, cost := range config.MethodCosts {
+		if cost < 0 {
+			return fmt.Errorf("negative cost %d for %s", cost, method)
+		}
+		if config.Rate > 0 && cost > config.Burst {
+			return fmt.Errorf("cost %d of %s exceeds the burst of %d", cost, method, config.Burst)
+		}
+	}
+	s.limits = &config
+	if config.Rate > 0 {
+		s.limiters, _ = lru.New(limiterCacheSize)
+	}
+	if config.HeavyConcurrency > 0 {
+		s.heavy = make(chan struct{}, config.HeavyConcurrency)
+	}
+	return nil
+}
+
+// tokenBucket is a rate limiter replenishing its budget continuously.
+type tokenBucket struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// take withdraws the given cost from the bucket if it holds enough tokens, or
+// returns the time to wait until it does.
+func (b *tokenBucket) take(cost int) (time.Duration, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens < float64(cost) {
+		return time.Duration((float64(cost) - b.tokens) / b.rate * float64(time.Second)), false
+	}
+	b.tokens -= float64(cost)
+	return 0, true
+}
+
+// limiterKey is the context key of the rate limiter of a connection.
+type limiterKey struct{}
+
+// withLimiter attaches a rate limiter to the context of a connection, if the
+// server enforces a rate limit.
+func (s *Server) withLimiter(ctx context.Context) context.Context {
+	if s.limits == nil || s.limiters == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, limiterKey{}, newTokenBucket(s.limits.Rate, s.limits.Burst))
+}
+
+// limiter returns the rate limiter charged for the requests of the context:
+// the one of its API key, of its connection or of its client address.
+func (s *Server) limiter(ctx context.Context) *tokenBucket {
+	if state, ok := ctx.Value(authStateKey{}).(*authState); ok {
+		state.lock.RLock()
+		key := state.key
+		state.lock.RUnlock()
+
+		if key != "" {
+			return s.sharedLimiter("key:" + key)
+		}
+	}
+	if bucket, ok := ctx.Value(limiterKey{}).(*tokenBucket); ok {
+		return bucket
+	}
+	if remote, ok := ctx.Value("remote").(string); ok {
+		host, _, err := net.SplitHostPort(remote)
+		if err != nil {
+			host = remote
+		}
+		return s.sharedLimiter("addr:" + host)
+	}
+	return nil
+}
+
+// sharedLimiter returns the rate limiter of an API key or client address.
+func (s *Server) sharedLimiter(id string) *tokenBucket {
+	s.limitersMu.Lock()
+	defer s.limitersMu.Unlock()
+
+	if bucket, ok := s.limiters.Get(id); ok {
+		return bucket.(*tokenBucket)
+	}
+	bucket := newTokenBucket(s.limits.Rate, s.limits.Burst)
+	s.limiters.Add(id, bucket)
+	return bucket
+}
+
+// admit charges the cost of a request against its rate limit and reserves a
+// heavy request slot if needed. The returned function releases the request.
+func (s *Server) admit(ctx context.Context, method string) (func(), Error) {
+	if s.limits == nil {
+		return func() {}, nil
+	}
+	if s.limiters != nil {
+		if bucket := s.limiter(ctx); bucket != nil {
+			cost := s.limits.cost(method)
+			if wait, ok := bucket.take(cost); !ok {
+				rpcRateLimitedMeter.Mark(1)
+				return nil, &rateLimitError{wait}
+			}
+			rpcCostMeter.Mark(int64(cost))
+		}
+	}
+	if s.heavy == nil || !matchMethod(s.limits.HeavyMethods, method) {
+		return func() {}, nil
+	}
+	select {
+	case s.heavy <- struct{}{}:
+		rpcHeavyActiveGauge.Update(int64(len(s.heavy)))
+		return func() {
+			<-s.heavy
+			rpcHeavyActiveGauge.Update(int64(len(s.heavy)))
+		}, nil
+	default:
+		rpcHeavyRejectedMeter.Mark(1)
+		return nil, &heavyLimitError{method, s.limits.HeavyConcurrency}
+	}
+}
+
+// withTimeout bounds the execution of a request by the configured timeout.
+func (s *Server) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.limits == nil || s.limits.Timeout == 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.limits.Timeout)
+}
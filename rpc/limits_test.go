@@ -0,0 +1,190 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// checkLimitCall calls a method, expecting either success or an error with the
+// given code.
+func checkLimitCall(t *testing.T, client *Client, want int, method string, args ...interface{}) {
+	t.Helper()
+
+	err := client.Call(nil, method, args...)
+	switch {
+	case want == 0 && err != nil:
+		t.Errorf("%s failed: %v", method, err)
+	case want != 0 && err == nil:
+		t.Errorf("%s succeeded, want error code %d", method, want)
+	case want != 0:
+		if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != want {
+			t.Errorf("%s error mismatch: have %v, want code %d", method, err, want)
+		}
+	}
+}
+
+func TestLimitConfigValidation(t *testing.T) {
+	tests := []struct {
+		config LimitConfig
+		fail   bool
+	}{
+		{config: LimitConfig{}},
+		{config: LimitConfig{Rate: 10, MethodCosts: map[string]int{"eth_getLogs": 10}}},
+		{config: LimitConfig{Rate: 10, MethodCosts: map[string]int{"eth_getLogs": 11}}, fail: true},
+		{config: LimitConfig{Rate: 10, Burst: 20, MethodCosts: map[string]int{"eth_getLogs": 11}}},
+		{config: LimitConfig{Rate: -1}, fail: true},
+		{config: LimitConfig{MethodCosts: map[string]int{"eth": -1}}, fail: true},
+	}
+	for i, tt := range tests {
+		err := NewServer().EnableLimits(tt.config)
+		if tt.fail && err == nil {
+			t.Errorf("test %d: invalid limits accepted", i)
+		}
+		if !tt.fail && err != nil {
+			t.Errorf("test %d: valid limits rejected: %v", i, err)
+		}
+	}
+}
+
+// Tests that requests are charged their cost against the rate limit of their
+// client address over HTTP, and of their connection over websocket.
+func TestRateLimit(t *testing.T) {
+	config := LimitConfig{
+		Rate:        0.001, // Practically no refill during the test
+		Burst:       4,
+		MethodCosts: map[string]int{"service_rets": 3},
+	}
+	for _, transport := range []string{"http", "ws"} {
+		server := newTestServer("service", new(Service))
+		if err := server.EnableLimits(config); err != nil {
+			t.Fatalf("failed to enable limits: %v", err)
+		}
+		var hs *httptest.Server
+		if transport == "http" {
+			hs = httptest.NewServer(server)
+		} else {
+			hs = httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+		}
+		url := transport + "://" + hs.Listener.Addr().String()
+
+		first, _ := Dial(url)
+		checkLimitCall(t, first, 0, "service_rets")
+		checkLimitCall(t, first, 0, "service_noArgsRets")
+		checkLimitCall(t, first, -32005, "service_noArgsRets")
+
+		// The limit error must tell when to retry
+		err := first.Call(nil, "service_noArgsRets")
+		if de, ok := err.(DataError); !ok {
+			t.Errorf("%s: rate limit error without data: %v", transport, err)
+		} else if data, ok := de.ErrorData().(map[string]interface{}); !ok || data["retryAfter"] == nil {
+			t.Errorf("%s: rate limit error data mismatch: %v", transport, de.ErrorData())
+		}
+		// A second connection shares the budget of its address over HTTP only
+		second, _ := Dial(url)
+		if transport == "http" {
+			checkLimitCall(t, second, -32005, "service_noArgsRets")
+		} else {
+			checkLimitCall(t, second, 0, "service_noArgsRets")
+		}
+		first.Close()
+		second.Close()
+		hs.Close()
+		server.Stop()
+	}
+}
+
+// Tests that authenticated requests are charged against the rate limit of their
+// API key, shared by all its connections.
+func TestRateLimitAPIKey(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	if err := server.EnableAuth(AuthConfig{APIKeys: map[string]ACL{"a": {"*"}, "b": {"*"}}}); err != nil {
+		t.Fatalf("failed to enable authentication: %v", err)
+	}
+	if err := server.EnableLimits(LimitConfig{Rate: 0.001, Burst: 2}); err != nil {
+		t.Fatalf("failed to enable limits: %v", err)
+	}
+	defer server.Stop()
+
+	hs := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer hs.Close()
+	url := "ws://" + hs.Listener.Addr().String()
+
+	dial := func(key string) *Client {
+		client, err := DialOptions(context.Background(), url, WithCredentials(APIKeyCredentials(key)))
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		return client
+	}
+	a1, a2, b := dial("a"), dial("a"), dial("b")
+	defer a1.Close()
+	defer a2.Close()
+	defer b.Close()
+
+	checkLimitCall(t, a1, 0, "service_noArgsRets")
+	checkLimitCall(t, a2, 0, "service_noArgsRets")
+	checkLimitCall(t, a1, -32005, "service_noArgsRets")
+	checkLimitCall(t, a2, -32005, "service_noArgsRets")
+	checkLimitCall(t, b, 0, "service_noArgsRets")
+}
+
+// Tests that heavy requests are rejected when too many of them execute at once.
+func TestHeavyConcurrencyLimit(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	if err := server.EnableLimits(LimitConfig{HeavyMethods: []string{"service_sleep"}, HeavyConcurrency: 1}); err != nil {
+		t.Fatalf("failed to enable limits: %v", err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	done := make(chan error)
+	go func() {
+		done <- client.Call(nil, "service_sleep", 500*time.Millisecond)
+	}()
+	for len(server.heavy) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	checkLimitCall(t, client, -32005, "service_sleep", time.Millisecond)
+	checkLimitCall(t, client, 0, "service_noArgsRets")
+
+	if err := <-done; err != nil {
+		t.Fatalf("heavy request failed: %v", err)
+	}
+	checkLimitCall(t, client, 0, "service_sleep", time.Millisecond)
+}
+
+// Tests that requests exceeding the execution timeout are interrupted through
+// their context and answered with a timeout error.
+func TestRequestTimeout(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	if err := server.EnableLimits(LimitConfig{Timeout: 50 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to enable limits: %v", err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	start := time.Now()
+	checkLimitCall(t, client, -32002, "service_sleep", 10*time.Second)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request not interrupted: took %v", elapsed)
+	}
+	checkLimitCall(t, client, 0, "service_sleep", time.Millisecond)
+}
@@ -0,0 +1,140 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/log"
+	"github.com/simplechain-org/go-simplechain/metrics"
+)
+
+// maxLoggedParamsLength is the number of bytes of the parameters of a slow
+// request included in its log entry.
+const maxLoggedParamsLength = 256
+
+var (
+	rpcCostMeter          = metrics.NewRegisteredMeter("rpc/limits/cost", nil)
+	rpcRateLimitedMeter   = metrics.NewRegisteredMeter("rpc/limits/ratelimited", nil)
+	rpcHeavyRejectedMeter = metrics.NewRegisteredMeter("rpc/limits/heavy/rejected", nil)
+	rpcHeavyActiveGauge   = metrics.NewRegisteredGauge("rpc/limits/heavy/active", nil)
+	rpcTimeoutMeter       = metrics.NewRegisteredMeter("rpc/limits/timeouts", nil)
+
+	rpcSlowMeter = metrics.NewRegisteredMeter("rpc/slow", nil)
+
+	// rpcInflight counts the requests being executed over each transport
+	rpcInflight = map[string]*int64{
+		"http":   newInflightGauge("http"),
+		"ws":     newInflightGauge("ws"),
+		"ipc":    newInflightGauge("ipc"),
+		"tcp":    newInflightGauge("tcp"),
+		"inproc": newInflightGauge("inproc"),
+	}
+)
+
+// newInflightGauge registers the gauge of the requests being executed over a
+// transport, returning the counter it reports.
+func newInflightGauge(transport string) *int64 {
+	count := new(int64)
+	metrics.NewRegisteredFunctionalGauge("rpc/inflight/"+transport, nil, func() int64 {
+		return atomic.LoadInt64(count)
+	})
+	return count
+}
+
+// transportKey is the context key of the name of the transport of a request.
+type transportKey struct{}
+
+// withTransport attaches the name of the transport serving the requests to
+// the context.
+func withTransport(ctx context.Context, transport string) context.Context {
+	return context.WithValue(ctx, transportKey{}, transport)
+}
+
+// transportOf returns the name of the transport serving the requests of the
+// context, or "unknown" for codecs served directly.
+func transportOf(ctx context.Context) string {
+	if transport, ok := ctx.Value(transportKey{}).(string); ok {
+		return transport
+	}
+	return "unknown"
+}
+
+// SetSlowRequestThreshold logs the requests executing for longer than the given
+// duration along with their parameters, zero disabling the logging. It must be
+// called before the server starts serving.
+func (s *Server) SetSlowRequestThreshold(threshold time.Duration) {
+	s.slowThreshold = threshold
+}
+
+// handleMetered executes a request like handle, recording its metrics and
+// logging it if it is slow.
+func (s *Server) handleMetered(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
+	transport := transportOf(ctx)
+	if inflight, ok := rpcInflight[transport]; ok {
+		atomic.AddInt64(inflight, 1)
+		defer atomic.AddInt64(inflight, -1)
+	}
+	start := time.Now()
+	response, callback := s.handle(ctx, codec, req)
+	elapsed := time.Since(start)
+
+	if req.method != "" {
+		metrics.GetOrRegisterCounter("rpc/calls/"+req.method, nil).Inc(1)
+		if isErrorResponse(response) {
+			metrics.GetOrRegisterCounter("rpc/errors/"+req.method, nil).Inc(1)
+		}
+		metrics.GetOrRegisterTimer("rpc/duration/"+req.method, nil).Update(elapsed)
+	}
+	if s.slowThreshold > 0 && elapsed >= s.slowThreshold {
+		rpcSlowMeter.Mark(1)
+
+		remote, _ := ctx.Value("remote").(string)
+		log.Warn("Slow RPC request", "method", req.method, "transport", transport, "remote", remote,
+			"elapsed", common.PrettyDuration(elapsed), "params", formatParams(req.params))
+	}
+	return response, callback
+}
+
+// isErrorResponse returns whether a response created by the codec is an error.
+func isErrorResponse(response interface{}) bool {
+	_, ok := response.(*jsonErrResponse)
+	return ok
+}
+
+// formatParams renders the raw parameters of a request for logging, truncated
+// to a reasonable length.
+func formatParams(params interface{}) string {
+	var text string
+	switch params := params.(type) {
+	case nil:
+		return ""
+	case json.RawMessage:
+		text = string(params)
+	default:
+		text = fmt.Sprint(params)
+	}
+	if len(text) > maxLoggedParamsLength {
+		text = text[:maxLoggedParamsLength] + "..."
+	}
+	return text
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/log"
+	"github.com/simplechain-org/go-simplechain/metrics"
+)
+
+func TestFormatParams(t *testing.T) {
+	long := strings.Repeat("x", 2*maxLoggedParamsLength)
+	tests := []struct {
+		params interface{}
+		want   string
+	}{
+		{nil, ""},
+		{[]byte(nil), "[]"},
+		{json.RawMessage(`["a",1]`), `["a",1]`},
+		{json.RawMessage(long), long[:maxLoggedParamsLength] + "..."},
+	}
+	for i, tt := range tests {
+		if have := formatParams(tt.params); have != tt.want {
+			t.Errorf("test %d: formatted params mismatch: have %q, want %q", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that requests are counted and timed per method and per transport, and
+// that slow ones are logged.
+func TestRequestMetrics(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	defer func() { metrics.Enabled = enabled }()
+
+	slow := make(chan *log.Record, 10)
+	handler := log.Root().GetHandler()
+	log.Root().SetHandler(log.FuncHandler(func(r *log.Record) error {
+		if r.Msg == "Slow RPC request" {
+			slow <- r
+		}
+		return nil
+	}))
+	defer log.Root().SetHandler(handler)
+
+	server := newTestServer("metered", new(Service))
+	server.SetSlowRequestThreshold(100 * time.Millisecond)
+	client := DialInProc(server)
+	defer client.Close()
+
+	checkLimitCall(t, client, 0, "metered_echo", "hello", 10, &Args{"world"})
+	checkLimitCall(t, client, 0, "metered_echo", "hello", 10, &Args{"world"})
+	checkLimitCall(t, client, -32602, "metered_echo")
+
+	// Slow requests must be reported in flight and logged
+	done := make(chan error)
+	go func() {
+		done <- client.Call(nil, "metered_sleep", 200*time.Millisecond)
+	}()
+	for atomic.LoadInt64(rpcInflight["inproc"]) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("slow request failed: %v", err)
+	}
+	if inflight := atomic.LoadInt64(rpcInflight["inproc"]); inflight != 0 {
+		t.Errorf("in-flight requests mismatch: have %d, want 0", inflight)
+	}
+	select {
+	case r := <-slow:
+		ctx := make(map[interface{}]interface{})
+		for i := 0; i+1 < len(r.Ctx); i += 2 {
+			ctx[r.Ctx[i]] = r.Ctx[i+1]
+		}
+		if ctx["method"] != "metered_sleep" || ctx["transport"] != "inproc" || ctx["params"] != "[200000000]" {
+			t.Errorf("slow request log mismatch: %v", r.Ctx)
+		}
+	default:
+		t.Error("slow request not logged")
+	}
+	if len(slow) != 0 {
+		t.Errorf("fast requests logged as slow: %d", len(slow))
+	}
+	// Check the per-method counters and timers
+	counter := func(name string) int64 {
+		if c, ok := metrics.DefaultRegistry.Get(name).(metrics.Counter); ok {
+			return c.Count()
+		}
+		return -1
+	}
+	if calls := counter("rpc/calls/metered_echo"); calls != 3 {
+		t.Errorf("call counter mismatch: have %d, want 3", calls)
+	}
+	if errs := counter("rpc/errors/metered_echo"); errs != 1 {
+		t.Errorf("error counter mismatch: have %d, want 1", errs)
+	}
+	if timer, ok := metrics.DefaultRegistry.Get("rpc/duration/metered_sleep").(metrics.Timer); !ok || timer.Count() != 1 {
+		t.Errorf("latency timer mismatch: %v", metrics.DefaultRegistry.Get("rpc/duration/metered_sleep"))
+	} else if timer.Max() < int64(200*time.Millisecond) {
+		t.Errorf("latency mismatch: have %v, want at least %v", time.Duration(timer.Max()), 200*time.Millisecond)
+	}
+}
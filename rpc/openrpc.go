@@ -0,0 +1,370 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+)
+
+// OpenRPCVersion is the version of the OpenRPC specification the documents
+// returned by rpc_discover conform to.
+const OpenRPCVersion = "1.2.6"
+
+// OpenRPCDocument describes the methods served by a server, see
+// https://spec.open-rpc.org.
+type OpenRPCDocument struct {
+	OpenRPC    string             `json:"openrpc"`
+	Info       OpenRPCInfo        `json:"info"`
+	Methods    []*OpenRPCMethod   `json:"methods"`
+	Components *OpenRPCComponents `json:"components,omitempty"`
+}
+
+// OpenRPCInfo is the metadata of an OpenRPC document.
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenRPCMethod describes a method, or a subscription created through the
+// subscribe method of its namespace.
+type OpenRPCMethod struct {
+	Name           string                      `json:"name"`
+	Description    string                      `json:"description,omitempty"`
+	Params         []*OpenRPCContentDescriptor `json:"params"`
+	Result         *OpenRPCContentDescriptor   `json:"result"`
+	ParamStructure string                      `json:"paramStructure"`
+	Subscription   *OpenRPCSubscription        `json:"x-subscription,omitempty"`
+}
+
+// OpenRPCSubscription is the extension describing how a subscription is created.
+// The name must be passed as the first parameter of the subscribe method, before
+// the parameters of the subscription.
+type OpenRPCSubscription struct {
+	Method string `json:"method"`
+	Name   string `json:"name"`
+}
+
+// OpenRPCContentDescriptor describes a parameter or the result of a method.
+type OpenRPCContentDescriptor struct {
+	Name     string      `json:"name"`
+	Required bool        `json:"required,omitempty"`
+	Schema   *JSONSchema `json:"schema"`
+}
+
+// OpenRPCComponents holds the schemas of the named struct types, which are
+// referenced from the methods.
+type OpenRPCComponents struct {
+	Schemas map[string]*JSONSchema `json:"schemas"`
+}
+
+// JSONSchema is the subset of JSON schema used to describe the values exchanged
+// with the methods. The empty schema accepts any value.
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	ContentEncoding      string                 `json:"contentEncoding,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	quantitySchema = &JSONSchema{Title: "quantity", Type: "string", Pattern: "^0x(0|[1-9a-f][0-9a-f]*)$"}
+	bytesSchema    = &JSONSchema{Title: "bytes", Type: "string", Pattern: "^0x([0-9a-fA-F]{2})*$"}
+
+	// knownSchemas maps the types with a custom JSON encoding to their schema.
+	knownSchemas = map[reflect.Type]*JSONSchema{
+		reflect.TypeOf(hexutil.Big{}):     quantitySchema,
+		reflect.TypeOf(hexutil.Uint64(0)): quantitySchema,
+		reflect.TypeOf(hexutil.Uint(0)):   quantitySchema,
+		reflect.TypeOf(hexutil.Bytes{}):   bytesSchema,
+		reflect.TypeOf(common.Address{}):  {Title: "address", Type: "string", Pattern: "^0x[0-9a-fA-F]{40}$"},
+		reflect.TypeOf(common.Hash{}):     {Title: "hash", Type: "string", Pattern: "^0x[0-9a-fA-F]{64}$"},
+		reflect.TypeOf(BlockNumber(0)): {Title: "blockNumber", OneOf: []*JSONSchema{
+			quantitySchema,
+			{Type: "string", Enum: []string{"earliest", "latest", "pending", "safe", "finalized"}},
+		}},
+		reflect.TypeOf(big.Int{}):         {Type: "integer"},
+		reflect.TypeOf(time.Time{}):       {Type: "string", Format: "date-time"},
+		reflect.TypeOf(json.RawMessage{}): {},
+		reflect.TypeOf(ID("")):            {Title: "subscriptionID", Type: "string"},
+		reflect.TypeOf(ResultStream(nil)): {Type: "array"},
+	}
+
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Discover returns the OpenRPC document describing the methods of all registered
+// services, generated from their signatures.
+func (s *RPCService) Discover() *OpenRPCDocument {
+	return s.server.openRPC()
+}
+
+// openRPC generates the OpenRPC document of the registered services, with the
+// methods sorted by name.
+func (s *Server) openRPC() *OpenRPCDocument {
+	gen := &schemaGenerator{names: make(map[reflect.Type]string), schemas: make(map[string]*JSONSchema)}
+	doc := &OpenRPCDocument{
+		OpenRPC: OpenRPCVersion,
+		Info:    OpenRPCInfo{Title: "JSON-RPC API", Version: "1.0"},
+		Methods: []*OpenRPCMethod{},
+	}
+	for _, svc := range s.services {
+		for name, cb := range svc.callbacks {
+			// Methods named like the subscription ones are shadowed by them
+			full := svc.name + serviceMethodSeparator + name
+			if full == svc.name+subscribeMethodSuffix || full == svc.name+unsubscribeMethodSuffix {
+				continue
+			}
+			method := &OpenRPCMethod{
+				Name:           full,
+				Params:         gen.params(cb.argTypes),
+				Result:         gen.result(cb),
+				ParamStructure: "by-position",
+			}
+			doc.Methods = append(doc.Methods, method)
+		}
+		if len(svc.subscriptions) == 0 {
+			continue
+		}
+		subscribe := svc.name + subscribeMethodSuffix
+		for name, cb := range svc.subscriptions {
+			method := &OpenRPCMethod{
+				Name:           subscribe + "_" + name,
+				Description:    fmt.Sprintf("Subscription created by calling %s with %q as the first parameter, followed by the listed ones.", subscribe, name),
+				Params:         gen.params(cb.argTypes),
+				Result:         &OpenRPCContentDescriptor{Name: "subscriptionID", Schema: knownSchemas[reflect.TypeOf(ID(""))]},
+				ParamStructure: "by-position",
+				Subscription:   &OpenRPCSubscription{Method: subscribe, Name: name},
+			}
+			doc.Methods = append(doc.Methods, method)
+		}
+		doc.Methods = append(doc.Methods, &OpenRPCMethod{
+			Name:           svc.name + unsubscribeMethodSuffix,
+			Params:         []*OpenRPCContentDescriptor{{Name: "subscriptionID", Required: true, Schema: knownSchemas[reflect.TypeOf(ID(""))]}},
+			Result:         &OpenRPCContentDescriptor{Name: "result", Schema: &JSONSchema{Type: "boolean"}},
+			ParamStructure: "by-position",
+		})
+	}
+	sort.Slice(doc.Methods, func(i, j int) bool { return doc.Methods[i].Name < doc.Methods[j].Name })
+
+	if len(gen.schemas) > 0 {
+		doc.Components = &OpenRPCComponents{Schemas: gen.schemas}
+	}
+	return doc
+}
+
+// schemaGenerator derives the JSON schemas of Go types. Named struct types are
+// added to the components of the document and referenced, which also takes care
+// of recursive types.
+type schemaGenerator struct {
+	names   map[reflect.Type]string
+	schemas map[string]*JSONSchema
+}
+
+// params describes the arguments of a method. Trailing pointer arguments may be
+// omitted by the caller, so they are the only optional ones.
+func (g *schemaGenerator) params(types []reflect.Type) []*OpenRPCContentDescriptor {
+	params := make([]*OpenRPCContentDescriptor, len(types))
+	seen := make(map[string]int)
+	required := false
+	for i := len(types) - 1; i >= 0; i-- {
+		if types[i].Kind() != reflect.Ptr {
+			required = true
+		}
+		params[i] = &OpenRPCContentDescriptor{Required: required, Schema: g.schema(types[i])}
+	}
+	for i, typ := range types {
+		name := paramName(typ)
+		if seen[name]++; seen[name] > 1 {
+			name = fmt.Sprintf("%s%d", name, seen[name])
+		}
+		params[i].Name = name
+	}
+	return params
+}
+
+// result describes the value returned by a method, null if it only returns an
+// error or nothing at all.
+func (g *schemaGenerator) result(cb *callback) *OpenRPCContentDescriptor {
+	for i := 0; i < cb.method.Type.NumOut(); i++ {
+		if i != cb.errPos {
+			return &OpenRPCContentDescriptor{Name: "result", Schema: g.schema(cb.method.Type.Out(i))}
+		}
+	}
+	return &OpenRPCContentDescriptor{Name: "result", Schema: &JSONSchema{Type: "null"}}
+}
+
+// paramName derives the name of a parameter from its type, since the names of
+// the Go arguments are not available through reflection.
+func paramName(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Name() == "" {
+		return "param"
+	}
+	return formatName(typ.Name())
+}
+
+// schema derives the JSON schema of a type from its encoding/json encoding.
+func (g *schemaGenerator) schema(typ reflect.Type) *JSONSchema {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if schema, ok := knownSchemas[typ]; ok {
+		return schema
+	}
+	// Values with a custom encoding are strings if they go through the text
+	// encoding, and anything otherwise
+	ptr := reflect.PtrTo(typ)
+	if ptr.Implements(jsonMarshalerType) || ptr.Implements(jsonUnmarshalerType) {
+		if ptr.Implements(textMarshalerType) || ptr.Implements(textUnmarshalerType) {
+			return &JSONSchema{Type: "string"}
+		}
+		return &JSONSchema{}
+	}
+	if ptr.Implements(textMarshalerType) || ptr.Implements(textUnmarshalerType) {
+		return &JSONSchema{Type: "string"}
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return &JSONSchema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &JSONSchema{Type: "array", Items: g.schema(typ.Elem())}
+	case reflect.Array:
+		n := typ.Len()
+		return &JSONSchema{Type: "array", Items: g.schema(typ.Elem()), MinItems: &n, MaxItems: &n}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: g.schema(typ.Elem())}
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return g.object(typ)
+		}
+		return &JSONSchema{Ref: "#/components/schemas/" + g.component(typ)}
+	default:
+		return &JSONSchema{}
+	}
+}
+
+// component adds the schema of a named struct type to the components if not
+// yet done, returning the name it's registered under.
+func (g *schemaGenerator) component(typ reflect.Type) string {
+	if name, ok := g.names[typ]; ok {
+		return name
+	}
+	// Types of different packages may share the same qualified name
+	name := typ.String()
+	for i := 2; g.schemas[name] != nil; i++ {
+		name = fmt.Sprintf("%s%d", typ.String(), i)
+	}
+	g.names[typ] = name
+	g.schemas[name] = &JSONSchema{} // placeholder for recursive references
+
+	*g.schemas[name] = *g.object(typ)
+	return name
+}
+
+// object describes a struct type as an object with the fields encoding/json
+// would encode, flattening the embedded structs.
+func (g *schemaGenerator) object(typ reflect.Type) *JSONSchema {
+	schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+	g.fields(typ, schema)
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// fields adds the fields of a struct type to the given object schema. Fields
+// that may be left out or null are not required.
+func (g *schemaGenerator) fields(typ reflect.Type, schema *JSONSchema) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+		ftyp := field.Type
+		if ftyp.Kind() == reflect.Ptr {
+			ftyp = ftyp.Elem()
+		}
+		if field.Anonymous && name == "" && ftyp.Kind() == reflect.Struct {
+			g.fields(ftyp, schema)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = field.Name
+		}
+		var fschema *JSONSchema
+		if hasOption(opts, "string") {
+			fschema = &JSONSchema{Type: "string"}
+		} else {
+			fschema = g.schema(field.Type)
+		}
+		schema.Properties[name] = fschema
+		if !hasOption(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// hasOption reports whether the comma separated options of a struct tag contain
+// the given one.
+func hasOption(opts string, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
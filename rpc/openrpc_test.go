@@ -0,0 +1,122 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/simplechain-org/go-simplechain/common"
+	"github.com/simplechain-org/go-simplechain/common/hexutil"
+)
+
+type OpenRPCTestService struct{}
+
+type OpenRPCTestBlock struct {
+	Hash   common.Hash       `json:"hash"`
+	Parent *OpenRPCTestBlock `json:"parent"`
+	Extra  hexutil.Bytes     `json:"extra,omitempty"`
+	Hidden string            `json:"-"`
+}
+
+func (s *OpenRPCTestService) Balance(addr common.Address, number *BlockNumber) (*hexutil.Big, error) {
+	return nil, nil
+}
+
+func (s *OpenRPCTestService) Block(hash common.Hash) *OpenRPCTestBlock {
+	return nil
+}
+
+// Tests that the OpenRPC document describes the methods and subscriptions of the
+// registered services, with the schemas derived from their Go types.
+func TestDiscover(t *testing.T) {
+	server := NewServer()
+	server.RegisterName("test", new(OpenRPCTestService))
+	server.RegisterName("eth", new(NotificationTestService))
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	var doc OpenRPCDocument
+	if err := client.Call(&doc, "rpc_discover"); err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+	if doc.OpenRPC != OpenRPCVersion {
+		t.Errorf("version mismatch: have %s, want %s", doc.OpenRPC, OpenRPCVersion)
+	}
+	methods := make(map[string]*OpenRPCMethod)
+	for i, method := range doc.Methods {
+		if i > 0 && doc.Methods[i-1].Name >= method.Name {
+			t.Errorf("methods not sorted: %s before %s", doc.Methods[i-1].Name, method.Name)
+		}
+		methods[method.Name] = method
+	}
+	for _, name := range []string{"rpc_discover", "rpc_modules", "eth_echo", "eth_subscribe_someSubscription", "eth_unsubscribe"} {
+		if methods[name] == nil {
+			t.Errorf("method %s missing", name)
+		}
+	}
+	// Check the parameters and results of the methods
+	balance := methods["test_balance"]
+	if balance == nil {
+		t.Fatal("method test_balance missing")
+	}
+	if len(balance.Params) != 2 {
+		t.Fatalf("parameter count mismatch: have %d, want 2", len(balance.Params))
+	}
+	if param := balance.Params[0]; param.Name != "address" || !param.Required || param.Schema.Pattern != "^0x[0-9a-fA-F]{40}$" {
+		t.Errorf("address parameter mismatch: %+v %+v", param, param.Schema)
+	}
+	if param := balance.Params[1]; param.Name != "blockNumber" || param.Required || len(param.Schema.OneOf) != 2 {
+		t.Errorf("block number parameter mismatch: %+v %+v", param, param.Schema)
+	}
+	if !reflect.DeepEqual(balance.Result.Schema, quantitySchema) {
+		t.Errorf("result schema mismatch: have %+v, want %+v", balance.Result.Schema, quantitySchema)
+	}
+	// Check the schemas of named structs, including recursive ones
+	block := methods["test_block"]
+	if block == nil {
+		t.Fatal("method test_block missing")
+	}
+	if ref := block.Result.Schema.Ref; ref != "#/components/schemas/rpc.OpenRPCTestBlock" {
+		t.Fatalf("result reference mismatch: have %s", ref)
+	}
+	if doc.Components == nil || doc.Components.Schemas["rpc.OpenRPCTestBlock"] == nil {
+		t.Fatal("block schema missing")
+	}
+	schema := doc.Components.Schemas["rpc.OpenRPCTestBlock"]
+	if schema.Type != "object" || len(schema.Properties) != 3 {
+		t.Fatalf("block schema mismatch: %+v", schema)
+	}
+	if schema.Properties["parent"].Ref != block.Result.Schema.Ref {
+		t.Errorf("parent reference mismatch: have %s", schema.Properties["parent"].Ref)
+	}
+	if schema.Properties["extra"].Pattern != bytesSchema.Pattern {
+		t.Errorf("extra schema mismatch: %+v", schema.Properties["extra"])
+	}
+	if want := []string{"hash"}; !reflect.DeepEqual(schema.Required, want) {
+		t.Errorf("required fields mismatch: have %v, want %v", schema.Required, want)
+	}
+	// Check the subscriptions
+	sub := methods["eth_subscribe_someSubscription"]
+	if sub.Subscription == nil || sub.Subscription.Method != "eth_subscribe" || sub.Subscription.Name != "someSubscription" {
+		t.Errorf("subscription mismatch: %+v", sub.Subscription)
+	}
+	if len(sub.Params) != 2 || sub.Params[0].Name != "int" || sub.Params[1].Name != "int2" || sub.Params[0].Schema.Type != "integer" {
+		t.Errorf("subscription parameters mismatch: %+v", sub.Params)
+	}
+}
@@ -0,0 +1,507 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/simplechain-org/go-simplechain/log"
+)
+
+// errConnectionLost is returned by the connections of a reconnecting client
+// once lost, the client itself taking care of reconnecting.
+var (
+	errConnectionLost = errors.New("connection lost")
+
+	// resubscribeTimeout bounds every attempt at resuming a subscription.
+	resubscribeTimeout = subscribeTimeout
+)
+
+// maxSubscriptionGaps is the number of gap notifications kept for consumers not
+// reading them, the oldest one being merged into the next once full.
+const maxSubscriptionGaps = 16
+
+// ReconnectConfig are the parameters of the reconnection attempts of a
+// reconnecting client.
+type ReconnectConfig struct {
+	MinBackoff time.Duration // Delay before the second attempt, doubled after every failure
+	MaxBackoff time.Duration // Maximum delay between attempts
+}
+
+// DefaultReconnectConfig contains the default reconnection parameters.
+var DefaultReconnectConfig = ReconnectConfig{
+	MinBackoff: 100 * time.Millisecond,
+	MaxBackoff: 30 * time.Second,
+}
+
+// ReconnectingClient is an RPC client reestablishing its connection whenever it
+// is lost, e.g. by a restart of the node, and resuming its subscriptions on the
+// new one.
+//
+// Requests issued while disconnected wait for the connection to be reestablished.
+// Requests in flight when the connection is lost fail and are not retried, as
+// they might have been executed.
+type ReconnectingClient struct {
+	dial   func(ctx context.Context) (*Client, error)
+	config ReconnectConfig
+
+	mu        sync.Mutex
+	client    *Client       // Current client, nil while reconnecting
+	connected chan struct{} // Closed once the current client is set
+	subs      map[*ReconnectingSubscription]struct{}
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	closed    chan struct{}
+}
+
+// DialReconnecting creates a reconnecting client for the given websocket, IPC or
+// binary URL, configured by the given options. The initial connection must
+// succeed, the following ones being retried with an exponential backoff.
+func DialReconnecting(ctx context.Context, rawurl string, config ReconnectConfig, options ...ClientOption) (*ReconnectingClient, error) {
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = DefaultReconnectConfig.MinBackoff
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = config.MinBackoff
+	}
+	dial := func(ctx context.Context) (*Client, error) {
+		client, err := DialOptions(ctx, rawurl, options...)
+		if err != nil {
+			return nil, err
+		}
+		if client.isHTTP {
+			client.Close()
+			return nil, errors.New("reconnecting clients need a persistent connection")
+		}
+		// Leave reconnecting to the reconnecting client, which resubscribes
+		client.connectFunc = func(context.Context) (net.Conn, error) {
+			return nil, errConnectionLost
+		}
+		return client, nil
+	}
+	client, err := dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rc := &ReconnectingClient{
+		dial:      dial,
+		config:    config,
+		client:    client,
+		connected: make(chan struct{}),
+		subs:      make(map[*ReconnectingSubscription]struct{}),
+		closing:   make(chan struct{}),
+		closed:    make(chan struct{}),
+	}
+	close(rc.connected)
+	go rc.loop(client)
+	return rc, nil
+}
+
+// Close closes the client, ending all its subscriptions and aborting the
+// requests waiting for a connection.
+func (rc *ReconnectingClient) Close() {
+	rc.closeOnce.Do(func() { close(rc.closing) })
+	<-rc.closed
+}
+
+// current returns the connected client, waiting for the connection to be
+// reestablished if needed.
+func (rc *ReconnectingClient) current(ctx context.Context) (*Client, error) {
+	for {
+		rc.mu.Lock()
+		client, connected := rc.client, rc.connected
+		rc.mu.Unlock()
+
+		if client != nil {
+			return client, nil
+		}
+		select {
+		case <-connected:
+		case <-rc.closing:
+			return nil, ErrClientQuit
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Call performs a JSON-RPC call, just like Client.Call.
+func (rc *ReconnectingClient) Call(result interface{}, method string, args ...interface{}) error {
+	return rc.CallContext(context.Background(), result, method, args...)
+}
+
+// CallContext performs a JSON-RPC call, just like Client.CallContext, waiting
+// for the connection to be reestablished if needed.
+func (rc *ReconnectingClient) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	client, err := rc.current(ctx)
+	if err != nil {
+		return err
+	}
+	return client.CallContext(ctx, result, method, args...)
+}
+
+// BatchCall sends all given requests as a single batch, just like
+// Client.BatchCall.
+func (rc *ReconnectingClient) BatchCall(b []BatchElem) error {
+	return rc.BatchCallContext(context.Background(), b)
+}
+
+// BatchCallContext sends all given requests as a single batch, just like
+// Client.BatchCallContext, waiting for the connection to be reestablished if
+// needed.
+func (rc *ReconnectingClient) BatchCallContext(ctx context.Context, b []BatchElem) error {
+	client, err := rc.current(ctx)
+	if err != nil {
+		return err
+	}
+	return client.BatchCallContext(ctx, b)
+}
+
+// EthSubscribe registers a resumable subscription under the "eth" namespace.
+func (rc *ReconnectingClient) EthSubscribe(ctx context.Context, channel interface{}, args ...interface{}) (*ReconnectingSubscription, error) {
+	return rc.Subscribe(ctx, "eth", channel, args...)
+}
+
+// Subscribe registers a subscription, just like Client.Subscribe, which is
+// reissued with the same arguments whenever the connection is reestablished.
+// Notifications sent by the server while disconnected are lost, consumers being
+// told about them by the gaps of the subscription.
+func (rc *ReconnectingClient) Subscribe(ctx context.Context, namespace string, channel interface{}, args ...interface{}) (*ReconnectingSubscription, error) {
+	chanVal := reflect.ValueOf(channel)
+	if chanVal.Kind() != reflect.Chan || chanVal.Type().ChanDir()&reflect.SendDir == 0 {
+		panic("first argument to Subscribe must be a writable channel")
+	}
+	if chanVal.IsNil() {
+		panic("channel given to Subscribe must not be nil")
+	}
+	client, err := rc.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := client.Subscribe(ctx, namespace, channel, args...)
+	if err != nil {
+		return nil, err
+	}
+	sub := &ReconnectingSubscription{
+		rc:        rc,
+		namespace: namespace,
+		channel:   channel,
+		args:      args,
+		gaps:      make(chan SubscriptionGap, maxSubscriptionGaps),
+		err:       make(chan error, 1),
+		quit:      make(chan struct{}),
+	}
+	rc.mu.Lock()
+	select {
+	case <-rc.closing:
+		rc.mu.Unlock()
+		inner.Unsubscribe()
+		return nil, ErrClientQuit
+	default:
+	}
+	rc.subs[sub] = struct{}{}
+	current := rc.client
+	if current == client {
+		sub.attach(client, inner)
+	}
+	rc.mu.Unlock()
+
+	// If the connection was lost meanwhile, resume the subscription unless the
+	// reconnection is still ongoing and will
+	if current != client {
+		inner.Unsubscribe()
+		if current != nil {
+			go sub.resume(current, time.Now())
+		}
+	}
+	return sub, nil
+}
+
+// loop waits for the connection of the current client to be lost and
+// reconnects, until the client is closed.
+func (rc *ReconnectingClient) loop(client *Client) {
+	defer close(rc.closed)
+
+	for {
+		select {
+		case <-client.connLost:
+		case <-rc.closing:
+			rc.shutdown(client)
+			return
+		}
+		client.Close()
+		lost := time.Now()
+
+		rc.mu.Lock()
+		rc.client, rc.connected = nil, make(chan struct{})
+		rc.mu.Unlock()
+
+		log.Warn("RPC connection lost, reconnecting")
+		if client = rc.reconnect(); client == nil {
+			rc.shutdown(nil)
+			return
+		}
+		log.Info("RPC connection reestablished", "downtime", time.Since(lost))
+
+		rc.mu.Lock()
+		rc.client = client
+		close(rc.connected)
+		subs := make([]*ReconnectingSubscription, 0, len(rc.subs))
+		for sub := range rc.subs {
+			subs = append(subs, sub)
+		}
+		rc.mu.Unlock()
+
+		for _, sub := range subs {
+			go sub.resume(client, lost)
+		}
+	}
+}
+
+// reconnect dials until a connection is established, backing off exponentially
+// between attempts. It returns nil if the client is closed meanwhile.
+func (rc *ReconnectingClient) reconnect() *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-rc.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	backoff := rc.config.MinBackoff
+	for {
+		client, err := rc.dial(ctx)
+		if err == nil {
+			return client
+		}
+		log.Debug("RPC reconnection failed", "err", err, "retry", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-rc.closing:
+			return nil
+		}
+		if backoff *= 2; backoff > rc.config.MaxBackoff {
+			backoff = rc.config.MaxBackoff
+		}
+	}
+}
+
+// shutdown ends all subscriptions and closes the given client.
+func (rc *ReconnectingClient) shutdown(client *Client) {
+	rc.mu.Lock()
+	subs := rc.subs
+	rc.subs = make(map[*ReconnectingSubscription]struct{})
+	rc.mu.Unlock()
+
+	for sub := range subs {
+		sub.fail(nil)
+	}
+	if client != nil {
+		client.Close()
+	}
+}
+
+// SubscriptionGap is a period during which the notifications of a resumed
+// subscription were lost, to be backfilled by its consumer if needed.
+type SubscriptionGap struct {
+	Lost    time.Time // Time the connection was lost
+	Resumed time.Time // Time the subscription was reissued
+}
+
+// ReconnectingSubscription is a subscription of a reconnecting client, resumed
+// whenever the connection is reestablished.
+type ReconnectingSubscription struct {
+	rc        *ReconnectingClient
+	namespace string
+	channel   interface{}
+	args      []interface{}
+
+	mu    sync.Mutex
+	inner *ClientSubscription // Subscription on the current connection, nil if none
+	lost  time.Time           // Start of the gap not yet reported, if resuming failed
+
+	gaps     chan SubscriptionGap
+	err      chan error
+	quitOnce sync.Once
+	quit     chan struct{}
+}
+
+// Gaps returns a channel receiving the periods during which notifications were
+// lost, after each resumption of the subscription.
+func (sub *ReconnectingSubscription) Gaps() <-chan SubscriptionGap {
+	return sub.gaps
+}
+
+// Err returns the subscription error channel, receiving a value when the
+// subscription has ended due to an error not caused by the connection. The
+// received error is nil if the client was closed.
+//
+// The error channel is closed when Unsubscribe is called on the subscription.
+func (sub *ReconnectingSubscription) Err() <-chan error {
+	return sub.err
+}
+
+// Unsubscribe unsubscribes the notification and closes the error channel.
+// It can safely be called more than once.
+func (sub *ReconnectingSubscription) Unsubscribe() {
+	sub.rc.mu.Lock()
+	delete(sub.rc.subs, sub)
+	sub.rc.mu.Unlock()
+
+	sub.quitOnce.Do(func() {
+		close(sub.quit)
+
+		sub.mu.Lock()
+		inner := sub.inner
+		sub.inner = nil
+		sub.mu.Unlock()
+
+		if inner != nil {
+			inner.Unsubscribe()
+		}
+		close(sub.err)
+	})
+}
+
+// attach sets the subscription established on the given client and watches it
+// for errors. It returns false if the subscription was ended meanwhile.
+func (sub *ReconnectingSubscription) attach(client *Client, inner *ClientSubscription) bool {
+	sub.mu.Lock()
+	select {
+	case <-sub.quit:
+		sub.mu.Unlock()
+		inner.Unsubscribe()
+		return false
+	default:
+	}
+	sub.inner, sub.lost = inner, time.Time{}
+	sub.mu.Unlock()
+
+	go func() {
+		select {
+		case err := <-inner.Err():
+			select {
+			case <-client.connLost:
+				// Resumed once reconnected
+			default:
+				if err != nil {
+					sub.fail(err)
+				}
+			}
+		case <-sub.quit:
+		}
+	}()
+	return true
+}
+
+// resume reissues the subscription on the given client and reports the
+// notifications lost since the connection was. Attempts failing while the
+// connection stays up, e.g. by timing out, are retried with an exponential
+// backoff, the ones of a lost connection being left to the next one.
+func (sub *ReconnectingSubscription) resume(client *Client, lost time.Time) {
+	sub.mu.Lock()
+	if sub.lost.IsZero() {
+		sub.lost = lost
+	}
+	lost, sub.inner = sub.lost, nil
+	sub.mu.Unlock()
+
+	var (
+		inner   *ClientSubscription
+		backoff = sub.rc.config.MinBackoff
+	)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), resubscribeTimeout)
+		var err error
+		inner, err = client.Subscribe(ctx, sub.namespace, sub.channel, sub.args...)
+		cancel()
+		if err == nil {
+			break
+		}
+		if _, ok := err.(Error); ok {
+			// Rejected by the server, the subscription can't be resumed
+			sub.fail(err)
+			return
+		}
+		log.Debug("Failed to resume RPC subscription", "namespace", sub.namespace, "err", err, "retry", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-client.connLost:
+			return // Retried on the next connection
+		case <-sub.quit:
+			return
+		}
+		if backoff *= 2; backoff > sub.rc.config.MaxBackoff {
+			backoff = sub.rc.config.MaxBackoff
+		}
+	}
+	// Don't attach to a connection lost meanwhile, the next one takes over
+	select {
+	case <-client.connLost:
+		inner.Unsubscribe()
+		return
+	default:
+	}
+	if !sub.attach(client, inner) {
+		return
+	}
+	gap := SubscriptionGap{Lost: lost, Resumed: time.Now()}
+	for {
+		select {
+		case sub.gaps <- gap:
+			return
+		default:
+		}
+		// Merge with the oldest gap not yet consumed
+		select {
+		case old := <-sub.gaps:
+			gap.Lost = old.Lost
+		default:
+		}
+	}
+}
+
+// fail ends the subscription with the given error.
+func (sub *ReconnectingSubscription) fail(err error) {
+	sub.rc.mu.Lock()
+	delete(sub.rc.subs, sub)
+	sub.rc.mu.Unlock()
+
+	sub.quitOnce.Do(func() {
+		close(sub.quit)
+
+		sub.mu.Lock()
+		inner := sub.inner
+		sub.inner = nil
+		sub.mu.Unlock()
+
+		if inner != nil {
+			inner.Unsubscribe()
+		}
+		sub.err <- err
+	})
+}
@@ -0,0 +1,245 @@
+// Copyright 2019 The go-simplechain Authors
+// This file is part of the go-simplechain library.
+//
+// The go-simplechain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-simplechain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-simplechain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startReconnectServer serves the given services over websocket on addr, waiting
+// for the address of a previous server to be released.
+func startReconnectServer(t *testing.T, addr string, services map[string]interface{}) (*Server, net.Listener) {
+	srv := NewServer()
+	for name, service := range services {
+		if err := srv.RegisterName(name, service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var (
+		l   net.Listener
+		err error
+	)
+	for i := 0; i < 50; i++ {
+		if l, err = net.Listen("tcp", addr); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	go http.Serve(l, srv.WebsocketHandler([]string{"*"}))
+	return srv, l
+}
+
+// Tests that a reconnecting client survives restarts of the server, resuming its
+// subscriptions and reporting the gaps in their notifications.
+func TestReconnectingClient(t *testing.T) {
+	startServer := func(addr string) (*Server, net.Listener) {
+		return startReconnectServer(t, addr, map[string]interface{}{
+			"service": new(Service),
+			"eth":     new(NotificationTestService),
+		})
+	}
+	s1, l1 := startServer("127.0.0.1:0")
+
+	config := ReconnectConfig{MinBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	client, err := DialReconnecting(context.Background(), "ws://"+l1.Addr().String(), config)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	var resp Result
+	if err := client.Call(&resp, "service_echo", "", 1, nil); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	nc := make(chan int)
+	sub, err := client.EthSubscribe(context.Background(), nc, "bufferedSubscription", 3, 0)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	readNotifications := func() {
+		for i := 0; i < 3; i++ {
+			select {
+			case val := <-nc:
+				if val != i {
+					t.Fatalf("notification mismatch: have %d, want %d", val, i)
+				}
+			case err := <-sub.Err():
+				t.Fatalf("subscription failed: %v", err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("notification %d timed out", i)
+			}
+		}
+	}
+	readNotifications()
+
+	// Restart the server a few times, the subscription must be resumed each time
+	for restart := 0; restart < 2; restart++ {
+		lost := time.Now()
+		l1.Close()
+		s1.Stop()
+
+		// Calls must wait for the connection to be reestablished
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		if err := client.CallContext(ctx, &resp, "service_echo", "", 2, nil); err == nil {
+			t.Fatalf("restart %d: call succeeded while the server is down", restart)
+		}
+		cancel()
+
+		s1, l1 = startServer(l1.Addr().String())
+
+		select {
+		case gap := <-sub.Gaps():
+			if gap.Lost.Before(lost) || gap.Resumed.Before(gap.Lost) {
+				t.Errorf("restart %d: invalid gap %v - %v", restart, gap.Lost, gap.Resumed)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("restart %d: subscription failed: %v", restart, err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("restart %d: subscription not resumed", restart)
+		}
+		readNotifications()
+
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		if err := client.CallContext(ctx, &resp, "service_echo", "", 3, nil); err != nil {
+			t.Errorf("restart %d: call failed after reconnecting: %v", restart, err)
+		}
+		cancel()
+	}
+	// Closing the client ends the subscriptions
+	client.Close()
+	select {
+	case err := <-sub.Err():
+		if err != nil {
+			t.Errorf("subscription failed on close: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("subscription not ended on close")
+	}
+	l1.Close()
+	s1.Stop()
+}
+
+// FlakyNotificationService hangs the second subscription made to it, which is
+// the first attempt of a reconnecting client at resuming the subscription.
+type FlakyNotificationService struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *FlakyNotificationService) FlakySubscription(ctx context.Context, n int) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	s.mu.Lock()
+	s.calls++
+	call := s.calls
+	s.mu.Unlock()
+
+	if call == 2 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+		return nil, errors.New("subscription hung")
+	}
+	subscription := notifier.CreateBufferedSubscription()
+	for i := 0; i < n; i++ {
+		if err := notifier.Notify(subscription.ID, i); err != nil {
+			return nil, err
+		}
+	}
+	return subscription, nil
+}
+
+// Tests that a subscription whose first resubscription times out on the new
+// connection is retried, resumed and reported with a gap.
+func TestReconnectingClientResubscribeTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { resubscribeTimeout = timeout }(resubscribeTimeout)
+	resubscribeTimeout = 100 * time.Millisecond
+
+	service := new(FlakyNotificationService)
+	services := map[string]interface{}{"eth": service}
+	s1, l1 := startReconnectServer(t, "127.0.0.1:0", services)
+
+	config := ReconnectConfig{MinBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	client, err := DialReconnecting(context.Background(), "ws://"+l1.Addr().String(), config)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	nc := make(chan int)
+	sub, err := client.EthSubscribe(context.Background(), nc, "flakySubscription", 3)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	readNotifications := func() {
+		for i := 0; i < 3; i++ {
+			select {
+			case val := <-nc:
+				if val != i {
+					t.Fatalf("notification mismatch: have %d, want %d", val, i)
+				}
+			case err := <-sub.Err():
+				t.Fatalf("subscription failed: %v", err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("notification %d timed out", i)
+			}
+		}
+	}
+	readNotifications()
+
+	lost := time.Now()
+	l1.Close()
+	s1.Stop()
+	s2, l2 := startReconnectServer(t, l1.Addr().String(), services)
+	defer s2.Stop()
+	defer l2.Close()
+
+	select {
+	case gap := <-sub.Gaps():
+		if gap.Lost.Before(lost) || gap.Resumed.Sub(gap.Lost) < resubscribeTimeout {
+			t.Errorf("invalid gap %v - %v", gap.Lost, gap.Resumed)
+		}
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription not resumed")
+	}
+	readNotifications()
+
+	service.mu.Lock()
+	calls := service.calls
+	service.mu.Unlock()
+	if calls != 3 {
+		t.Errorf("subscription attempts mismatch: have %d, want 3", calls)
+	}
+}
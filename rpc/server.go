@@ -116,6 +116,16 @@ func (s *Server) RegisterName(name string, rcvr interface{}) error {
 	return nil
 }
 
+// SetBatchLimits limits the number of requests in a batch and the size of the
+// responses, that of a batch being the sum of its elements. Responses exceeding
+// the size limit are replaced by an error before being written, ResultStream
+// results only being streamed without a limit. It must be called before the
+// server starts serving.
+func (s *Server) SetBatchLimits(itemLimit, responseSizeLimit int) {
+	s.batchItemLimit = itemLimit
+	s.responseSizeLimit = responseSizeLimit
+}
+
 // serveRequest will reads requests from the codec, calls the RPC callback and
 // writes the response to the given codec.
 //
@@ -147,6 +157,9 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 	if options&OptionSubscriptions == OptionSubscriptions {
 		ctx = context.WithValue(ctx, notifierKey{}, newNotifier(codec))
 	}
+	if c, ok := codec.(*jsonCodec); ok {
+		c.limit = s.responseSizeLimit
+	}
 	s.codecsMu.Lock()
 	if atomic.LoadInt32(&s.run) != 1 { // server stopped
 		s.codecsMu.Unlock()
@@ -184,6 +197,14 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 			}
 			return nil
 		}
+		// reject batches holding too many requests without executing any
+		if batch && s.batchItemLimit > 0 && len(reqs) > s.batchItemLimit {
+			if err := codec.Write(createErrorResponse(codec, nil, &batchTooLargeError{s.batchItemLimit})); err != nil || singleShot {
+				pend.Wait()
+				return nil
+			}
+			continue
+		}
 		// If a single shot request is executing, run and return immediately
 		if singleShot {
 			if batch {
@@ -212,8 +233,13 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 // response back using the given codec. It will block until the codec is closed or the server is
 // stopped. In either case the codec is closed.
 func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
+	s.serveCodec(s.withAuth(context.Background(), ""), codec, options)
+}
+
+// serveCodec is like ServeCodec, serving the requests within the given context.
+func (s *Server) serveCodec(ctx context.Context, codec ServerCodec, options CodecOption) {
 	defer codec.Close()
-	s.serveRequest(context.Background(), codec, false, options)
+	s.serveRequest(s.withLimiter(ctx), codec, false, options)
 }
 
 // ServeSingleRequest reads and processes a single RPC request from the given codec. It will not
@@ -274,6 +300,17 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, &invalidParamsError{"Expected subscription id as first argument"}), nil
 	}
 
+	// check the credentials of the request allow calling the method
+	if err := s.authorize(ctx, req.method); err != nil {
+		return codec.CreateErrorResponse(&req.id, err), nil
+	}
+	// charge the request against the budget of its client
+	release, limitErr := s.admit(ctx, req.method)
+	if limitErr != nil {
+		return createErrorResponse(codec, req.id, limitErr), nil
+	}
+	defer release()
+
 	if req.callb.isSubscribe {
 		subid, err := s.createSubscription(ctx, codec, req)
 		if err != nil {
@@ -297,6 +334,9 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, rpcErr), nil
 	}
 
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	arguments := []reflect.Value{req.callb.rcvr}
 	if req.callb.hasCtx {
 		arguments = append(arguments, reflect.ValueOf(ctx))
@@ -307,28 +347,43 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 
 	// execute RPC method and return result
 	reply := req.callb.method.Func.Call(arguments)
+
+	// results of requests interrupted by their timeout are unreliable, drop them
+	if s.limits != nil && s.limits.Timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		rpcTimeoutMeter.Mark(1)
+		return codec.CreateErrorResponse(&req.id, &timeoutError{req.method, s.limits.Timeout}), nil
+	}
 	if len(reply) == 0 {
 		return codec.CreateResponse(req.id, nil), nil
 	}
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
+			var rpcErr Error = &callbackError{e.Error()}
+			if ec, ok := e.(Error); ok {
+				rpcErr = ec
+			}
+			if de, ok := e.(DataError); ok {
+				return codec.CreateErrorResponseWithInfo(&req.id, rpcErr, de.ErrorData()), nil
+			}
+			return codec.CreateErrorResponse(&req.id, rpcErr), nil
 		}
 	}
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
 }
 
+// createErrorResponse creates an error response, including the error data if
+// the error carries any.
+func createErrorResponse(codec ServerCodec, id interface{}, err Error) interface{} {
+	if de, ok := err.(DataError); ok {
+		return codec.CreateErrorResponseWithInfo(&id, err, de.ErrorData())
+	}
+	return codec.CreateErrorResponse(&id, err)
+}
+
 // exec executes the given request and writes the result back using the codec.
 func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest) {
-	var response interface{}
-	var callback func()
-	if req.err != nil {
-		response = codec.CreateErrorResponse(&req.id, req.err)
-	} else {
-		response, callback = s.handle(ctx, codec, req)
-	}
+	response, callback := s.handleMetered(ctx, codec, req)
 
 	if err := codec.Write(response); err != nil {
 		log.Error(fmt.Sprintf("%v\n", err))
@@ -347,13 +402,9 @@ func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*s
 	responses := make([]interface{}, len(requests))
 	var callbacks []func()
 	for i, req := range requests {
-		if req.err != nil {
-			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
-		} else {
-			var callback func()
-			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
-				callbacks = append(callbacks, callback)
-			}
+		var callback func()
+		if responses[i], callback = s.handleMetered(ctx, codec, req); callback != nil {
+			callbacks = append(callbacks, callback)
 		}
 	}
 
@@ -407,7 +458,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 
 		if r.isPubSub { // eth_subscribe, r.method contains the subscription method name
 			if callb, ok := svc.subscriptions[r.method]; ok {
-				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+				requests[i] = &serverRequest{id: r.id, svcname: svc.name, method: svc.name + subscribeMethodSuffix, callb: callb, params: r.params}
 				if r.params != nil && len(callb.argTypes) > 0 {
 					argTypes := []reflect.Type{reflect.TypeOf("")}
 					argTypes = append(argTypes, callb.argTypes...)
@@ -424,7 +475,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 		}
 
 		if callb, ok := svc.callbacks[r.method]; ok { // lookup RPC method
-			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+			requests[i] = &serverRequest{id: r.id, svcname: svc.name, method: svc.name + serviceMethodSeparator + r.method, callb: callb, params: r.params}
 			if r.params != nil && len(callb.argTypes) > 0 {
 				if args, err := codec.ParseRequestArguments(callb.argTypes, r.params); err == nil {
 					requests[i].args = args
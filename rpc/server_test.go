@@ -17,10 +17,17 @@
 package rpc
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -160,3 +167,226 @@ func TestServerMethodExecution(t *testing.T) {
 func TestServerMethodWithCtx(t *testing.T) {
 	testServerMethodExecution(t, "echoWithCtx")
 }
+
+// LargeService returns results of arbitrary size.
+type LargeService struct{}
+
+func (s *LargeService) Repeat(str string, n int) []string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = str
+	}
+	return items
+}
+
+func (s *LargeService) Stream(str string, n int) ResultStream {
+	return func(emit func(interface{}) error) error {
+		for i := 0; i < n; i++ {
+			if err := emit(str); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (s *LargeService) Broken(n int) ResultStream {
+	return func(emit func(interface{}) error) error {
+		for i := 0; i < n; i++ {
+			if err := emit(strings.Repeat("x", 1024)); err != nil {
+				return err
+			}
+		}
+		return errors.New("stream broken")
+	}
+}
+
+func (s *LargeService) Blob(n int) string {
+	return strings.Repeat("x", n)
+}
+
+// postRaw sends a raw request body to an HTTP server, returning the response.
+func postRaw(t *testing.T, url string, body string) []byte {
+	t.Helper()
+
+	resp, err := http.Post(url, contentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	blob, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	return blob
+}
+
+// Tests that batches holding more requests than allowed are rejected with a
+// single error, without executing any of them.
+func TestServerBatchItemLimit(t *testing.T) {
+	server := newTestServer("large", new(LargeService))
+	server.SetBatchLimits(2, 0)
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	batch := []BatchElem{
+		{Method: "large_blob", Args: []interface{}{1}, Result: new(string)},
+		{Method: "large_blob", Args: []interface{}{2}, Result: new(string)},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatalf("batch failed: %v", err)
+	}
+	for i, elem := range batch {
+		if elem.Error != nil {
+			t.Errorf("batch element %d failed: %v", i, elem.Error)
+		}
+	}
+	// Over the limit, the server answers with a single error object
+	hs := httptest.NewServer(server)
+	defer hs.Close()
+
+	var resp jsonErrResponse
+	if err := json.Unmarshal(postRaw(t, hs.URL, `[
+		{"jsonrpc":"2.0","id":1,"method":"large_blob","params":[1]},
+		{"jsonrpc":"2.0","id":2,"method":"large_blob","params":[1]},
+		{"jsonrpc":"2.0","id":3,"method":"large_blob","params":[1]}
+	]`), &resp); err != nil {
+		t.Fatalf("invalid batch limit response: %v", err)
+	}
+	if resp.Error.Code != -32005 {
+		t.Errorf("batch limit error code mismatch: have %d, want %d", resp.Error.Code, -32005)
+	}
+	if data, ok := resp.Error.Data.(map[string]interface{}); !ok || data["limit"] != float64(2) {
+		t.Errorf("batch limit error data mismatch: %v", resp.Error.Data)
+	}
+}
+
+// Tests that responses exceeding the size limit are replaced by errors when
+// they are encoded in memory, with batches limited as a whole.
+func TestServerResponseSizeLimit(t *testing.T) {
+	server := newTestServer("large", new(LargeService))
+	server.SetBatchLimits(0, 1024)
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	var result string
+	if err := client.Call(&result, "large_blob", 512); err != nil || len(result) != 512 {
+		t.Fatalf("small response failed: %v", err)
+	}
+	err := client.Call(&result, "large_blob", 2048)
+	if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != -32005 {
+		t.Fatalf("large response error mismatch: have %v, want code %d", err, -32005)
+	}
+	// The responses of a batch share the budget, later ones being replaced
+	batch := []BatchElem{
+		{Method: "large_blob", Args: []interface{}{600}, Result: new(string)},
+		{Method: "large_blob", Args: []interface{}{600}, Result: new(string)},
+		{Method: "large_blob", Args: []interface{}{10}, Result: new(string)},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatalf("batch failed: %v", err)
+	}
+	if batch[0].Error != nil {
+		t.Errorf("first batch element failed: %v", batch[0].Error)
+	}
+	if rpcErr, ok := batch[1].Error.(Error); !ok || rpcErr.ErrorCode() != -32005 {
+		t.Errorf("second batch element error mismatch: have %v, want code %d", batch[1].Error, -32005)
+	}
+	if batch[2].Error != nil {
+		t.Errorf("third batch element failed: %v", batch[2].Error)
+	}
+}
+
+// Tests that stream results are written to HTTP clients as they are produced
+// without a size limit, encoded identically to in-memory responses.
+func TestServerStreamResults(t *testing.T) {
+	server := newTestServer("large", new(LargeService))
+	defer server.Stop()
+
+	hs := httptest.NewServer(server)
+	defer hs.Close()
+
+	// Streamed results must be byte for byte the regular encoding, pinning the
+	// field order of the hand-built envelope
+	for _, id := range []string{"1", `"abc"`} {
+		for _, n := range []int{0, 1, 100} {
+			have := postRaw(t, hs.URL, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"method":"large_stream","params":["item",%d]}`, id, n))
+			want, _ := json.Marshal(&jsonSuccessResponse{Version: jsonrpcVersion, Id: json.RawMessage(id), Result: new(LargeService).Repeat("item", n)})
+			if !bytes.Equal(bytes.TrimSpace(have), want) {
+				t.Errorf("streamed response of %d items mismatch:\nhave %s\nwant %s", n, have, want)
+			}
+		}
+	}
+	have := postRaw(t, hs.URL, `[{"jsonrpc":"2.0","id":1,"method":"large_stream","params":["a",2]},{"jsonrpc":"2.0","id":2,"method":"large_blob","params":[1]}]`)
+	if want := `[{"jsonrpc":"2.0","id":1,"result":["a","a"]},{"jsonrpc":"2.0","id":2,"result":"x"}]`; string(bytes.TrimSpace(have)) != want {
+		t.Errorf("streamed batch mismatch:\nhave %s\nwant %s", have, want)
+	}
+	// Streams failing midway must not leave a valid looking response behind
+	client, err := Dial(hs.URL)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	var items []string
+	if err := client.Call(&items, "large_stream", "item", 1000); err != nil || len(items) != 1000 {
+		t.Fatalf("streamed call failed: %v", err)
+	}
+	if err := client.Call(&items, "large_broken", 100); err == nil {
+		t.Fatal("broken stream succeeded")
+	}
+}
+
+// Tests that stream results are collected in memory when the size of responses
+// is limited.
+func TestServerStreamResultsInMemory(t *testing.T) {
+	server := newTestServer("large", new(LargeService))
+	server.SetBatchLimits(0, 64*1024)
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	var items []string
+	if err := client.Call(&items, "large_stream", "item", 3); err != nil || !reflect.DeepEqual(items, []string{"item", "item", "item"}) {
+		t.Fatalf("stream call mismatch: have %v, err %v", items, err)
+	}
+	if err := client.Call(&items, "large_broken", 1); err == nil {
+		t.Fatal("broken stream succeeded")
+	}
+}
+
+// Tests that list results exceeding the size limit are replaced by an error
+// before anything is written, instead of truncating the HTTP body.
+func TestServerResponseSizeLimitHTTP(t *testing.T) {
+	server := newTestServer("large", new(LargeService))
+	server.SetBatchLimits(0, 64*1024)
+	defer server.Stop()
+
+	hs := httptest.NewServer(server)
+	defer hs.Close()
+
+	client, err := Dial(hs.URL)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	var items []string
+	if err := client.Call(&items, "large_stream", "item", 1000); err != nil || len(items) != 1000 {
+		t.Fatalf("call within the size limit failed: %v", err)
+	}
+	err = client.Call(&items, "large_stream", "item", 100000)
+	if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != -32005 {
+		t.Fatalf("large response error mismatch: have %v, want code %d", err, -32005)
+	}
+	// The connection must remain usable after the replaced response
+	if err := client.Call(&items, "large_repeat", "item", 10); err != nil || len(items) != 10 {
+		t.Fatalf("call after the large response failed: %v", err)
+	}
+}
@@ -38,6 +38,9 @@ type Subscription struct {
 	ID        ID
 	namespace string
 	err       chan error // closed on unsubscribe
+
+	buffered bool          // queue notifications until activated instead of dropping them
+	queue    []interface{} // notifications sent before the subscription was activated
 }
 
 // Err returns a channel that is closed when the client send an unsubscribe request.
@@ -85,19 +88,38 @@ func (n *Notifier) CreateSubscription() *Subscription {
 	return s
 }
 
+// CreateBufferedSubscription returns a new subscription that is coupled to the
+// RPC connection. Unlike with CreateSubscription, notifications sent before the
+// subscription is activated are queued and delivered once it is, so none are
+// lost while the subscription ID is sent to the client.
+func (n *Notifier) CreateBufferedSubscription() *Subscription {
+	s := n.CreateSubscription()
+	s.buffered = true
+	return s
+}
+
 // Notify sends a notification to the client with the given data as payload.
 // If an error occurs the RPC connection is closed and the error is returned.
 func (n *Notifier) Notify(id ID, data interface{}) error {
-	n.subMu.RLock()
-	defer n.subMu.RUnlock()
+	n.subMu.Lock()
+	defer n.subMu.Unlock()
 
 	sub, active := n.active[id]
 	if active {
-		notification := n.codec.CreateNotification(string(id), sub.namespace, data)
-		if err := n.codec.Write(notification); err != nil {
-			n.codec.Close()
-			return err
-		}
+		return n.send(sub, data)
+	}
+	if sub, inactive := n.inactive[id]; inactive && sub.buffered {
+		sub.queue = append(sub.queue, data)
+	}
+	return nil
+}
+
+// send writes a notification of a subscription to the client.
+func (n *Notifier) send(sub *Subscription, data interface{}) error {
+	notification := n.codec.CreateNotification(string(sub.ID), sub.namespace, data)
+	if err := n.codec.Write(notification); err != nil {
+		n.codec.Close()
+		return err
 	}
 	return nil
 }
@@ -121,9 +143,10 @@ func (n *Notifier) unsubscribe(id ID) error {
 }
 
 // activate enables a subscription. Until a subscription is enabled all
-// notifications are dropped. This method is called by the RPC server after
-// the subscription ID was sent to client. This prevents notifications being
-// send to the client before the subscription ID is send to the client.
+// notifications are dropped, or queued for buffered subscriptions. This method
+// is called by the RPC server after the subscription ID was sent to client. This
+// prevents notifications being send to the client before the subscription ID is
+// send to the client.
 func (n *Notifier) activate(id ID, namespace string) {
 	n.subMu.Lock()
 	defer n.subMu.Unlock()
@@ -131,5 +154,13 @@ func (n *Notifier) activate(id ID, namespace string) {
 		sub.namespace = namespace
 		n.active[id] = sub
 		delete(n.inactive, id)
+
+		// Deliver the notifications queued while the subscription was inactive
+		for _, data := range sub.queue {
+			if err := n.send(sub, data); err != nil {
+				break
+			}
+		}
+		sub.queue = nil
 	}
 }
@@ -87,6 +87,22 @@ func (s *NotificationTestService) SomeSubscription(ctx context.Context, n, val i
 	return subscription, nil
 }
 
+// BufferedSubscription sends all its notifications before the subscription is
+// activated, relying on them being queued until the subscription ID was sent.
+func (s *NotificationTestService) BufferedSubscription(ctx context.Context, n, val int) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	subscription := notifier.CreateBufferedSubscription()
+	for i := 0; i < n; i++ {
+		if err := notifier.Notify(subscription.ID, val+i); err != nil {
+			return nil, err
+		}
+	}
+	return subscription, nil
+}
+
 // HangSubscription blocks on s.unblockHangSubscription before
 // sending anything.
 func (s *NotificationTestService) HangSubscription(ctx context.Context, val int) (*Subscription, error) {
@@ -164,6 +180,55 @@ func TestNotifications(t *testing.T) {
 	}
 }
 
+// Tests that notifications of buffered subscriptions sent before the subscription
+// ID was returned are delivered after it, instead of being dropped.
+func TestBufferedNotifications(t *testing.T) {
+	server := NewServer()
+	service := &NotificationTestService{}
+
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatalf("unable to register test service %v", err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation|OptionSubscriptions)
+
+	out := json.NewEncoder(clientConn)
+	in := json.NewDecoder(clientConn)
+
+	n, val := 5, 12345
+	request := map[string]interface{}{
+		"id":      1,
+		"method":  "eth_subscribe",
+		"version": "2.0",
+		"params":  []interface{}{"bufferedSubscription", n, val},
+	}
+	if err := out.Encode(request); err != nil {
+		t.Fatal(err)
+	}
+	var response jsonSuccessResponse
+	if err := in.Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	subid, ok := response.Result.(string)
+	if !ok {
+		t.Fatalf("expected subscription id, got %T", response.Result)
+	}
+	for i := 0; i < n; i++ {
+		var notification jsonNotification
+		if err := in.Decode(&notification); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if notification.Params.Subscription != subid {
+			t.Fatalf("subscription id mismatch: have %s, want %s", notification.Params.Subscription, subid)
+		}
+		if int(notification.Params.Result.(float64)) != val+i {
+			t.Fatalf("expected %d, got %v", val+i, notification.Params.Result)
+		}
+	}
+}
+
 func waitForMessages(t *testing.T, in *json.Decoder, successes chan<- jsonSuccessResponse,
 	failures chan<- jsonErrResponse, notifications chan<- jsonNotification, errors chan<- error) {
 
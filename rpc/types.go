@@ -17,13 +17,18 @@
 package rpc
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/simplechain-org/go-simplechain/common/hexutil"
 )
 
@@ -57,8 +62,10 @@ type service struct {
 type serverRequest struct {
 	id            interface{}
 	svcname       string
+	method        string // Fully qualified method name, checked against ACLs
 	callb         *callback
 	args          []reflect.Value
+	params        interface{} // Raw parameters, kept for logging
 	isUnsubscribe bool
 	err           Error
 }
@@ -74,6 +81,18 @@ type Server struct {
 	run      int32
 	codecsMu sync.Mutex
 	codecs   mapset.Set
+
+	auth *AuthConfig // Credentials required by the server, nil if open
+
+	limits     *LimitConfig  // Request budget enforced by the server, nil if unlimited
+	limiters   *lru.Cache    // Rate limiters of the API keys and client addresses
+	limitersMu sync.Mutex    // Lock protecting the creation of rate limiters
+	heavy      chan struct{} // Slots of the heavy requests being executed
+
+	batchItemLimit    int // Maximum number of requests in a batch, zero if unlimited
+	responseSizeLimit int // Maximum size of a response in bytes, zero if unlimited
+
+	slowThreshold time.Duration // Execution time above which requests are logged, zero if disabled
 }
 
 // rpcRequest represents a raw incoming RPC request
@@ -92,6 +111,13 @@ type Error interface {
 	ErrorCode() int // returns the code
 }
 
+// DataError is an RPC error which carries additional data, returned in the
+// data field of the JSON-RPC error object.
+type DataError interface {
+	Error() string          // returns the message
+	ErrorData() interface{} // returns the error data
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
@@ -119,13 +145,15 @@ type ServerCodec interface {
 type BlockNumber int64
 
 const (
-	PendingBlockNumber  = BlockNumber(-2)
-	LatestBlockNumber   = BlockNumber(-1)
-	EarliestBlockNumber = BlockNumber(0)
+	SafeBlockNumber      = BlockNumber(-4)
+	FinalizedBlockNumber = BlockNumber(-3)
+	PendingBlockNumber   = BlockNumber(-2)
+	LatestBlockNumber    = BlockNumber(-1)
+	EarliestBlockNumber  = BlockNumber(0)
 )
 
 // UnmarshalJSON parses the given JSON fragment into a BlockNumber. It supports:
-// - "latest", "earliest" or "pending" as string arguments
+// - "latest", "earliest", "pending", "safe" or "finalized" as string arguments
 // - the block number
 // Returned errors:
 // - an invalid block number error when the given argument isn't a known strings
@@ -146,6 +174,12 @@ func (bn *BlockNumber) UnmarshalJSON(data []byte) error {
 	case "pending":
 		*bn = PendingBlockNumber
 		return nil
+	case "safe":
+		*bn = SafeBlockNumber
+		return nil
+	case "finalized":
+		*bn = FinalizedBlockNumber
+		return nil
 	}
 
 	blckNum, err := hexutil.DecodeUint64(input)
@@ -163,3 +197,49 @@ func (bn *BlockNumber) UnmarshalJSON(data []byte) error {
 func (bn BlockNumber) Int64() int64 {
 	return (int64)(bn)
 }
+
+// ResultStream is a list result produced element by element while the response
+// is being written, sparing the server from assembling large results in memory.
+// The producer passes the elements in order to emit, returning its error if it
+// fails. It runs after the callback returned, so it can't rely on the context
+// of the request. Transports which can't stream results, and servers limiting
+// the size of responses, collect the elements into a list before writing it.
+type ResultStream func(emit func(elem interface{}) error) error
+
+// MarshalJSON collects the elements produced by the stream into a JSON array.
+func (s ResultStream) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+	buf := new(bytes.Buffer)
+	if err := s.writeJSON(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeJSON encodes the elements produced by the stream as a JSON array,
+// writing them as they are produced.
+func (s ResultStream) writeJSON(w io.Writer) error {
+	if _, err := w.Write([]byte{'['}); err != nil {
+		return err
+	}
+	first := true
+	err := s(func(elem interface{}) error {
+		blob, err := json.Marshal(elem)
+		if err != nil {
+			return err
+		}
+		if !first {
+			blob = append([]byte{','}, blob...)
+		}
+		first = false
+		_, err = w.Write(blob)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte{']'})
+	return err
+}
@@ -62,17 +62,42 @@ func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			// Create a custom encode/decode pair to enforce payload size and number encoding
 			conn.MaxPayloadBytes = maxRequestContentLength
 
+			// Drop any deadline inherited from an HTTP server sharing the port,
+			// the connection being long lived
+			conn.SetDeadline(time.Time{})
+
 			encoder := func(v interface{}) error {
 				return websocketJSONCodec.Send(conn, v)
 			}
 			decoder := func(v interface{}) error {
 				return websocketJSONCodec.Receive(conn, v)
 			}
-			srv.ServeCodec(NewCodec(conn, encoder, decoder), OptionMethodInvocation|OptionSubscriptions)
+			ctx := withTransport(srv.withAuth(context.Background(), bearerToken(conn.Request().Header)), "ws")
+			ctx = context.WithValue(ctx, "remote", conn.Request().RemoteAddr)
+			srv.serveCodec(ctx, NewCodec(conn, encoder, decoder), OptionMethodInvocation|OptionSubscriptions)
 		},
 	}
 }
 
+// NewHTTPWSHandler returns a handler serving websocket upgrade requests with the
+// websocket handler and all other requests with the HTTP one, allowing both to
+// share a port.
+func NewHTTPWSHandler(httpHandler http.Handler, wsHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isWebsocket(r) {
+			wsHandler.ServeHTTP(w, r)
+			return
+		}
+		httpHandler.ServeHTTP(w, r)
+	})
+}
+
+// isWebsocket returns whether an HTTP request asks for a websocket upgrade.
+func isWebsocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
 // NewWSServer creates a new websocket RPC server around an API provider.
 //
 // Deprecated: use Server.WebsocketHandler
@@ -124,6 +149,10 @@ func wsHandshakeValidator(allowedOrigins []string) func(*websocket.Config, *http
 // The context is used for the initial connection establishment. It does not
 // affect subsequent interactions with the client.
 func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error) {
+	return dialWebsocket(ctx, endpoint, origin, nil)
+}
+
+func dialWebsocket(ctx context.Context, endpoint, origin string, credentials Credentials) (*Client, error) {
 	if origin == "" {
 		var err error
 		if origin, err = os.Hostname(); err != nil {
@@ -141,7 +170,17 @@ func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error
 	}
 
 	return newClient(ctx, func(ctx context.Context) (net.Conn, error) {
-		return wsDialContext(ctx, config)
+		if credentials == nil {
+			return wsDialContext(ctx, config)
+		}
+		// Issue fresh credentials for every connection
+		token, err := credentials()
+		if err != nil {
+			return nil, err
+		}
+		authConfig := *config
+		authConfig.Header = http.Header{"Authorization": {"Bearer " + token}}
+		return wsDialContext(ctx, &authConfig)
 	})
 }
 